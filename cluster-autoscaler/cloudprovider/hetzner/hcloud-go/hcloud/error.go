@@ -29,6 +29,7 @@ const (
 	ErrorCodeRobotUnavailable      ErrorCode = "robot_unavailable"       // Robot was not available. The caller may retry the operation after a short delay
 	ErrorCodeResourceLocked        ErrorCode = "resource_locked"         // The resource is locked. The caller should contact support
 	ErrorUnsupportedError          ErrorCode = "unsupported_error"       // The given resource does not support this
+	ErrorCodeDeprecatedAPIEndpoint ErrorCode = "deprecated_api_endpoint" // The API endpoint is deprecated and will be removed
 
 	// Server related error codes.
 
@@ -126,6 +127,11 @@ type ErrorDetailsInvalidInputField struct {
 	Messages []string
 }
 
+// ErrorDetailsDeprecatedAPIEndpoint contains the details of a 'deprecated_api_endpoint' error.
+type ErrorDetailsDeprecatedAPIEndpoint struct {
+	Announcement string
+}
+
 // IsError returns whether err is an API error with the given error code.
 func IsError(err error, code ErrorCode) bool {
 	var apiErr Error
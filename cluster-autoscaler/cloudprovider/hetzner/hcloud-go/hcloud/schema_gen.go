@@ -618,6 +618,11 @@ func errorDetailsFromSchema(d interface{}) interface{} {
 		}
 		return details
 	}
+	if d, ok := d.(schema.ErrorDetailsDeprecatedAPIEndpoint); ok {
+		return ErrorDetailsDeprecatedAPIEndpoint{
+			Announcement: d.Announcement,
+		}
+	}
 	return nil
 }
 
@@ -637,6 +642,11 @@ func schemaFromErrorDetails(d interface{}) interface{} {
 		}
 		return details
 	}
+	if d, ok := d.(ErrorDetailsDeprecatedAPIEndpoint); ok {
+		return schema.ErrorDetailsDeprecatedAPIEndpoint{
+			Announcement: d.Announcement,
+		}
+	}
 	return nil
 }
 
@@ -27,6 +27,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud"
 	"k8s.io/autoscaler/cluster-autoscaler/config"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/gpu"
@@ -39,12 +40,18 @@ const (
 	// GPULabel is the label added to nodes with GPU resource.
 	GPULabel                   = hcloudLabelNamespace + "/gpu-node"
 	providerIDPrefix           = "hcloud://"
+	failedCreateIDPrefix       = "hcloud-failed-create://"
 	nodeGroupLabel             = hcloudLabelNamespace + "/node-group"
+	primaryIPCreatedLabel      = hcloudLabelNamespace + "/autoscaler-created"
 	hcloudLabelNamespace       = "hcloud"
 	drainingNodePoolId         = "draining-node-pool"
 	serverCreateTimeoutDefault = 5 * time.Minute
+	serverDeleteTimeoutDefault = 5 * time.Minute
 	serverRegisterTimeout      = 10 * time.Minute
+	serverReadyPollInterval    = 5 * time.Second
 	defaultPodAmountsLimit     = 110
+	// serverDeleteConcurrencyDefault is how many servers are deleted at once by default.
+	serverDeleteConcurrencyDefault = 10
 )
 
 // HetznerCloudProvider implements CloudProvider interface.
@@ -60,9 +67,10 @@ func (d *HetznerCloudProvider) Name() string {
 
 // NodeGroups returns all node groups configured for this cloud provider.
 func (d *HetznerCloudProvider) NodeGroups() []cloudprovider.NodeGroup {
-	groups := make([]cloudprovider.NodeGroup, 0, len(d.manager.nodeGroups))
-	for groupId := range d.manager.nodeGroups {
-		groups = append(groups, d.manager.nodeGroups[groupId])
+	nodeGroups := d.manager.allNodeGroups()
+	groups := make([]cloudprovider.NodeGroup, 0, len(nodeGroups))
+	for _, group := range nodeGroups {
+		groups = append(groups, group)
 	}
 	return groups
 }
@@ -71,6 +79,14 @@ func (d *HetznerCloudProvider) NodeGroups() []cloudprovider.NodeGroup {
 // should not be processed by cluster autoscaler, or non-nil error if such
 // occurred. Must be implemented.
 func (d *HetznerCloudProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider.NodeGroup, error) {
+	if groupId, ok := failedCreateNodeGroup(node.Spec.ProviderID); ok {
+		group, exists := d.manager.nodeGroup(groupId)
+		if !exists {
+			return nil, nil
+		}
+		return group, nil
+	}
+
 	server, err := d.manager.serverForNode(node)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if server %s exists error: %v", node.Spec.ProviderID, err)
@@ -92,7 +108,7 @@ func (d *HetznerCloudProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider
 		}
 	}
 
-	group, exists := d.manager.nodeGroups[groupId]
+	group, exists := d.manager.nodeGroup(groupId)
 	if !exists {
 		return nil, nil
 	}
@@ -127,6 +143,65 @@ func (d *HetznerCloudProvider) GetAvailableMachineTypes() ([]string, error) {
 	return types, nil
 }
 
+// ServerTypeInfo describes a server type that can be created in a location.
+type ServerTypeInfo struct {
+	Name   string
+	Cores  int
+	Memory float32
+	// PriceHourly and PriceMonthly are the prices of the server type in the location.
+	PriceHourly  hcloud.Price
+	PriceMonthly hcloud.Price
+}
+
+// GetAvailableServerTypes returns the server types that can be created in the
+// given location, along with their resources and prices in that location.
+// Deprecated server types are skipped.
+func (d *HetznerCloudProvider) GetAvailableServerTypes(location string) ([]ServerTypeInfo, error) {
+	serverTypes, err := d.manager.cachedServerType.getAllServerTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var available []ServerTypeInfo
+	for _, serverType := range serverTypes {
+		if serverType.IsDeprecated() {
+			continue
+		}
+		for _, pricing := range serverType.Pricings {
+			if pricing.Location == nil || pricing.Location.Name != location {
+				continue
+			}
+			available = append(available, ServerTypeInfo{
+				Name:         serverType.Name,
+				Cores:        serverType.Cores,
+				Memory:       serverType.Memory,
+				PriceHourly:  pricing.Hourly,
+				PriceMonthly: pricing.Monthly,
+			})
+			break
+		}
+	}
+
+	return available, nil
+}
+
+// LocationNodeCounts returns the number of servers of the configured node
+// groups in each location, e.g. fsn1. Servers being drained are not counted.
+func (d *HetznerCloudProvider) LocationNodeCounts() (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, group := range d.manager.allNodeGroups() {
+		if group.id == drainingNodePoolId {
+			continue
+		}
+		servers, err := d.manager.cachedServers.getServersByNodeGroupName(group.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get servers for node group %s error: %v", group.id, err)
+		}
+		counts[group.region] += len(servers)
+	}
+	return counts, nil
+}
+
 // NewNodeGroup builds a theoretical node group based on the node definition
 // provided. The node group is not automatically created on the cloud provider
 // side. The node group is not returned by NodeGroups() until it is created.
@@ -173,7 +248,7 @@ func (d *HetznerCloudProvider) Cleanup() error {
 // update cloud provider state. In particular the list of node groups returned
 // by NodeGroups() can change as a result of CloudProvider.Refresh().
 func (d *HetznerCloudProvider) Refresh() error {
-	for _, group := range d.manager.nodeGroups {
+	for _, group := range d.manager.allNodeGroups() {
 		group.resetTargetSize(0)
 	}
 	return nil
@@ -216,7 +291,11 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 			}
 		}
 
-		manager.nodeGroups[spec.name] = &hetznerNodeGroup{
+		if spec.maxSize > 1 && manager.hasPinnedPrimaryIP(spec.name) {
+			klog.Fatalf("Node group `%s` has a primary ip configured and can't have a max size of %d, a primary ip can only be assigned to one server", spec.name, spec.maxSize)
+		}
+
+		manager.addNodeGroup(&hetznerNodeGroup{
 			manager:            manager,
 			id:                 spec.name,
 			minSize:            spec.minSize,
@@ -225,7 +304,7 @@ func BuildHetzner(_ config.AutoscalingOptions, do cloudprovider.NodeGroupDiscove
 			region:             strings.ToLower(spec.region),
 			targetSize:         len(servers),
 			clusterUpdateMutex: &clusterUpdateLock,
-		}
+		})
 	}
 
 	return provider
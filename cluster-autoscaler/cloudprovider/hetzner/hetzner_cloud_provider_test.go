@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud/schema"
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+)
+
+func TestGetAvailableServerTypes(t *testing.T) {
+	price := func(location, hourly string) schema.PricingServerTypePrice {
+		return schema.PricingServerTypePrice{
+			Location:     location,
+			PriceHourly:  schema.Price{Net: hourly, Gross: hourly},
+			PriceMonthly: schema.Price{Net: "10", Gross: "10"},
+		}
+	}
+	pages := [][]schema.ServerType{
+		{
+			{ID: 1, Name: "cx22", Cores: 2, Memory: 4, Prices: []schema.PricingServerTypePrice{price("fsn1", "0.01"), price("nbg1", "0.02")}},
+			{ID: 2, Name: "cpx11", Cores: 2, Memory: 2, Prices: []schema.PricingServerTypePrice{price("ash", "0.03")}},
+		},
+		{
+			{ID: 3, Name: "cax11", Cores: 2, Memory: 4, Prices: []schema.PricingServerTypePrice{price("fsn1", "0.04")}},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		require.NoError(t, err)
+		pagination := schema.MetaPagination{Page: page, PerPage: 2, LastPage: len(pages), TotalEntries: 3}
+		if page < len(pages) {
+			pagination.NextPage = page + 1
+		}
+		writeJSON(t, w, http.StatusOK, struct {
+			schema.ServerTypeListResponse
+			Meta schema.Meta `json:"meta"`
+		}{
+			ServerTypeListResponse: schema.ServerTypeListResponse{ServerTypes: pages[page-1]},
+			Meta:                   schema.Meta{Pagination: &pagination},
+		})
+	})
+	provider := &HetznerCloudProvider{manager: newTestManager(t, mux)}
+
+	serverTypes, err := provider.GetAvailableServerTypes("fsn1")
+	require.NoError(t, err)
+	require.Len(t, serverTypes, 2)
+	assert.Equal(t, "cx22", serverTypes[0].Name)
+	assert.Equal(t, 2, serverTypes[0].Cores)
+	assert.Equal(t, float32(4), serverTypes[0].Memory)
+	assert.Equal(t, "0.01", serverTypes[0].PriceHourly.Gross)
+	assert.Equal(t, "cax11", serverTypes[1].Name)
+
+	serverTypes, err = provider.GetAvailableServerTypes("hel1")
+	require.NoError(t, err)
+	assert.Empty(t, serverTypes)
+}
+
+func TestLocationSpread(t *testing.T) {
+	servers := []schema.Server{
+		testServer(1, "fsn-pool"),
+		testServer(2, "fsn-pool"),
+		testServer(3, "nbg-pool"),
+		testServer(4, "other-fsn-pool"),
+		testServer(5, drainingNodePoolId),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: servers})
+	})
+	manager := newTestManager(t, mux)
+	for _, group := range []*hetznerNodeGroup{
+		{id: "fsn-pool", region: "fsn1"},
+		{id: "other-fsn-pool", region: "fsn1"},
+		{id: "nbg-pool", region: "nbg1"},
+		{id: "hel-pool", region: "hel1"},
+		{id: drainingNodePoolId, region: "fsn1"},
+	} {
+		group.manager = manager
+		manager.nodeGroups[group.id] = group
+	}
+	provider := &HetznerCloudProvider{manager: manager}
+
+	counts, err := provider.LocationNodeCounts()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"fsn1": 3, "nbg1": 1, "hel1": 0}, counts)
+
+	option := func(id string) expander.Option {
+		return expander.Option{NodeGroup: manager.nodeGroups[id]}
+	}
+	filter := provider.LocationSpreadFilter()
+	assert.Equal(t, []expander.Option{option("nbg-pool")},
+		filter.BestOptions([]expander.Option{option("fsn-pool"), option("nbg-pool"), option("other-fsn-pool")}, nil))
+	assert.Equal(t, []expander.Option{option("hel-pool")},
+		filter.BestOptions([]expander.Option{option("fsn-pool"), option("hel-pool"), option("nbg-pool")}, nil))
+	assert.Equal(t, []expander.Option{option("fsn-pool"), option("other-fsn-pool")},
+		filter.BestOptions([]expander.Option{option("fsn-pool"), option("other-fsn-pool")}, nil))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"k8s.io/autoscaler/cluster-autoscaler/expander"
+	"k8s.io/klog/v2"
+	schedulerframework "k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+type locationSpreadFilter struct {
+	provider *HetznerCloudProvider
+}
+
+// NewLocationSpreadFilter returns an expander filter preferring scale-up
+// options of node groups in the locations with the fewest servers, so that
+// nodes are spread across locations. Options of node groups not managed by
+// the Hetzner provider are always kept.
+func NewLocationSpreadFilter(provider *HetznerCloudProvider) expander.Filter {
+	return &locationSpreadFilter{provider: provider}
+}
+
+// LocationSpreadFilter returns the filter used by the location-spread expander.
+func (d *HetznerCloudProvider) LocationSpreadFilter() expander.Filter {
+	return NewLocationSpreadFilter(d)
+}
+
+// BestOptions returns the options of node groups in the least populated
+// locations among the given options.
+func (f *locationSpreadFilter) BestOptions(options []expander.Option, _ map[string]*schedulerframework.NodeInfo) []expander.Option {
+	counts, err := f.provider.LocationNodeCounts()
+	if err != nil {
+		klog.Warningf("Failed to count servers per location, not spreading scale-up: %v", err)
+		return options
+	}
+
+	least := -1
+	for _, option := range options {
+		if group, ok := option.NodeGroup.(*hetznerNodeGroup); ok {
+			if count := counts[group.region]; least < 0 || count < least {
+				least = count
+			}
+		}
+	}
+
+	var best []expander.Option
+	for _, option := range options {
+		if group, ok := option.NodeGroup.(*hetznerNodeGroup); ok && counts[group.region] != least {
+			continue
+		}
+		best = append(best, option)
+	}
+	return best
+}
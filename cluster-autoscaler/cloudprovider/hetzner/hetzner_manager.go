@@ -26,12 +26,14 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
 
 	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud"
 	"k8s.io/autoscaler/cluster-autoscaler/version"
+	"k8s.io/klog/v2"
 )
 
 var (
@@ -43,18 +45,35 @@ var (
 // hetznerManager handles Hetzner communication and data caching of
 // node groups
 type hetznerManager struct {
-	client           *hcloud.Client
-	nodeGroups       map[string]*hetznerNodeGroup
-	apiCallContext   context.Context
-	clusterConfig    *ClusterConfig
-	sshKey           *hcloud.SSHKey
-	network          *hcloud.Network
-	firewall         *hcloud.Firewall
-	createTimeout    time.Duration
-	publicIPv4       bool
-	publicIPv6       bool
-	cachedServerType *serverTypeCache
-	cachedServers    *serversCache
+	client *hcloud.Client
+	// nodeGroupsMutex guards nodeGroups, which is read by expanders and written by Create.
+	nodeGroupsMutex sync.RWMutex
+	nodeGroups      map[string]*hetznerNodeGroup
+	apiCallContext  context.Context
+	clusterConfig   *ClusterConfig
+	sshKey          *hcloud.SSHKey
+	network         *hcloud.Network
+	firewall        *hcloud.Firewall
+	createTimeout   time.Duration
+	deleteTimeout   time.Duration
+	// deleteConcurrency is how many servers a node group deletes at once. There's no limit if it's 0.
+	deleteConcurrency int
+	// serverQuota is the maximum number of servers in the project, scale-ups that would exceed it are refused.
+	// It's not checked if it's 0.
+	serverQuota int
+	// readinessCheck makes scale-up wait for new servers to be running, and to accept connections on
+	// readinessPort if it's set, before they count as created.
+	readinessCheck        bool
+	readinessPort         int
+	readinessPollInterval time.Duration
+	publicIPv4            bool
+	publicIPv6            bool
+	cachedServerType      *serverTypeCache
+	cachedServers         *serversCache
+	sshKeysMutex          sync.Mutex
+	cachedSSHKeys         map[string]*hcloud.SSHKey
+	networksMutex         sync.Mutex
+	cachedNetworks        map[string]*hcloud.Network
 }
 
 // ClusterConfig holds the configuration for all the nodepools
@@ -74,8 +93,28 @@ type ImageList struct {
 // NodeConfig holds the configuration for a single nodepool
 type NodeConfig struct {
 	CloudInit string
-	Taints    []apiv1.Taint
-	Labels    map[string]string
+	// Image overrides ImagesForArch for this nodepool. It has the same format as HCLOUD_IMAGE.
+	Image string
+	// SSHKeys holds the IDs or names of SSH keys attached to servers of this nodepool, in addition to HCLOUD_SSH_KEY.
+	SSHKeys []string
+	// PublicIPv4 and PublicIPv6 override HCLOUD_PUBLIC_IPV4 and HCLOUD_PUBLIC_IPV6 for this nodepool.
+	PublicIPv4 *bool
+	PublicIPv6 *bool
+	// PrimaryIPv4 and PrimaryIPv6 hold the ID or name of an existing primary IP to attach to new servers instead
+	// of an automatically created one. A primary IP can only be assigned to one server at a time.
+	PrimaryIPv4 string
+	PrimaryIPv6 string
+	// Network holds the ID or name of the private network servers of this nodepool are attached to, overriding
+	// HCLOUD_NETWORK.
+	Network string
+	// FallbackLocations are tried in order when creating a server in the nodepool's location fails because the
+	// location has no capacity left for its server type.
+	FallbackLocations []string
+	// ServerLabels are added to the Hetzner Cloud labels of servers created for this nodepool, e.g. for cost
+	// tracking. Keys in the hcloud/ namespace are reserved for autodiscovery and ignored.
+	ServerLabels map[string]string
+	Taints       []apiv1.Taint
+	Labels       map[string]string
 }
 
 // LegacyConfig holds the configuration in the legacy format
@@ -86,13 +125,22 @@ type LegacyConfig struct {
 
 func newManager() (*hetznerManager, error) {
 	token := os.Getenv("HCLOUD_TOKEN")
+	apiHTTPClient := httpClient
+	if tokenFile := os.Getenv("HCLOUD_TOKEN_FILE"); tokenFile != "" {
+		source := fileTokenSource(tokenFile)
+		var err error
+		if token, err = source(); err != nil {
+			return nil, err
+		}
+		apiHTTPClient = &http.Client{Transport: tokenRoundTripper(source, httpClient.Transport)}
+	}
 	if token == "" {
-		return nil, errors.New("`HCLOUD_TOKEN` is not specified")
+		return nil, errors.New("`HCLOUD_TOKEN` or `HCLOUD_TOKEN_FILE` is not specified")
 	}
 
 	client := hcloud.NewClient(
 		hcloud.WithToken(token),
-		hcloud.WithHTTPClient(httpClient),
+		hcloud.WithHTTPClient(apiHTTPClient),
 		hcloud.WithApplication("cluster-autoscaler", version.ClusterAutoscalerVersion),
 		hcloud.WithPollBackoffFunc(hcloud.ExponentialBackoff(2, 500*time.Millisecond)),
 		hcloud.WithDebugWriter(&debugWriter{}),
@@ -182,6 +230,45 @@ func newManager() (*hetznerManager, error) {
 		createTimeout = time.Duration(v) * time.Minute
 	}
 
+	deleteTimeout := serverDeleteTimeoutDefault
+	v, err = strconv.Atoi(os.Getenv("HCLOUD_SERVER_DELETION_TIMEOUT"))
+	if err == nil && v != 0 {
+		deleteTimeout = time.Duration(v) * time.Minute
+	}
+
+	readinessCheck := false
+	if readinessCheckStr := os.Getenv("HCLOUD_SERVER_READINESS_CHECK"); readinessCheckStr != "" {
+		readinessCheck, err = strconv.ParseBool(readinessCheckStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HCLOUD_SERVER_READINESS_CHECK: %s", err)
+		}
+	}
+
+	readinessPort := 0
+	if readinessPortStr := os.Getenv("HCLOUD_SERVER_READINESS_PORT"); readinessPortStr != "" {
+		readinessPort, err = strconv.Atoi(readinessPortStr)
+		if err != nil || readinessPort <= 0 || readinessPort > 65535 {
+			return nil, fmt.Errorf("failed to parse HCLOUD_SERVER_READINESS_PORT: invalid port %q", readinessPortStr)
+		}
+		readinessCheck = true
+	}
+
+	deleteConcurrency := serverDeleteConcurrencyDefault
+	if deleteConcurrencyStr := os.Getenv("HCLOUD_SERVER_DELETION_CONCURRENCY"); deleteConcurrencyStr != "" {
+		deleteConcurrency, err = strconv.Atoi(deleteConcurrencyStr)
+		if err != nil || deleteConcurrency < 0 {
+			return nil, fmt.Errorf("failed to parse HCLOUD_SERVER_DELETION_CONCURRENCY: invalid concurrency %q", deleteConcurrencyStr)
+		}
+	}
+
+	serverQuota := 0
+	if serverQuotaStr := os.Getenv("HCLOUD_SERVER_QUOTA"); serverQuotaStr != "" {
+		serverQuota, err = strconv.Atoi(serverQuotaStr)
+		if err != nil || serverQuota < 0 {
+			return nil, fmt.Errorf("failed to parse HCLOUD_SERVER_QUOTA: invalid quota %q", serverQuotaStr)
+		}
+	}
+
 	var firewall *hcloud.Firewall
 	firewallIdOrName := os.Getenv("HCLOUD_FIREWALL")
 	if firewallIdOrName != "" {
@@ -192,18 +279,24 @@ func newManager() (*hetznerManager, error) {
 	}
 
 	m := &hetznerManager{
-		client:           client,
-		nodeGroups:       make(map[string]*hetznerNodeGroup),
-		sshKey:           sshKey,
-		network:          network,
-		firewall:         firewall,
-		createTimeout:    createTimeout,
-		apiCallContext:   ctx,
-		publicIPv4:       publicIPv4,
-		publicIPv6:       publicIPv6,
-		clusterConfig:    clusterConfig,
-		cachedServerType: newServerTypeCache(ctx, client),
-		cachedServers:    newServersCache(ctx, client),
+		client:                client,
+		nodeGroups:            make(map[string]*hetznerNodeGroup),
+		sshKey:                sshKey,
+		network:               network,
+		firewall:              firewall,
+		createTimeout:         createTimeout,
+		deleteTimeout:         deleteTimeout,
+		deleteConcurrency:     deleteConcurrency,
+		readinessCheck:        readinessCheck,
+		readinessPort:         readinessPort,
+		readinessPollInterval: serverReadyPollInterval,
+		serverQuota:           serverQuota,
+		apiCallContext:        ctx,
+		publicIPv4:            publicIPv4,
+		publicIPv6:            publicIPv6,
+		clusterConfig:         clusterConfig,
+		cachedServerType:      newServerTypeCache(ctx, client),
+		cachedServers:         newServersCache(ctx, client),
 	}
 
 	m.nodeGroups[drainingNodePoolId] = &hetznerNodeGroup{
@@ -234,27 +327,251 @@ func (m *hetznerManager) allServers(nodeGroup string) ([]*hcloud.Server, error)
 	return servers, nil
 }
 
-func (m *hetznerManager) deleteByNode(node *apiv1.Node) error {
-	server, err := m.serverForNode(node)
+func (m *hetznerManager) deleteServer(server *hcloud.Server) error {
+	if err := m.releasePrimaryIPs(server); err != nil {
+		return err
+	}
+	if err := m.detachVolumes(server); err != nil {
+		return err
+	}
+	result, _, err := m.client.Server.DeleteWithResult(m.apiCallContext, server)
 	if err != nil {
-		return fmt.Errorf("failed to delete node %s error: %v", node.Name, err)
+		return err
+	}
+	// Wait for the server to be gone, so that it doesn't count towards capacity or quota anymore once the
+	// deletion is reported.
+	ctx, cancel := context.WithTimeout(m.apiCallContext, m.deleteTimeout)
+	defer cancel()
+	if err := m.client.Action.WaitFor(ctx, result.Action); err != nil {
+		return fmt.Errorf("failed to wait for server %s to be deleted: %v", server.Name, err)
+	}
+	return nil
+}
+
+// detachVolumes detaches the volumes attached to the server and waits for the detachment to finish, so that
+// deleting the server doesn't leave them in an inconsistent state. Volumes already detached are skipped.
+func (m *hetznerManager) detachVolumes(server *hcloud.Server) error {
+	for _, attached := range server.Volumes {
+		volume, _, err := m.client.Volume.GetByID(m.apiCallContext, attached.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get volume %d of server %s error: %v", attached.ID, server.Name, err)
+		}
+		if volume == nil || volume.Server == nil || volume.Server.ID != server.ID {
+			continue
+		}
+		klog.Infof("Detaching volume %s (ID %d) from server %s", volume.Name, volume.ID, server.Name)
+		action, _, err := m.client.Volume.Detach(m.apiCallContext, volume)
+		if err == nil {
+			err = m.client.Action.WaitFor(m.apiCallContext, action)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to detach volume %s (ID %d) from server %s, it may still be in use: %v", volume.Name, volume.ID, server.Name, err)
+		}
 	}
+	return nil
+}
 
-	if server == nil {
-		return fmt.Errorf("failed to delete node %s server not found", node.Name)
+// labelCreatedPrimaryIPs labels the primary IPs that were created together with the server, i.e. those not
+// given in publicNet, so that releasePrimaryIPs can tell them apart from primary IPs owned by the user.
+func (m *hetznerManager) labelCreatedPrimaryIPs(server *hcloud.Server, publicNet *hcloud.ServerCreatePublicNet) error {
+	var ids []int64
+	if publicNet.IPv4 == nil && server.PublicNet.IPv4.ID != 0 {
+		ids = append(ids, server.PublicNet.IPv4.ID)
+	}
+	if publicNet.IPv6 == nil && server.PublicNet.IPv6.ID != 0 {
+		ids = append(ids, server.PublicNet.IPv6.ID)
+	}
+	for _, id := range ids {
+		labels := map[string]string{primaryIPCreatedLabel: "true"}
+		if _, _, err := m.client.PrimaryIP.Update(m.apiCallContext, &hcloud.PrimaryIP{ID: id}, hcloud.PrimaryIPUpdateOpts{Labels: &labels}); err != nil {
+			return fmt.Errorf("failed to label primary ip %d of server %s error: %v", id, server.Name, err)
+		}
 	}
+	return nil
+}
 
-	return m.deleteServer(server)
+// releasePrimaryIPs makes sure the primary IPs created together with the server are deleted with it, so that
+// they don't leak after scale-down. Only primary IPs labeled by labelCreatedPrimaryIPs are released, all
+// others are owned by the user and left untouched.
+func (m *hetznerManager) releasePrimaryIPs(server *hcloud.Server) error {
+	for _, id := range []int64{server.PublicNet.IPv4.ID, server.PublicNet.IPv6.ID} {
+		if id == 0 {
+			continue
+		}
+		primaryIP, _, err := m.client.PrimaryIP.GetByID(m.apiCallContext, id)
+		if err != nil {
+			return fmt.Errorf("failed to get primary ip %d of server %s error: %v", id, server.Name, err)
+		}
+		if primaryIP == nil || primaryIP.AutoDelete || primaryIP.Labels[primaryIPCreatedLabel] != "true" {
+			continue
+		}
+		if _, _, err := m.client.PrimaryIP.Update(m.apiCallContext, primaryIP, hcloud.PrimaryIPUpdateOpts{AutoDelete: hcloud.Ptr(true)}); err != nil {
+			return fmt.Errorf("failed to enable auto delete of primary ip %d of server %s error: %v", id, server.Name, err)
+		}
+	}
+	return nil
 }
 
-func (m *hetznerManager) deleteServer(server *hcloud.Server) error {
-	_, err := m.client.Server.Delete(m.apiCallContext, server)
-	return err
+// networkForNodeGroup returns the private network new servers of the node group are attached to, or nil if
+// there is none. Configured network IDs and names are resolved once and cached.
+func (m *hetznerManager) networkForNodeGroup(nodeGroup string) (*hcloud.Network, error) {
+	nodeConfig, ok := m.clusterConfig.NodeConfigs[nodeGroup]
+	if !ok || nodeConfig.Network == "" {
+		return m.network, nil
+	}
+
+	m.networksMutex.Lock()
+	defer m.networksMutex.Unlock()
+	if network, found := m.cachedNetworks[nodeConfig.Network]; found {
+		return network, nil
+	}
+	network, _, err := m.client.Network.Get(m.apiCallContext, nodeConfig.Network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network %s error: %v", nodeConfig.Network, err)
+	}
+	if network == nil {
+		return nil, fmt.Errorf("network %s not found", nodeConfig.Network)
+	}
+	if m.cachedNetworks == nil {
+		m.cachedNetworks = make(map[string]*hcloud.Network)
+	}
+	m.cachedNetworks[nodeConfig.Network] = network
+	return network, nil
+}
+
+// publicNetForNodeGroup returns the public network configuration of new servers of the node group.
+func (m *hetznerManager) publicNetForNodeGroup(nodeGroup string) (*hcloud.ServerCreatePublicNet, error) {
+	publicNet := &hcloud.ServerCreatePublicNet{
+		EnableIPv4: m.publicIPv4,
+		EnableIPv6: m.publicIPv6,
+	}
+	nodeConfig, ok := m.clusterConfig.NodeConfigs[nodeGroup]
+	if !ok {
+		return publicNet, nil
+	}
+	if nodeConfig.PublicIPv4 != nil {
+		publicNet.EnableIPv4 = *nodeConfig.PublicIPv4
+	}
+	if nodeConfig.PublicIPv6 != nil {
+		publicNet.EnableIPv6 = *nodeConfig.PublicIPv6
+	}
+
+	var err error
+	if nodeConfig.PrimaryIPv4 != "" {
+		if publicNet.IPv4, err = m.primaryIP(nodeConfig.PrimaryIPv4); err != nil {
+			return nil, err
+		}
+		publicNet.EnableIPv4 = true
+	}
+	if nodeConfig.PrimaryIPv6 != "" {
+		if publicNet.IPv6, err = m.primaryIP(nodeConfig.PrimaryIPv6); err != nil {
+			return nil, err
+		}
+		publicNet.EnableIPv6 = true
+	}
+	return publicNet, nil
+}
+
+// checkServerQuota returns an error if creating count more servers would exceed the server quota of the project.
+// The current usage is fetched from the API rather than the cache, as other clients may have created servers since.
+func (m *hetznerManager) checkServerQuota(count int) error {
+	if m.serverQuota == 0 {
+		return nil
+	}
+	servers, err := m.cachedServers.servers()
+	if err != nil {
+		return fmt.Errorf("failed to get servers to check quota error: %v", err)
+	}
+	if len(servers)+count > m.serverQuota {
+		return fmt.Errorf("creating %d servers would exceed quota: %d of %d servers in use", count, len(servers), m.serverQuota)
+	}
+	return nil
+}
+
+// hasPinnedPrimaryIP returns whether new servers of the node group are created with a configured primary IP.
+// As a primary IP can only be assigned to one server at a time, such node groups can't have more than one server.
+func (m *hetznerManager) hasPinnedPrimaryIP(nodeGroup string) bool {
+	nodeConfig, ok := m.clusterConfig.NodeConfigs[nodeGroup]
+	return ok && (nodeConfig.PrimaryIPv4 != "" || nodeConfig.PrimaryIPv6 != "")
+}
+
+func (m *hetznerManager) primaryIP(idOrName string) (*hcloud.PrimaryIP, error) {
+	primaryIP, _, err := m.client.PrimaryIP.Get(m.apiCallContext, idOrName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get primary ip %s error: %v", idOrName, err)
+	}
+	if primaryIP == nil {
+		return nil, fmt.Errorf("primary ip %s not found", idOrName)
+	}
+	return primaryIP, nil
+}
+
+// sshKeysForNodeGroup returns the SSH keys to attach to new servers of the node group. Configured
+// key IDs and names are resolved once and cached.
+func (m *hetznerManager) sshKeysForNodeGroup(nodeGroup string) ([]*hcloud.SSHKey, error) {
+	var sshKeys []*hcloud.SSHKey
+	if m.sshKey != nil {
+		sshKeys = append(sshKeys, m.sshKey)
+	}
+	nodeConfig, ok := m.clusterConfig.NodeConfigs[nodeGroup]
+	if !ok || len(nodeConfig.SSHKeys) == 0 {
+		return sshKeys, nil
+	}
+
+	m.sshKeysMutex.Lock()
+	defer m.sshKeysMutex.Unlock()
+	if m.cachedSSHKeys == nil {
+		m.cachedSSHKeys = make(map[string]*hcloud.SSHKey)
+	}
+	for _, idOrName := range nodeConfig.SSHKeys {
+		sshKey, found := m.cachedSSHKeys[idOrName]
+		if !found {
+			var err error
+			sshKey, _, err = m.client.SSHKey.Get(m.apiCallContext, idOrName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get ssh key %s error: %v", idOrName, err)
+			}
+			if sshKey == nil {
+				return nil, fmt.Errorf("ssh key %s not found", idOrName)
+			}
+			m.cachedSSHKeys[idOrName] = sshKey
+		}
+		if m.sshKey == nil || sshKey.ID != m.sshKey.ID {
+			sshKeys = append(sshKeys, sshKey)
+		}
+	}
+	return sshKeys, nil
 }
 
 func (m *hetznerManager) addNodeToDrainingPool(node *apiv1.Node) (*hetznerNodeGroup, error) {
-	m.nodeGroups[drainingNodePoolId].targetSize += 1
-	return m.nodeGroups[drainingNodePoolId], nil
+	drainingPool, _ := m.nodeGroup(drainingNodePoolId)
+	drainingPool.targetSize += 1
+	return drainingPool, nil
+}
+
+// nodeGroup returns the node group with the given id, false if there is none.
+func (m *hetznerManager) nodeGroup(id string) (*hetznerNodeGroup, bool) {
+	m.nodeGroupsMutex.RLock()
+	defer m.nodeGroupsMutex.RUnlock()
+	group, ok := m.nodeGroups[id]
+	return group, ok
+}
+
+// allNodeGroups returns a snapshot of the node groups, including the draining pool.
+func (m *hetznerManager) allNodeGroups() []*hetznerNodeGroup {
+	m.nodeGroupsMutex.RLock()
+	defer m.nodeGroupsMutex.RUnlock()
+	groups := make([]*hetznerNodeGroup, 0, len(m.nodeGroups))
+	for _, group := range m.nodeGroups {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (m *hetznerManager) addNodeGroup(group *hetznerNodeGroup) {
+	m.nodeGroupsMutex.Lock()
+	defer m.nodeGroupsMutex.Unlock()
+	m.nodeGroups[group.id] = group
 }
 
 func (m *hetznerManager) validProviderID(providerID string) bool {
@@ -17,17 +17,24 @@ limitations under the License.
 package hetzner
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud/schema"
 	k8smetrics "k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
 )
 
 const subsystemIdentifier = "api"
@@ -55,16 +62,50 @@ func instrumentedRoundTripper() http.RoundTripper {
 		[]string{"method"},
 	)
 
+	operationLatencyHistogram := k8smetrics.NewHistogramVec(
+		&k8smetrics.HistogramOpts{
+			Name:    fmt.Sprintf("hcloud_%s_operation_duration_seconds", subsystemIdentifier),
+			Help:    fmt.Sprintf("A histogram of request latencies to the hcloud %s per operation.", subsystemIdentifier),
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+
+	responsesPerErrorCodeCounter := k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Name: fmt.Sprintf("hcloud_%s_responses_total", subsystemIdentifier),
+			Help: fmt.Sprintf("A counter for responses from the hcloud %s per error code, \"ok\" for successful responses.", subsystemIdentifier),
+		},
+		[]string{"error_code"},
+	)
+
+	deprecatedEndpointCounter := k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Name: fmt.Sprintf("hcloud_%s_deprecated_endpoint_responses_total", subsystemIdentifier),
+			Help: fmt.Sprintf("A counter for responses from the hcloud %s reporting a deprecated endpoint.", subsystemIdentifier),
+		},
+		[]string{"api_endpoint"},
+	)
+
 	legacyregistry.MustRegister(requestsPerEndpointCounter)
 	legacyregistry.MustRegister(requestLatencyHistogram)
 	legacyregistry.MustRegister(inFlightRequestsGauge)
+	legacyregistry.MustRegister(deprecatedEndpointCounter)
+	legacyregistry.MustRegister(operationLatencyHistogram)
+	legacyregistry.MustRegister(responsesPerErrorCodeCounter)
 
-	return instrumentRoundTripperInFlight(inFlightRequestsGauge,
-		instrumentRoundTripperDuration(requestLatencyHistogram,
-			instrumentRoundTripperEndpoint(requestsPerEndpointCounter,
-				http.DefaultTransport,
+	return observeResponseErrors(
+		instrumentRoundTripperInFlight(inFlightRequestsGauge,
+			instrumentRoundTripperDuration(requestLatencyHistogram,
+				instrumentRoundTripperOperationDuration(operationLatencyHistogram,
+					instrumentRoundTripperEndpoint(requestsPerEndpointCounter,
+						http.DefaultTransport,
+					),
+				),
 			),
 		),
+		errorCodeObserver(responsesPerErrorCodeCounter),
+		newDeprecationWarner(deprecatedEndpointCounter).observe,
 	)
 }
 
@@ -105,6 +146,129 @@ func instrumentRoundTripperEndpoint(counter *k8smetrics.CounterVec, next http.Ro
 	}
 }
 
+func instrumentRoundTripperOperationDuration(obs *k8smetrics.HistogramVec, next http.RoundTripper) roundTripperFunc {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(r)
+		if err == nil {
+			obs.WithLabelValues(operationLabel(resp.Request)).Observe(time.Since(start).Seconds())
+		}
+		return resp, err
+	})
+}
+
+// responseErrorObserver is notified of each response along with its decoded top-level error, which is empty if
+// there's none.
+type responseErrorObserver func(resp *http.Response, apiErr schema.Error)
+
+// observeResponseErrors decodes the error of each response once and passes it to the observers. It reads the response
+// body, so it wraps the other instrumentations to keep the body download out of the request latencies.
+func observeResponseErrors(next http.RoundTripper, observers ...responseErrorObserver) roundTripperFunc {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+
+		apiErr := decodeResponseError(resp)
+		for _, observe := range observers {
+			observe(resp, apiErr)
+		}
+		return resp, nil
+	})
+}
+
+// errorCodeObserver counts responses by their error code, "ok" for successful responses and "unknown" for failed
+// responses whose error can't be decoded.
+func errorCodeObserver(counter *k8smetrics.CounterVec) responseErrorObserver {
+	return func(resp *http.Response, apiErr schema.Error) {
+		if resp.StatusCode < http.StatusBadRequest {
+			counter.WithLabelValues("ok").Inc()
+			return
+		}
+		code := "unknown"
+		if apiErr.Code != "" {
+			code = apiErr.Code
+		}
+		counter.WithLabelValues(code).Inc()
+	}
+}
+
+// responseErrorBody is the top-level error key of an API response. Other keys are skipped when decoding.
+type responseErrorBody struct {
+	Error schema.Error `json:"error"`
+}
+
+// decodeResponseError reads the body of a JSON response and decodes its top-level error, if any. The body is replaced
+// so that it can be read again by the hcloud client. If reading it fails, the client gets the read error instead, and
+// an empty error is returned.
+func decodeResponseError(resp *http.Response) schema.Error {
+	if resp.Body == nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return schema.Error{}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err: err}))
+		return schema.Error{}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var respBody responseErrorBody
+	// The code is decoded even if the error details are malformed.
+	_ = json.Unmarshal(body, &respBody)
+	return respBody.Error
+}
+
+// errReader fails every read with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// operationLabel identifies the API operation of a request, e.g. "get /servers".
+func operationLabel(r *http.Request) string {
+	return strings.ToLower(r.Method) + " " + preparePathForLabel(r.URL.Path)
+}
+
+// deprecationWarner inspects API responses for the deprecated_api_endpoint
+// error code. It logs the announcement once per endpoint and counts every
+// such response. The response itself is passed through untouched.
+type deprecationWarner struct {
+	counter *k8smetrics.CounterVec
+	warned  sync.Map
+	warnf   func(format string, args ...interface{})
+}
+
+func newDeprecationWarner(counter *k8smetrics.CounterVec) *deprecationWarner {
+	return &deprecationWarner{
+		counter: counter,
+		warnf:   klog.Warningf,
+	}
+}
+
+func (d *deprecationWarner) observe(resp *http.Response, apiErr schema.Error) {
+	if hcloud.ErrorCode(apiErr.Code) != hcloud.ErrorCodeDeprecatedAPIEndpoint {
+		return
+	}
+
+	endpoint := preparePathForLabel(resp.Request.URL.Path)
+	d.counter.WithLabelValues(endpoint).Inc()
+	if _, alreadyWarned := d.warned.LoadOrStore(endpoint, struct{}{}); alreadyWarned {
+		return
+	}
+
+	announcement := apiErr.Message
+	if details, ok := apiErr.Details.(schema.ErrorDetailsDeprecatedAPIEndpoint); ok && details.Announcement != "" {
+		announcement = details.Announcement
+	}
+	d.warnf("hcloud API endpoint %s is deprecated: %s", endpoint, announcement)
+}
+
 func preparePathForLabel(path string) string {
 	path = strings.ToLower(path)
 
@@ -0,0 +1,140 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud/schema"
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func TestDeprecationWarner(t *testing.T) {
+	const body = `{"servers": [], "error": {"code": "deprecated_api_endpoint", "message": "deprecated", "details": {"announcement": "https://docs.hetzner.cloud/changelog#example"}}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	counter := k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{Name: "test_deprecated_endpoint_responses_total"}, []string{"api_endpoint"})
+	k8smetrics.NewKubeRegistry().MustRegister(counter)
+
+	var warnings []string
+	warner := newDeprecationWarner(counter)
+	warner.warnf = func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+	client := &http.Client{Transport: observeResponseErrors(http.DefaultTransport, warner.observe)}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL + "/v1/servers")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The body must still be readable by the hcloud client.
+		got, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, body, string(got))
+	}
+
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "/servers")
+	assert.Contains(t, warnings[0], "https://docs.hetzner.cloud/changelog#example")
+
+	count, err := testutil.GetCounterMetricValue(counter.WithLabelValues("/servers"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), count)
+}
+
+func TestObserveResponseErrorsBodyReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusUnprocessableEntity,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader(`{"error": {"code": "invalid_inp`), errReader{err: readErr})),
+			Request:    r,
+		}, nil
+	})
+
+	counter := k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{Name: "test_read_error_responses_total"}, []string{"error_code"})
+	k8smetrics.NewKubeRegistry().MustRegister(counter)
+	decodes := 0
+	client := &http.Client{Transport: observeResponseErrors(transport, errorCodeObserver(counter), func(*http.Response, schema.Error) {
+		decodes++
+	})}
+
+	// The round trip itself succeeds, the read error is left to the client reading the body.
+	resp, err := client.Post("http://hcloud.invalid/v1/servers", "application/json", nil)
+	require.NoError(t, err)
+	got, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.ErrorIs(t, err, readErr)
+	assert.Equal(t, `{"error": {"code": "invalid_inp`, string(got))
+
+	count, err := testutil.GetCounterMetricValue(counter.WithLabelValues("unknown"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), count)
+	assert.Equal(t, 1, decodes)
+}
+
+func TestInstrumentRoundTripperErrorCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `{"servers": []}`)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = io.WriteString(w, `{"error": {"code": "invalid_input", "message": "invalid input in field 'name'", "details": {"fields": [{"name": "name", "messages": ["is invalid"]}]}}}`)
+	}))
+	defer server.Close()
+
+	counter := k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{Name: "test_responses_total"}, []string{"error_code"})
+	k8smetrics.NewKubeRegistry().MustRegister(counter)
+	client := &http.Client{Transport: observeResponseErrors(http.DefaultTransport, errorCodeObserver(counter))}
+
+	resp, err := client.Post(server.URL+"/v1/servers", "application/json", nil)
+	require.NoError(t, err)
+	got, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Contains(t, string(got), "invalid_input")
+
+	resp, err = client.Get(server.URL + "/v1/servers")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	count, err := testutil.GetCounterMetricValue(counter.WithLabelValues("invalid_input"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), count)
+	count, err = testutil.GetCounterMetricValue(counter.WithLabelValues("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), count)
+}
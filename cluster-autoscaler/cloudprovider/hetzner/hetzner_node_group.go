@@ -18,11 +18,16 @@ package hetzner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"math/rand"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -47,6 +52,20 @@ type hetznerNodeGroup struct {
 	instanceType string
 
 	clusterUpdateMutex *sync.Mutex
+
+	// lastErrorMutex guards lastError and failedCreates.
+	lastErrorMutex sync.Mutex
+	lastError      *cloudprovider.InstanceErrorInfo
+	// failedCreates holds placeholder instances of servers that couldn't be created. They're reported by
+	// Nodes() with the create error, so that the scale-up is backed off, until they're passed to DeleteNodes
+	// or the next IncreaseSize. They're counted in targetSize, like the servers that were created.
+	failedCreates     []cloudprovider.Instance
+	failedCreateCount int
+
+	// otherServerTypes holds the server types of servers besides instanceType
+	// seen by the last Nodes() call.
+	otherServerTypesMutex sync.Mutex
+	otherServerTypes      []string
 }
 
 type hetznerNodeGroupSpec struct {
@@ -67,6 +86,122 @@ func (n *hetznerNodeGroup) MinSize() int {
 	return n.minSize
 }
 
+// LastError returns the last error returned by the Hetzner API while creating
+// or deleting servers of the node group, nil if there was none or the last
+// create or delete succeeded.
+func (n *hetznerNodeGroup) LastError() *cloudprovider.InstanceErrorInfo {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+	return n.lastError
+}
+
+// recordError stores err as the last error of the node group.
+func (n *hetznerNodeGroup) recordError(err error) {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+	n.lastError = toInstanceErrorInfo(err)
+}
+
+// locations returns the node group's location followed by its fallback
+// locations, in the order servers are created in.
+func (n *hetznerNodeGroup) locations() []string {
+	locations := []string{n.region}
+	if nodeConfig, ok := n.manager.clusterConfig.NodeConfigs[n.id]; ok {
+		for _, location := range nodeConfig.FallbackLocations {
+			if location = strings.ToLower(location); location != n.region {
+				locations = append(locations, location)
+			}
+		}
+	}
+	return locations
+}
+
+// recordFailedCreate stores err as the last error of the node group and adds a
+// placeholder instance for the server that couldn't be created.
+func (n *hetznerNodeGroup) recordFailedCreate(err error) {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+	n.lastError = toInstanceErrorInfo(err)
+	n.failedCreateCount++
+	n.failedCreates = append(n.failedCreates, cloudprovider.Instance{
+		Id: fmt.Sprintf("%s%s/%d", failedCreateIDPrefix, n.id, n.failedCreateCount),
+		Status: &cloudprovider.InstanceStatus{
+			State:     cloudprovider.InstanceCreating,
+			ErrorInfo: n.lastError,
+		},
+	})
+}
+
+// resetFailedCreates clears the last error and drops the placeholder instances
+// of earlier failed creates. It returns the number of dropped placeholders.
+func (n *hetznerNodeGroup) resetFailedCreates() int {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+	dropped := len(n.failedCreates)
+	n.lastError = nil
+	n.failedCreates = nil
+	return dropped
+}
+
+// removeFailedCreates drops the placeholder instances of the given failed
+// creates and returns the remaining nodes and the number of dropped
+// placeholders.
+func (n *hetznerNodeGroup) removeFailedCreates(nodes []*apiv1.Node) ([]*apiv1.Node, int) {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+
+	remaining := make([]*apiv1.Node, 0, len(nodes))
+	dropped := 0
+	for _, node := range nodes {
+		if _, ok := failedCreateNodeGroup(node.Spec.ProviderID); !ok {
+			remaining = append(remaining, node)
+			continue
+		}
+		before := len(n.failedCreates)
+		n.failedCreates = slices.DeleteFunc(n.failedCreates, func(instance cloudprovider.Instance) bool {
+			return instance.Id == node.Spec.ProviderID
+		})
+		dropped += before - len(n.failedCreates)
+	}
+	return remaining, dropped
+}
+
+// failedCreatesCount returns the number of placeholder instances of failed
+// creates.
+func (n *hetznerNodeGroup) failedCreatesCount() int {
+	n.lastErrorMutex.Lock()
+	defer n.lastErrorMutex.Unlock()
+	return len(n.failedCreates)
+}
+
+// toInstanceErrorInfo converts err to the error info reported to CA. The code
+// and message of Hetzner API errors are kept as returned by the API.
+func toInstanceErrorInfo(err error) *cloudprovider.InstanceErrorInfo {
+	info := &cloudprovider.InstanceErrorInfo{
+		ErrorClass:   cloudprovider.OtherErrorClass,
+		ErrorMessage: err.Error(),
+	}
+	var apiErr hcloud.Error
+	if errors.As(err, &apiErr) {
+		info.ErrorCode = string(apiErr.Code)
+		info.ErrorMessage = apiErr.Message
+		if apiErr.Code == hcloud.ErrorCodeResourceUnavailable || apiErr.Code == hcloud.ErrorCodeResourceLimitExceeded {
+			info.ErrorClass = cloudprovider.OutOfResourcesErrorClass
+		}
+	}
+	return info
+}
+
+// failedCreateNodeGroup returns the node group of a failed create placeholder
+// instance, false if providerID isn't one.
+func failedCreateNodeGroup(providerID string) (string, bool) {
+	if !strings.HasPrefix(providerID, failedCreateIDPrefix) {
+		return "", false
+	}
+	nodeGroup, _, ok := strings.Cut(strings.TrimPrefix(providerID, failedCreateIDPrefix), "/")
+	return nodeGroup, ok
+}
+
 // GetOptions returns NodeGroupAutoscalingOptions that should be used for this particular
 // NodeGroup. Returning a nil will result in using default options.
 func (n *hetznerNodeGroup) GetOptions(defaults config.NodeGroupAutoscalingOptions) (*config.NodeGroupAutoscalingOptions, error) {
@@ -85,51 +220,111 @@ func (n *hetznerNodeGroup) TargetSize() (int, error) {
 // IncreaseSize increases the size of the node group. To delete a node you need
 // to explicitly name it and use DeleteNode. This function should wait until
 // node group size is updated. Implementation required.
+//
+// Servers that couldn't be created don't fail the call. The target size is
+// increased by delta and each failed server is reported by Nodes() as a
+// placeholder instance carrying the create error, which CA backs off from and
+// deletes. Placeholders left from an earlier call are dropped, together with
+// the last error, when the next call starts.
 func (n *hetznerNodeGroup) IncreaseSize(delta int) error {
 	if delta <= 0 {
 		return fmt.Errorf("delta must be positive, have: %d", delta)
 	}
 
+	n.targetSize -= n.resetFailedCreates()
+
 	targetSize := n.targetSize + delta
 	if targetSize > n.MaxSize() {
 		return fmt.Errorf("size increase is too large. current: %d desired: %d max: %d", n.targetSize, targetSize, n.MaxSize())
 	}
+	if targetSize > 1 && n.manager.hasPinnedPrimaryIP(n.id) {
+		return fmt.Errorf("node group %s has a primary ip configured and can't have more than one server, desired: %d", n.id, targetSize)
+	}
 
 	klog.V(4).Infof("Scaling Instance Pool %s to %d", n.id, targetSize)
 
 	n.clusterUpdateMutex.Lock()
 	defer n.clusterUpdateMutex.Unlock()
 
-	available, err := serverTypeAvailable(n.manager, n.instanceType, n.region)
-	if err != nil {
-		return fmt.Errorf("failed to check if type %s is available in region %s error: %v", n.instanceType, n.region, err)
+	if err := n.manager.checkServerQuota(delta); err != nil {
+		return err
+	}
+
+	available := false
+	for _, location := range n.locations() {
+		var err error
+		available, err = serverTypeAvailable(n.manager, n.instanceType, location)
+		if err != nil {
+			return fmt.Errorf("failed to check if type %s is available in region %s error: %v", n.instanceType, location, err)
+		}
+		if available {
+			break
+		}
 	}
 	if !available {
-		return fmt.Errorf("server type %s not available in region %s", n.instanceType, n.region)
+		return fmt.Errorf("server type %s not available in region %s", n.instanceType, strings.Join(n.locations(), ", "))
+	}
+
+	serverType, err := n.manager.cachedServerType.getServerType(n.instanceType)
+	if err != nil {
+		return fmt.Errorf("failed to get server type %s error: %v", n.instanceType, err)
+	}
+	image, err := findImage(n, serverType)
+	if err != nil {
+		return fmt.Errorf("failed to find image for node group %s error: %v", n.id, err)
+	}
+	sshKeys, err := n.manager.sshKeysForNodeGroup(n.id)
+	if err != nil {
+		return fmt.Errorf("failed to get ssh keys for node group %s error: %v", n.id, err)
+	}
+	publicNet, err := n.manager.publicNetForNodeGroup(n.id)
+	if err != nil {
+		return fmt.Errorf("failed to get public network config for node group %s error: %v", n.id, err)
+	}
+	network, err := n.manager.networkForNodeGroup(n.id)
+	if err != nil {
+		return fmt.Errorf("failed to get network for node group %s error: %v", n.id, err)
+	}
+
+	created, err := createServers(n, delta, serverType, image, sshKeys, publicNet, network)
+	n.targetSize += delta
+	if err != nil {
+		klog.Warningf("Created %d of %d servers for node group %s, the others are reported as failed creates: %v", created, delta, n.id, err)
 	}
 
+	// create new servers cache
+	if _, err := n.manager.cachedServers.servers(); err != nil {
+		klog.Errorf("failed to get servers: %v", err)
+	}
+
+	return nil
+}
+
+// createServers creates count servers in parallel and returns how many of them
+// were created, along with the errors of the others. Servers that were created
+// are kept even if others fail.
+func createServers(n *hetznerNodeGroup, count int, serverType *hcloud.ServerType, image *hcloud.Image, sshKeys []*hcloud.SSHKey, publicNet *hcloud.ServerCreatePublicNet, network *hcloud.Network) (int, error) {
 	waitGroup := sync.WaitGroup{}
-	for i := 0; i < delta; i++ {
+	mutex := sync.Mutex{}
+	created := 0
+	var errs []error
+	for i := 0; i < count; i++ {
 		waitGroup.Add(1)
 		go func() {
 			defer waitGroup.Done()
-			err := createServer(n)
+			err := createServer(n, serverType, image, sshKeys, publicNet, network)
+			mutex.Lock()
+			defer mutex.Unlock()
 			if err != nil {
-				targetSize--
 				klog.Errorf("failed to create error: %v", err)
+				errs = append(errs, err)
+				return
 			}
+			created++
 		}()
 	}
 	waitGroup.Wait()
-
-	n.targetSize = targetSize
-
-	// create new servers cache
-	if _, err := n.manager.cachedServers.servers(); err != nil {
-		klog.Errorf("failed to get servers: %v", err)
-	}
-
-	return nil
+	return created, errors.Join(errs...)
 }
 
 // AtomicIncreaseSize is not implemented.
@@ -145,25 +340,57 @@ func (n *hetznerNodeGroup) DeleteNodes(nodes []*apiv1.Node) error {
 	n.clusterUpdateMutex.Lock()
 	defer n.clusterUpdateMutex.Unlock()
 
+	// Placeholders of failed creates have no server to delete.
+	nodes, dropped := n.removeFailedCreates(nodes)
+	n.targetSize -= dropped
+	if len(nodes) == 0 {
+		return nil
+	}
+
 	targetSize := n.targetSize - len(nodes)
 	if targetSize < n.MinSize() {
 		return fmt.Errorf("size decrease is too large. current: %d desired: %d min: %d", n.targetSize, targetSize, n.MinSize())
 	}
 
+	// Resolve all servers up front, so that we either delete exactly the servers
+	// backing the given nodes or nothing at all.
+	servers := make([]*hcloud.Server, 0, len(nodes))
+	for _, node := range nodes {
+		server, err := n.manager.serverForNode(node)
+		if err != nil {
+			return fmt.Errorf("failed to resolve server for node %s error: %v", node.Name, err)
+		}
+		if server == nil {
+			return fmt.Errorf("failed to resolve server for node %s: server not found", node.Name)
+		}
+		servers = append(servers, server)
+	}
+
+	concurrency := n.manager.deleteConcurrency
+	if concurrency <= 0 {
+		concurrency = len(servers)
+	}
+	slots := make(chan struct{}, concurrency)
 	waitGroup := sync.WaitGroup{}
+	errsMutex := sync.Mutex{}
+	var errs []error
 
-	for _, node := range nodes {
+	for i, server := range servers {
 		waitGroup.Add(1)
-		go func(node *apiv1.Node) {
-			klog.Infof("Evicting server %s", node.Name)
-
-			err := n.manager.deleteByNode(node)
-			if err != nil {
-				klog.Errorf("failed to delete server ID %s error: %v", node.Name, err)
+		go func(node *apiv1.Node, server *hcloud.Server) {
+			defer waitGroup.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			klog.Infof("Evicting server %s (ID %d) backing node %s", server.Name, server.ID, node.Name)
+
+			if err := n.manager.deleteServer(server); err != nil {
+				n.recordError(err)
+				klog.Errorf("failed to delete server ID %d for node %s error: %v", server.ID, node.Name, err)
+				errsMutex.Lock()
+				errs = append(errs, fmt.Errorf("failed to delete server ID %d for node %s: %v", server.ID, node.Name, err))
+				errsMutex.Unlock()
 			}
-
-			waitGroup.Done()
-		}(node)
+		}(nodes[i], server)
 	}
 	waitGroup.Wait()
 
@@ -174,6 +401,9 @@ func (n *hetznerNodeGroup) DeleteNodes(nodes []*apiv1.Node) error {
 
 	n.resetTargetSize(-len(nodes))
 
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
 	return nil
 }
 
@@ -210,17 +440,59 @@ func (n *hetznerNodeGroup) Nodes() ([]cloudprovider.Instance, error) {
 	for _, vm := range servers {
 		instances = append(instances, toInstance(vm))
 	}
+	n.lastErrorMutex.Lock()
+	instances = append(instances, n.failedCreates...)
+	n.lastErrorMutex.Unlock()
+	n.warnHeterogeneousServerTypes(servers)
 
 	return instances, nil
 }
 
+// warnHeterogeneousServerTypes logs a warning when the set of server types of
+// the node group's servers besides the node group's server type changes, e.g.
+// after the node group's server type was changed. Such nodes keep the capacity
+// of their own type and aren't used as template for new nodes.
+func (n *hetznerNodeGroup) warnHeterogeneousServerTypes(servers []*hcloud.Server) {
+	var otherTypes []string
+	for _, server := range servers {
+		if server.ServerType != nil && server.ServerType.Name != n.instanceType && !slices.Contains(otherTypes, server.ServerType.Name) {
+			otherTypes = append(otherTypes, server.ServerType.Name)
+		}
+	}
+	slices.Sort(otherTypes)
+
+	n.otherServerTypesMutex.Lock()
+	defer n.otherServerTypesMutex.Unlock()
+	if slices.Equal(otherTypes, n.otherServerTypes) {
+		return
+	}
+	n.otherServerTypes = otherTypes
+	if len(otherTypes) > 0 {
+		klog.Warningf("Node group %s has servers of types %v besides %s, new servers are created as %s", n.id, otherTypes, n.instanceType, n.instanceType)
+	}
+}
+
+// IsTemplateCandidate returns whether the node can be used as template for new
+// nodes of the node group, which is the case unless its server has another
+// server type than the node group. Nodes of another server type have their own
+// capacity, while new servers are created with the node group's server type.
+func (n *hetznerNodeGroup) IsTemplateCandidate(node *apiv1.Node) bool {
+	server, err := n.manager.serverForNode(node)
+	if err != nil {
+		klog.Warningf("Failed to get server of node %s, using it as template for node group %s: %v", node.Name, n.id, err)
+		return true
+	}
+	return server == nil || server.ServerType == nil || server.ServerType.Name == n.instanceType
+}
+
 // TemplateNodeInfo returns a schedulerframework.NodeInfo structure of an empty
 // (as if just started) node. This will be used in scale-up simulations to
 // predict what would a new node look like if a node group was expanded. The
 // returned NodeInfo is expected to have a fully populated Node object, with
 // all of the labels, capacity and allocatable information as well as all pods
 // that are started on the node by default, using manifest (most likely only
-// kube-proxy). Implementation optional.
+// kube-proxy). Implementation optional. New servers are created with the node
+// group's server type, so it's used even if existing servers have another type.
 func (n *hetznerNodeGroup) TemplateNodeInfo() (*schedulerframework.NodeInfo, error) {
 	resourceList, err := getMachineTypeResourceList(n.manager, n.instanceType)
 	if err != nil {
@@ -270,14 +542,14 @@ func (n *hetznerNodeGroup) TemplateNodeInfo() (*schedulerframework.NodeInfo, err
 // Allows to tell the theoretical node group from the real one. Implementation
 // required.
 func (n *hetznerNodeGroup) Exist() bool {
-	_, exists := n.manager.nodeGroups[n.id]
+	_, exists := n.manager.nodeGroup(n.id)
 	return exists
 }
 
 // Create creates the node group on the cloud provider side. Implementation
 // optional.
 func (n *hetznerNodeGroup) Create() (cloudprovider.NodeGroup, error) {
-	n.manager.nodeGroups[n.id] = n
+	n.manager.addNodeGroup(n)
 
 	return n, cloudprovider.ErrNotImplemented
 }
@@ -409,19 +681,26 @@ func instanceTypeArch(manager *hetznerManager, instanceType string) (string, err
 	}
 }
 
-func createServer(n *hetznerNodeGroup) error {
-	ctx, cancel := context.WithTimeout(n.manager.apiCallContext, n.manager.createTimeout)
-	defer cancel()
-
-	serverType, err := n.manager.cachedServerType.getServerType(n.instanceType)
-	if err != nil {
-		return err
+// serverLabels returns the labels of servers created for the node group. Configured server labels can't
+// override the labels in the hcloud/ namespace, which are used to discover the servers of node groups.
+func serverLabels(n *hetznerNodeGroup) map[string]string {
+	labels := map[string]string{}
+	if nodeConfig, ok := n.manager.clusterConfig.NodeConfigs[n.id]; ok && n.manager.clusterConfig.IsUsingNewFormat {
+		for key, value := range nodeConfig.ServerLabels {
+			if strings.HasPrefix(key, hcloudLabelNamespace+"/") {
+				klog.Warningf("Ignoring server label %s of node group %s, the %s/ namespace is reserved", key, n.id, hcloudLabelNamespace)
+				continue
+			}
+			labels[key] = value
+		}
 	}
+	labels[nodeGroupLabel] = n.id
+	return labels
+}
 
-	image, err := findImage(n, serverType)
-	if err != nil {
-		return err
-	}
+func createServer(n *hetznerNodeGroup, serverType *hcloud.ServerType, image *hcloud.Image, sshKeys []*hcloud.SSHKey, publicNet *hcloud.ServerCreatePublicNet, network *hcloud.Network) error {
+	ctx, cancel := context.WithTimeout(n.manager.apiCallContext, n.manager.createTimeout)
+	defer cancel()
 
 	cloudInit := n.manager.clusterConfig.LegacyConfig.CloudInit
 
@@ -433,32 +712,42 @@ func createServer(n *hetznerNodeGroup) error {
 	opts := hcloud.ServerCreateOpts{
 		Name:             newNodeName(n),
 		UserData:         cloudInit,
-		Location:         &hcloud.Location{Name: n.region},
 		ServerType:       serverType,
 		Image:            image,
 		StartAfterCreate: &StartAfterCreate,
-		Labels: map[string]string{
-			nodeGroupLabel: n.id,
-		},
-		PublicNet: &hcloud.ServerCreatePublicNet{
-			EnableIPv4: n.manager.publicIPv4,
-			EnableIPv6: n.manager.publicIPv6,
-		},
+		Labels:           serverLabels(n),
+		PublicNet:        publicNet,
 	}
-	if n.manager.sshKey != nil {
-		opts.SSHKeys = []*hcloud.SSHKey{n.manager.sshKey}
+	if len(sshKeys) > 0 {
+		opts.SSHKeys = sshKeys
 	}
-	if n.manager.network != nil {
-		opts.Networks = []*hcloud.Network{n.manager.network}
+	if network != nil {
+		opts.Networks = []*hcloud.Network{network}
 	}
 	if n.manager.firewall != nil {
 		serverCreateFirewall := &hcloud.ServerCreateFirewall{Firewall: *n.manager.firewall}
 		opts.Firewalls = []*hcloud.ServerCreateFirewall{serverCreateFirewall}
 	}
 
-	serverCreateResult, _, err := n.manager.client.Server.Create(ctx, opts)
+	var serverCreateResult hcloud.ServerCreateResult
+	var err error
+	location := ""
+	for _, location = range n.locations() {
+		opts.Location = &hcloud.Location{Name: location}
+		serverCreateResult, _, err = n.manager.client.Server.Create(ctx, opts)
+		if !hcloud.IsError(err, hcloud.ErrorCodeResourceUnavailable) {
+			break
+		}
+		klog.Warningf("No capacity left for server type %s in region %s: %v", n.instanceType, location, err)
+	}
+	if err != nil {
+		n.recordFailedCreate(err)
+	}
+	if network != nil && (hcloud.IsError(err, hcloud.ErrorCodeNoSubnetAvailable) || hcloud.IsError(err, hcloud.ErrorCodeIPNotAvailable)) {
+		return fmt.Errorf("could not create server type %s in region %s: no IP available in network %s: %v", n.instanceType, location, network.Name, err)
+	}
 	if err != nil {
-		return fmt.Errorf("could not create server type %s in region %s: %v", n.instanceType, n.region, err)
+		return fmt.Errorf("could not create server type %s in region %s: %v", n.instanceType, location, err)
 	}
 
 	server := serverCreateResult.Server
@@ -468,17 +757,85 @@ func createServer(n *hetznerNodeGroup) error {
 	// Delete the server if any action (most importantly create_server & start_server) fails
 	err = n.manager.client.Action.WaitFor(ctx, actions...)
 	if err != nil {
+		n.recordFailedCreate(err)
 		_ = n.manager.deleteServer(server)
 		return fmt.Errorf("failed to start server %s error: %v", server.Name, err)
 	}
 
+	if n.manager.readinessCheck {
+		if err := waitForServerReady(ctx, n.manager, server); err != nil {
+			n.recordFailedCreate(err)
+			_ = n.manager.deleteServer(server)
+			return fmt.Errorf("server %s didn't become ready error: %v", server.Name, err)
+		}
+	}
+
+	if publicNet != nil {
+		if err := n.manager.labelCreatedPrimaryIPs(server, publicNet); err != nil {
+			klog.Warningf("Primary IPs of server %s won't be released on scale-down: %v", server.Name, err)
+		}
+	}
+
 	return nil
 }
 
+// waitForServerReady polls the server until it's running and, if a readiness port is configured, accepts
+// connections on that port. It gives up once ctx is done, which is bounded by the server creation timeout.
+func waitForServerReady(ctx context.Context, m *hetznerManager, server *hcloud.Server) error {
+	var lastErr error
+	for {
+		lastErr = serverReady(ctx, m, server.ID)
+		if lastErr == nil {
+			return nil
+		}
+		klog.V(4).Infof("Server %s is not ready yet: %v", server.Name, lastErr)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v (last check: %v)", ctx.Err(), lastErr)
+		case <-time.After(m.readinessPollInterval):
+		}
+	}
+}
+
+// serverReady returns nil if the server is running and reachable on the readiness port, if it's set.
+func serverReady(ctx context.Context, m *hetznerManager, id int64) error {
+	server, _, err := m.client.Server.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get server: %v", err)
+	}
+	if server == nil {
+		return fmt.Errorf("server not found")
+	}
+	if server.Status != hcloud.ServerStatusRunning {
+		return fmt.Errorf("server status is %s", server.Status)
+	}
+	if m.readinessPort == 0 {
+		return nil
+	}
+
+	var ip net.IP
+	if !server.PublicNet.IPv4.IsUnspecified() {
+		ip = server.PublicNet.IPv4.IP
+	} else if len(server.PrivateNet) > 0 {
+		ip = server.PrivateNet[0].IP
+	}
+	if ip == nil {
+		return fmt.Errorf("server has no IPv4 address to check port %d", m.readinessPort)
+	}
+	dialer := net.Dialer{Timeout: m.readinessPollInterval}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(m.readinessPort)))
+	if err != nil {
+		return fmt.Errorf("port %d is not reachable: %v", m.readinessPort, err)
+	}
+	return conn.Close()
+}
+
 // findImage searches for an image ID corresponding to the supplied
-// HCLOUD_IMAGE env variable. This value can either be an image ID itself (an
-// int), a name (e.g. "ubuntu-20.04"), or a label selector associated with an
-// image snapshot. In the latter case it will use the most recent snapshot.
+// HCLOUD_IMAGE env variable, or the image configured for the node group. This
+// value can either be an image ID itself (an int), a name (e.g. "ubuntu-20.04"),
+// or a label selector associated with an image snapshot. In the latter case it
+// will use the most recent snapshot.
 // It also verifies that the returned image has a compatible architecture with
 // server.
 func findImage(n *hetznerNodeGroup, serverType *hcloud.ServerType) (*hcloud.Image, error) {
@@ -492,6 +849,10 @@ func findImage(n *hetznerNodeGroup, serverType *hcloud.ServerType) (*hcloud.Imag
 		if serverType.Architecture == hcloud.ArchitectureX86 {
 			imageName = n.manager.clusterConfig.ImagesForArch.Amd64
 		}
+
+		if nodeConfig, ok := n.manager.clusterConfig.NodeConfigs[n.id]; ok && nodeConfig.Image != "" {
+			imageName = nodeConfig.Image
+		}
 	}
 
 	image, _, err := n.manager.client.Image.GetForArchitecture(context.TODO(), imageName, serverType.Architecture)
@@ -530,7 +891,10 @@ func (n *hetznerNodeGroup) resetTargetSize(expectedDelta int) {
 		klog.Errorf("failed to set node pool %s size, using delta %d error: %v", n.id, expectedDelta, err)
 		n.targetSize = n.targetSize - expectedDelta
 	} else {
-		klog.Infof("Set node group %s size from %d to %d, expected delta %d", n.id, n.targetSize, len(servers), expectedDelta)
-		n.targetSize = len(servers)
+		// Placeholders of failed creates stay part of the target size until
+		// they're deleted, so that it matches Nodes().
+		targetSize := len(servers) + n.failedCreatesCount()
+		klog.Infof("Set node group %s size from %d to %d, expected delta %d", n.id, n.targetSize, targetSize, expectedDelta)
+		n.targetSize = targetSize
 	}
 }
@@ -0,0 +1,1228 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud/schema"
+)
+
+func newTestManager(t *testing.T, mux *http.ServeMux) *hetznerManager {
+	t.Helper()
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := hcloud.NewClient(
+		hcloud.WithEndpoint(server.URL),
+		hcloud.WithToken("token"),
+		hcloud.WithBackoffFunc(hcloud.ConstantBackoff(0)),
+		hcloud.WithPollBackoffFunc(hcloud.ConstantBackoff(0)),
+	)
+
+	ctx := context.Background()
+	return &hetznerManager{
+		client:           client,
+		nodeGroups:       make(map[string]*hetznerNodeGroup),
+		apiCallContext:   ctx,
+		clusterConfig:    &ClusterConfig{},
+		createTimeout:    serverCreateTimeoutDefault,
+		deleteTimeout:    serverDeleteTimeoutDefault,
+		publicIPv4:       true,
+		publicIPv6:       true,
+		cachedServerType: newServerTypeCache(ctx, client),
+		cachedServers:    newServersCache(ctx, client),
+	}
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, v interface{}) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+// actionsResponse returns the actions listed by a GET /actions request, all with the given status.
+func actionsResponse(t *testing.T, r *http.Request, status hcloud.ActionStatus) schema.ActionListResponse {
+	t.Helper()
+
+	var actions []schema.Action
+	for _, idStr := range r.URL.Query()["id"] {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		require.NoError(t, err)
+		actions = append(actions, schema.Action{ID: id, Status: string(status)})
+	}
+	return schema.ActionListResponse{Actions: actions}
+}
+
+func testServer(id int64, nodeGroup string) schema.Server {
+	return schema.Server{
+		ID:     id,
+		Name:   "server-" + strconv.FormatInt(id, 10),
+		Status: string(hcloud.ServerStatusRunning),
+		Labels: map[string]string{nodeGroupLabel: nodeGroup},
+	}
+}
+
+func testNode(name string, serverID int64) *apiv1.Node {
+	return &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       apiv1.NodeSpec{ProviderID: toProviderID(serverID)},
+	}
+}
+
+func TestDeleteNodes(t *testing.T) {
+	servers := map[int64]schema.Server{
+		1: testServer(1, "pool1"),
+		2: testServer(2, "pool1"),
+		3: testServer(3, "pool1"),
+	}
+	var mu sync.Mutex
+	var deleted []int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		list := make([]schema.Server, 0, len(servers))
+		for _, server := range servers {
+			list = append(list, server)
+		}
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: list})
+	})
+	mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		deleted = append(deleted, id)
+		delete(servers, id)
+		writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: id, Status: "running"}})
+	})
+	mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, actionsResponse(t, r, hcloud.ActionStatusSuccess))
+	})
+
+	manager := newTestManager(t, mux)
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		minSize:            0,
+		maxSize:            3,
+		targetSize:         3,
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	t.Run("deletes exactly the servers backing the nodes", func(t *testing.T) {
+		err := nodeGroup.DeleteNodes([]*apiv1.Node{
+			testNode("node-a", 1),
+			testNode("node-c", 3),
+		})
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+		assert.Equal(t, []int64{1, 3}, deleted)
+		assert.Contains(t, servers, int64(2))
+		assert.Equal(t, 1, nodeGroup.targetSize)
+	})
+
+	t.Run("fails without deleting anything if a server can't be resolved", func(t *testing.T) {
+		mu.Lock()
+		deleted = nil
+		mu.Unlock()
+		nodeGroup.targetSize = 3
+
+		err := nodeGroup.DeleteNodes([]*apiv1.Node{
+			testNode("node-b", 2),
+			testNode("node-unknown", 42),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "node-unknown")
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Empty(t, deleted)
+	})
+}
+
+// newScaleUpMux returns a mux serving a single x86 server type available in fsn1 and recording the
+// server create requests.
+func newScaleUpMux(t *testing.T, createRequests chan<- schema.ServerCreateRequest) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{{
+			ID:           1,
+			Name:         "cx22",
+			Architecture: string(hcloud.ArchitectureX86),
+			Prices:       []schema.PricingServerTypePrice{{Location: "fsn1"}},
+		}}})
+	})
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{})
+	})
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		var req schema.ServerCreateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		createRequests <- req
+		writeJSON(t, w, http.StatusCreated, schema.ServerCreateResponse{
+			Server: schema.Server{ID: 1, Name: req.Name},
+			Action: schema.Action{ID: 1, Status: string(hcloud.ActionStatusSuccess)},
+		})
+	})
+	return mux
+}
+
+func TestIncreaseSizeImageAndUserData(t *testing.T) {
+	images := map[string]schema.Image{
+		"custom-image": {ID: 42, Name: hcloud.Ptr("custom-image"), Architecture: string(hcloud.ArchitectureX86)},
+	}
+	createRequests := make(chan schema.ServerCreateRequest, 1)
+	mux := newScaleUpMux(t, createRequests)
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		var list []schema.Image
+		if image, ok := images[r.URL.Query().Get("name")]; ok {
+			list = append(list, image)
+		}
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: list})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {CloudInit: "#cloud-config\nruncmd: []\n", Image: "custom-image"},
+			"pool2": {CloudInit: "#cloud-config\n", Image: "missing-image"},
+		},
+	}
+	newNodeGroup := func(id string) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 id,
+			manager:            manager,
+			maxSize:            3,
+			instanceType:       "cx22",
+			region:             "fsn1",
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("create request carries the node group's image and user data", func(t *testing.T) {
+		require.NoError(t, newNodeGroup("pool1").IncreaseSize(1))
+		require.Len(t, createRequests, 1)
+
+		req := <-createRequests
+		assert.Equal(t, float64(42), req.Image)
+		assert.Equal(t, "#cloud-config\nruncmd: []\n", req.UserData)
+	})
+
+	t.Run("scale-up fails if the image doesn't exist", func(t *testing.T) {
+		err := newNodeGroup("pool2").IncreaseSize(1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing-image")
+		assert.Empty(t, createRequests)
+	})
+}
+
+func TestIncreaseSizeSSHKeys(t *testing.T) {
+	sshKeys := map[string]schema.SSHKey{
+		"admin":    {ID: 7, Name: "admin"},
+		"recovery": {ID: 8, Name: "recovery"},
+	}
+	var mu sync.Mutex
+	sshKeyLookups := 0
+	createRequests := make(chan schema.ServerCreateRequest, 1)
+	mux := newScaleUpMux(t, createRequests)
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /ssh_keys", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sshKeyLookups++
+		mu.Unlock()
+		var list []schema.SSHKey
+		if sshKey, ok := sshKeys[r.URL.Query().Get("name")]; ok {
+			list = append(list, sshKey)
+		}
+		writeJSON(t, w, http.StatusOK, schema.SSHKeyListResponse{SSHKeys: list})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {SSHKeys: []string{"admin", "recovery"}},
+			"pool2": {SSHKeys: []string{"unknown"}},
+		},
+	}
+	newNodeGroup := func(id string) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 id,
+			manager:            manager,
+			maxSize:            3,
+			instanceType:       "cx22",
+			region:             "fsn1",
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("create request lists the resolved key IDs", func(t *testing.T) {
+		nodeGroup := newNodeGroup("pool1")
+		for i := 0; i < 2; i++ {
+			require.NoError(t, nodeGroup.IncreaseSize(1))
+			require.Len(t, createRequests, 1)
+			req := <-createRequests
+			assert.Equal(t, []int64{7, 8}, req.SSHKeys)
+		}
+
+		// Key names are only resolved once.
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 2, sshKeyLookups)
+	})
+
+	t.Run("scale-up fails on an unknown key name", func(t *testing.T) {
+		err := newNodeGroup("pool2").IncreaseSize(1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown")
+		assert.Empty(t, createRequests)
+	})
+}
+
+func TestIncreaseSizePrimaryIPs(t *testing.T) {
+	primaryIPs := map[string]schema.PrimaryIP{
+		"ingress-ip": {ID: 13, Name: "ingress-ip", Type: string(hcloud.PrimaryIPTypeIPv4)},
+	}
+	createRequests := make(chan schema.ServerCreateRequest, 1)
+	mux := newScaleUpMux(t, createRequests)
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /primary_ips", func(w http.ResponseWriter, r *http.Request) {
+		var list []schema.PrimaryIP
+		if primaryIP, ok := primaryIPs[r.URL.Query().Get("name")]; ok {
+			list = append(list, primaryIP)
+		}
+		writeJSON(t, w, http.StatusOK, schema.PrimaryIPListResult{PrimaryIPs: list})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {PrimaryIPv4: "ingress-ip", PublicIPv6: hcloud.Ptr(false)},
+			"pool2": {PublicIPv4: hcloud.Ptr(false)},
+			"pool3": {PrimaryIPv4: "unknown-ip"},
+		},
+	}
+	newNodeGroup := func(id string) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 id,
+			manager:            manager,
+			maxSize:            3,
+			instanceType:       "cx22",
+			region:             "fsn1",
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("existing primary IP is attached", func(t *testing.T) {
+		require.NoError(t, newNodeGroup("pool1").IncreaseSize(1))
+		require.Len(t, createRequests, 1)
+
+		req := <-createRequests
+		require.NotNil(t, req.PublicNet)
+		assert.Equal(t, schema.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: false, IPv4ID: 13}, *req.PublicNet)
+	})
+
+	t.Run("automatic IPv4 assignment is disabled", func(t *testing.T) {
+		require.NoError(t, newNodeGroup("pool2").IncreaseSize(1))
+		require.Len(t, createRequests, 1)
+
+		req := <-createRequests
+		require.NotNil(t, req.PublicNet)
+		assert.Equal(t, schema.ServerCreatePublicNet{EnableIPv4: false, EnableIPv6: true}, *req.PublicNet)
+	})
+
+	t.Run("scale-up fails on an unknown primary IP", func(t *testing.T) {
+		err := newNodeGroup("pool3").IncreaseSize(1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown-ip")
+		assert.Empty(t, createRequests)
+	})
+
+	t.Run("more than one server is rejected for a pinned primary IP", func(t *testing.T) {
+		err := newNodeGroup("pool1").IncreaseSize(2)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "primary ip")
+		assert.Empty(t, createRequests)
+
+		nodeGroup := newNodeGroup("pool1")
+		nodeGroup.targetSize = 1
+		require.Error(t, nodeGroup.IncreaseSize(1))
+		assert.Empty(t, createRequests)
+	})
+}
+
+func TestDeleteNodesReleasesPrimaryIPs(t *testing.T) {
+	autoCreatedServer := testServer(1, "pool1")
+	autoCreatedServer.PublicNet.IPv4.ID = 11
+	autoCreatedServer.PublicNet.IPv6.ID = 12
+	configuredServer := testServer(2, "pool1")
+	configuredServer.PublicNet.IPv4.ID = 13
+	userOwnedServer := testServer(3, "pool1")
+	userOwnedServer.PublicNet.IPv4.ID = 14
+	servers := []schema.Server{autoCreatedServer, configuredServer, userOwnedServer}
+	createdLabels := map[string]string{primaryIPCreatedLabel: "true"}
+	primaryIPs := map[int64]schema.PrimaryIP{
+		11: {ID: 11, Name: "primary_ip-11", AutoDelete: false, Labels: createdLabels},
+		12: {ID: 12, Name: "primary_ip-12", AutoDelete: true, Labels: createdLabels},
+		13: {ID: 13, Name: "ingress-ip", AutoDelete: false},
+		// Not configured for the node group, but not created by the autoscaler either.
+		14: {ID: 14, Name: "user-ip", AutoDelete: false},
+	}
+	var mu sync.Mutex
+	var updates []int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: servers})
+	})
+	mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: 1, Status: "running"}})
+	})
+	mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, actionsResponse(t, r, hcloud.ActionStatusSuccess))
+	})
+	mux.HandleFunc("GET /primary_ips/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		require.NoError(t, err)
+		writeJSON(t, w, http.StatusOK, schema.PrimaryIPGetResult{PrimaryIP: primaryIPs[id]})
+	})
+	mux.HandleFunc("PUT /primary_ips/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		require.NoError(t, err)
+		var opts hcloud.PrimaryIPUpdateOpts
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		require.NotNil(t, opts.AutoDelete)
+		assert.True(t, *opts.AutoDelete)
+		mu.Lock()
+		updates = append(updates, id)
+		mu.Unlock()
+		primaryIP := primaryIPs[id]
+		primaryIP.AutoDelete = true
+		writeJSON(t, w, http.StatusOK, schema.PrimaryIPUpdateResult{PrimaryIP: primaryIP})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {PrimaryIPv4: "ingress-ip"},
+		},
+	}
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            3,
+		targetSize:         3,
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	require.NoError(t, nodeGroup.DeleteNodes([]*apiv1.Node{testNode("node-a", 1), testNode("node-b", 2), testNode("node-c", 3)}))
+
+	// Only the auto-created IPv4 needed releasing, the IPv6 was already auto-deleted and the primary IPs
+	// the autoscaler didn't create are kept.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{11}, updates)
+}
+
+func TestLabelCreatedPrimaryIPs(t *testing.T) {
+	var mu sync.Mutex
+	labeled := map[int64]map[string]string{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /primary_ips/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		require.NoError(t, err)
+		var opts hcloud.PrimaryIPUpdateOpts
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&opts))
+		assert.Nil(t, opts.AutoDelete)
+		require.NotNil(t, opts.Labels)
+		mu.Lock()
+		labeled[id] = *opts.Labels
+		mu.Unlock()
+		writeJSON(t, w, http.StatusOK, schema.PrimaryIPUpdateResult{PrimaryIP: schema.PrimaryIP{ID: id, Labels: *opts.Labels}})
+	})
+	manager := newTestManager(t, mux)
+
+	server := &hcloud.Server{Name: "server-1"}
+	server.PublicNet.IPv4.ID = 11
+	server.PublicNet.IPv6.ID = 13
+	// The IPv6 was given in the create request and is owned by the user.
+	publicNet := &hcloud.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true, IPv6: &hcloud.PrimaryIP{ID: 13}}
+	require.NoError(t, manager.labelCreatedPrimaryIPs(server, publicNet))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[int64]map[string]string{11: {primaryIPCreatedLabel: "true"}}, labeled)
+}
+
+func TestIncreaseSizeNetwork(t *testing.T) {
+	networks := map[string]schema.Network{
+		"cluster-net": {ID: 5, Name: "cluster-net"},
+	}
+	createRequests := make(chan schema.ServerCreateRequest, 1)
+	mux := newScaleUpMux(t, createRequests)
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /networks", func(w http.ResponseWriter, r *http.Request) {
+		var list []schema.Network
+		if network, ok := networks[r.URL.Query().Get("name")]; ok {
+			list = append(list, network)
+		}
+		writeJSON(t, w, http.StatusOK, schema.NetworkListResponse{Networks: list})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.network = &hcloud.Network{ID: 9, Name: "default-net"}
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {Network: "cluster-net"},
+			"pool2": {},
+			"pool3": {Network: "unknown-net"},
+		},
+	}
+	newNodeGroup := func(id string) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 id,
+			manager:            manager,
+			maxSize:            3,
+			instanceType:       "cx22",
+			region:             "fsn1",
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("node group network is attached", func(t *testing.T) {
+		require.NoError(t, newNodeGroup("pool1").IncreaseSize(1))
+		require.Len(t, createRequests, 1)
+		req := <-createRequests
+		assert.Equal(t, []int64{5}, req.Networks)
+	})
+
+	t.Run("HCLOUD_NETWORK is attached by default", func(t *testing.T) {
+		require.NoError(t, newNodeGroup("pool2").IncreaseSize(1))
+		require.Len(t, createRequests, 1)
+		req := <-createRequests
+		assert.Equal(t, []int64{9}, req.Networks)
+	})
+
+	t.Run("scale-up fails on an unknown network", func(t *testing.T) {
+		err := newNodeGroup("pool3").IncreaseSize(1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown-net")
+		assert.Empty(t, createRequests)
+	})
+}
+
+func TestCreateServerNetworkFull(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusPreconditionFailed, schema.ErrorResponse{Error: schema.Error{
+			Code:    string(hcloud.ErrorCodeNoSubnetAvailable),
+			Message: "no subnet or IP available",
+		}})
+	})
+
+	manager := newTestManager(t, mux)
+	nodeGroup := &hetznerNodeGroup{
+		id:           "pool1",
+		manager:      manager,
+		instanceType: "cx22",
+		region:       "fsn1",
+	}
+	network := &hcloud.Network{ID: 5, Name: "cluster-net"}
+	publicNet := &hcloud.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true}
+
+	err := createServer(nodeGroup, &hcloud.ServerType{ID: 1}, &hcloud.Image{ID: 1}, nil, publicNet, network)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no IP available in network cluster-net")
+}
+
+func TestDeleteNodesDetachesVolumes(t *testing.T) {
+	server := testServer(1, "pool1")
+	server.Volumes = []int64{21, 22}
+	volumes := map[int64]schema.Volume{
+		21: {ID: 21, Name: "data", Server: hcloud.Ptr(int64(1))},
+		// Already detached, e.g. by the CSI driver.
+		22: {ID: 22, Name: "scratch"},
+	}
+
+	newMux := func(detachStatus int) (*http.ServeMux, func() []string) {
+		var mu sync.Mutex
+		var calls []string
+		record := func(call string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, call)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: []schema.Server{server}})
+		})
+		mux.HandleFunc("GET /volumes/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+			require.NoError(t, err)
+			writeJSON(t, w, http.StatusOK, schema.VolumeGetResponse{Volume: volumes[id]})
+		})
+		mux.HandleFunc("POST /volumes/{id}/actions/detach", func(w http.ResponseWriter, r *http.Request) {
+			record("detach " + r.PathValue("id"))
+			if detachStatus != http.StatusCreated {
+				writeJSON(t, w, detachStatus, schema.ErrorResponse{Error: schema.Error{Code: string(hcloud.ErrorCodeLocked), Message: "volume is locked"}})
+				return
+			}
+			writeJSON(t, w, detachStatus, schema.VolumeActionDetachVolumeResponse{Action: schema.Action{ID: 30, Status: "running"}})
+		})
+		mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+			record("wait " + r.URL.Query().Get("id"))
+			writeJSON(t, w, http.StatusOK, actionsResponse(t, r, hcloud.ActionStatusSuccess))
+		})
+		mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+			record("delete " + r.PathValue("id"))
+			writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: 1, Status: "running"}})
+		})
+		return mux, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return calls
+		}
+	}
+	newNodeGroup := func(manager *hetznerManager) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 "pool1",
+			manager:            manager,
+			maxSize:            1,
+			targetSize:         1,
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("volumes are detached before the server is deleted", func(t *testing.T) {
+		mux, calls := newMux(http.StatusCreated)
+		nodeGroup := newNodeGroup(newTestManager(t, mux))
+
+		require.NoError(t, nodeGroup.DeleteNodes([]*apiv1.Node{testNode("node-a", 1)}))
+		assert.Equal(t, []string{"detach 21", "wait 30", "delete 1", "wait 1"}, calls())
+	})
+
+	t.Run("server isn't deleted if a volume can't be detached", func(t *testing.T) {
+		mux, calls := newMux(http.StatusLocked)
+		nodeGroup := newNodeGroup(newTestManager(t, mux))
+
+		err := nodeGroup.DeleteNodes([]*apiv1.Node{testNode("node-a", 1)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to detach volume data (ID 21)")
+		assert.Contains(t, err.Error(), "still be in use")
+		assert.Equal(t, []string{"detach 21"}, calls())
+	})
+}
+
+func TestDeleteNodesWaitsForDeletion(t *testing.T) {
+	newManager := func(pollsUntilDone int) (*hetznerManager, func() int) {
+		var mu sync.Mutex
+		polls := 0
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: []schema.Server{testServer(1, "pool1")}})
+		})
+		mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: 5, Status: "running"}})
+		})
+		mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			polls++
+			status := hcloud.ActionStatusRunning
+			if pollsUntilDone > 0 && polls >= pollsUntilDone {
+				status = hcloud.ActionStatusSuccess
+			}
+			mu.Unlock()
+			writeJSON(t, w, http.StatusOK, actionsResponse(t, r, status))
+		})
+		return newTestManager(t, mux), func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return polls
+		}
+	}
+	newNodeGroup := func(manager *hetznerManager) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 "pool1",
+			manager:            manager,
+			maxSize:            1,
+			targetSize:         1,
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("deletion is reported once the delete action completes", func(t *testing.T) {
+		manager, polls := newManager(2)
+		nodeGroup := newNodeGroup(manager)
+
+		require.NoError(t, nodeGroup.DeleteNodes([]*apiv1.Node{testNode("node-a", 1)}))
+		assert.Equal(t, 2, polls())
+		assert.Equal(t, 0, nodeGroup.targetSize)
+	})
+
+	t.Run("deletion fails if the delete action doesn't complete in time", func(t *testing.T) {
+		manager, _ := newManager(0)
+		manager.deleteTimeout = 50 * time.Millisecond
+		nodeGroup := newNodeGroup(manager)
+
+		err := nodeGroup.DeleteNodes([]*apiv1.Node{testNode("node-a", 1)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to wait for server server-1 to be deleted")
+	})
+}
+
+func TestIncreaseSizeServerLabels(t *testing.T) {
+	createRequests := make(chan schema.ServerCreateRequest, 1)
+	mux := newScaleUpMux(t, createRequests)
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs: map[string]*NodeConfig{
+			"pool1": {ServerLabels: map[string]string{
+				"cost-center":  "platform",
+				"team":         "infra",
+				nodeGroupLabel: "other-pool",
+				"hcloud/extra": "value",
+			}},
+		},
+	}
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            3,
+		instanceType:       "cx22",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	require.NoError(t, nodeGroup.IncreaseSize(1))
+	require.Len(t, createRequests, 1)
+
+	req := <-createRequests
+	require.NotNil(t, req.Labels)
+	// Configured labels are merged in, while the reserved node group label keeps pointing at the node group.
+	assert.Equal(t, map[string]string{
+		"cost-center":  "platform",
+		"team":         "infra",
+		nodeGroupLabel: "pool1",
+	}, *req.Labels)
+}
+
+func TestIncreaseSizeReadinessCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	newManager := func(statuses []hcloud.ServerStatus, ip string) (*hetznerManager, func() (int, int)) {
+		var mu sync.Mutex
+		polls, deletes := 0, 0
+		createRequests := make(chan schema.ServerCreateRequest, 1)
+		mux := newScaleUpMux(t, createRequests)
+		mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+				{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+			}})
+		})
+		mux.HandleFunc("GET /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			status := statuses[min(polls, len(statuses)-1)]
+			polls++
+			mu.Unlock()
+			server := testServer(1, "pool1")
+			server.Status = string(status)
+			server.PublicNet.IPv4.IP = ip
+			writeJSON(t, w, http.StatusOK, schema.ServerGetResponse{Server: server})
+		})
+		mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			deletes++
+			mu.Unlock()
+			writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: 2, Status: "running"}})
+		})
+
+		manager := newTestManager(t, mux)
+		manager.clusterConfig = &ClusterConfig{
+			IsUsingNewFormat: true,
+			ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+			NodeConfigs:      map[string]*NodeConfig{"pool1": {}},
+		}
+		manager.readinessCheck = true
+		manager.readinessPollInterval = time.Millisecond
+		return manager, func() (int, int) {
+			mu.Lock()
+			defer mu.Unlock()
+			return polls, deletes
+		}
+	}
+	newNodeGroup := func(manager *hetznerManager) *hetznerNodeGroup {
+		return &hetznerNodeGroup{
+			id:                 "pool1",
+			manager:            manager,
+			maxSize:            3,
+			instanceType:       "cx22",
+			region:             "fsn1",
+			clusterUpdateMutex: &sync.Mutex{},
+		}
+	}
+
+	t.Run("server counts as created once it's running", func(t *testing.T) {
+		manager, counts := newManager([]hcloud.ServerStatus{hcloud.ServerStatusInitializing, hcloud.ServerStatusStarting, hcloud.ServerStatusRunning}, "")
+		nodeGroup := newNodeGroup(manager)
+
+		require.NoError(t, nodeGroup.IncreaseSize(1))
+		polls, deletes := counts()
+		assert.Equal(t, 3, polls)
+		assert.Equal(t, 0, deletes)
+		assert.Equal(t, 1, nodeGroup.targetSize)
+	})
+
+	t.Run("server counts as created once its port is reachable", func(t *testing.T) {
+		manager, counts := newManager([]hcloud.ServerStatus{hcloud.ServerStatusStarting, hcloud.ServerStatusStarting, hcloud.ServerStatusRunning}, "127.0.0.1")
+		manager.readinessPort = port
+		nodeGroup := newNodeGroup(manager)
+
+		require.NoError(t, nodeGroup.IncreaseSize(1))
+		polls, deletes := counts()
+		assert.Equal(t, 3, polls)
+		assert.Equal(t, 0, deletes)
+		assert.Equal(t, 1, nodeGroup.targetSize)
+	})
+
+	t.Run("server is deleted if it isn't ready within the timeout", func(t *testing.T) {
+		manager, counts := newManager([]hcloud.ServerStatus{hcloud.ServerStatusStarting}, "")
+		manager.createTimeout = 50 * time.Millisecond
+		nodeGroup := newNodeGroup(manager)
+
+		require.NoError(t, nodeGroup.IncreaseSize(1))
+		_, deletes := counts()
+		assert.Equal(t, 1, deletes)
+		assert.Equal(t, 0, nodeGroup.targetSize)
+	})
+}
+
+func TestIncreaseSizeRecordsLastError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{{
+			ID:           1,
+			Name:         "cx22",
+			Architecture: string(hcloud.ArchitectureX86),
+			Prices:       []schema.PricingServerTypePrice{{Location: "fsn1"}},
+		}}})
+	})
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{})
+	})
+	var createSucceeds atomic.Bool
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		if createSucceeds.Load() {
+			writeJSON(t, w, http.StatusCreated, schema.ServerCreateResponse{
+				Server: testServer(1, "pool1"),
+				Action: schema.Action{ID: 1, Status: string(hcloud.ActionStatusSuccess)},
+			})
+			return
+		}
+		writeJSON(t, w, http.StatusPreconditionFailed, schema.ErrorResponse{Error: schema.Error{
+			Code:    string(hcloud.ErrorCodeResourceUnavailable),
+			Message: "server type cx22 is unavailable in fsn1",
+		}})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs:      map[string]*NodeConfig{"pool1": {}},
+	}
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            3,
+		instanceType:       "cx22",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+	assert.Nil(t, nodeGroup.LastError())
+
+	// The failed create is counted in the target size, as its placeholder is
+	// reported by Nodes().
+	require.NoError(t, nodeGroup.IncreaseSize(1))
+	assert.Equal(t, 1, nodeGroup.targetSize)
+	errorInfo := &cloudprovider.InstanceErrorInfo{
+		ErrorClass:   cloudprovider.OutOfResourcesErrorClass,
+		ErrorCode:    string(hcloud.ErrorCodeResourceUnavailable),
+		ErrorMessage: "server type cx22 is unavailable in fsn1",
+	}
+	assert.Equal(t, errorInfo, nodeGroup.LastError())
+
+	// The failed create is reported to CA, which backs off and deletes it.
+	instances, err := nodeGroup.Nodes()
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, &cloudprovider.InstanceStatus{State: cloudprovider.InstanceCreating, ErrorInfo: errorInfo}, instances[0].Status)
+
+	// A refresh keeps the placeholder in the target size.
+	nodeGroup.resetTargetSize(0)
+	assert.Equal(t, 1, nodeGroup.targetSize)
+
+	manager.nodeGroups[nodeGroup.id] = nodeGroup
+	provider := &HetznerCloudProvider{manager: manager}
+	fakeNode := &apiv1.Node{Spec: apiv1.NodeSpec{ProviderID: instances[0].Id}}
+	group, err := provider.NodeGroupForNode(fakeNode)
+	require.NoError(t, err)
+	assert.Equal(t, nodeGroup, group)
+
+	require.NoError(t, nodeGroup.DeleteNodes([]*apiv1.Node{fakeNode}))
+	assert.Equal(t, 0, nodeGroup.targetSize)
+	instances, err = nodeGroup.Nodes()
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+	assert.Equal(t, errorInfo, nodeGroup.LastError())
+
+	// The next scale-up clears the last error.
+	createSucceeds.Store(true)
+	require.NoError(t, nodeGroup.IncreaseSize(1))
+	assert.Nil(t, nodeGroup.LastError())
+	assert.Equal(t, 1, nodeGroup.targetSize)
+}
+
+func TestMixedServerTypes(t *testing.T) {
+	oldType := schema.ServerType{ID: 1, Name: "cx22", Cores: 2, Memory: 4, Disk: 40, Architecture: string(hcloud.ArchitectureX86)}
+	newType := schema.ServerType{ID: 2, Name: "cx32", Cores: 4, Memory: 8, Disk: 80, Architecture: string(hcloud.ArchitectureX86)}
+	oldServer := testServer(1, "pool1")
+	oldServer.ServerType = oldType
+	newServer := testServer(2, "pool1")
+	newServer.ServerType = newType
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{oldType, newType}})
+	})
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: []schema.Server{oldServer, newServer}})
+	})
+	manager := newTestManager(t, mux)
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            3,
+		instanceType:       "cx32",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	instances, err := nodeGroup.Nodes()
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+	assert.Equal(t, []string{"cx22"}, nodeGroup.otherServerTypes)
+
+	// Only nodes of the node group's server type are used as template, as
+	// new nodes are created with it.
+	assert.False(t, nodeGroup.IsTemplateCandidate(testNode("old", 1)))
+	assert.True(t, nodeGroup.IsTemplateCandidate(testNode("new", 2)))
+
+	nodeInfo, err := nodeGroup.TemplateNodeInfo()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), nodeInfo.Node().Status.Capacity.Cpu().Value())
+	assert.Equal(t, int64(8*1024*1024*1024), nodeInfo.Node().Status.Capacity.Memory().Value())
+	assert.Equal(t, "cx32", nodeInfo.Node().Labels[apiv1.LabelInstanceType])
+}
+
+func TestIncreaseSizePartialFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{{
+			ID:           1,
+			Name:         "cx22",
+			Architecture: string(hcloud.ArchitectureX86),
+			Prices:       []schema.PricingServerTypePrice{{Location: "fsn1"}},
+		}}})
+	})
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{})
+	})
+	var creates atomic.Int64
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		// The first 3 creates succeed, the others fail.
+		if id := creates.Add(1); id <= 3 {
+			writeJSON(t, w, http.StatusCreated, schema.ServerCreateResponse{
+				Server: testServer(id, "pool1"),
+				Action: schema.Action{ID: id, Status: string(hcloud.ActionStatusSuccess)},
+			})
+			return
+		}
+		writeJSON(t, w, http.StatusPreconditionFailed, schema.ErrorResponse{Error: schema.Error{
+			Code:    string(hcloud.ErrorCodeResourceUnavailable),
+			Message: "server type cx22 is unavailable in fsn1",
+		}})
+	})
+	mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected delete of server %s", r.PathValue("id"))
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs:      map[string]*NodeConfig{"pool1": {}},
+	}
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            5,
+		instanceType:       "cx22",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	require.NoError(t, nodeGroup.IncreaseSize(5))
+	assert.Equal(t, int64(5), creates.Load())
+	// The servers that were created are kept and the failed ones are reported
+	// as placeholders with the create error, both counted in the target size.
+	assert.Equal(t, 5, nodeGroup.targetSize)
+	instances, err := nodeGroup.Nodes()
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	for _, instance := range instances {
+		assert.Equal(t, "server type cx22 is unavailable in fsn1", instance.Status.ErrorInfo.ErrorMessage)
+	}
+	assert.Equal(t, "server type cx22 is unavailable in fsn1", nodeGroup.LastError().ErrorMessage)
+}
+
+func TestIncreaseSizeServerQuota(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		// Servers outside of the cluster count towards the quota too.
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: []schema.Server{
+			testServer(1, "pool1"), testServer(2, "pool1"), testServer(3, "other"), testServer(4, ""),
+		}})
+	})
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected server create")
+	})
+
+	manager := newTestManager(t, mux)
+	manager.serverQuota = 5
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            5,
+		targetSize:         2,
+		instanceType:       "cx22",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	err := nodeGroup.IncreaseSize(2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "creating 2 servers would exceed quota: 4 of 5 servers in use")
+	assert.Equal(t, 2, nodeGroup.targetSize)
+}
+
+func TestIncreaseSizeFallbackLocations(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /server_types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerTypeListResponse{ServerTypes: []schema.ServerType{{
+			ID:           1,
+			Name:         "cx22",
+			Architecture: string(hcloud.ArchitectureX86),
+			Prices:       []schema.PricingServerTypePrice{{Location: "fsn1"}, {Location: "nbg1"}},
+		}}})
+	})
+	mux.HandleFunc("GET /images", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ImageListResponse{Images: []schema.Image{
+			{ID: 1, Name: hcloud.Ptr("ubuntu-22.04"), Architecture: string(hcloud.ArchitectureX86)},
+		}})
+	})
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{})
+	})
+	var locations []string
+	mux.HandleFunc("POST /servers", func(w http.ResponseWriter, r *http.Request) {
+		var req schema.ServerCreateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		locations = append(locations, req.Location)
+		if req.Location == "fsn1" {
+			writeJSON(t, w, http.StatusPreconditionFailed, schema.ErrorResponse{Error: schema.Error{
+				Code:    string(hcloud.ErrorCodeResourceUnavailable),
+				Message: "server type cx22 is unavailable in fsn1",
+			}})
+			return
+		}
+		writeJSON(t, w, http.StatusCreated, schema.ServerCreateResponse{
+			Server: testServer(1, "pool1"),
+			Action: schema.Action{ID: 1, Status: string(hcloud.ActionStatusSuccess)},
+		})
+	})
+
+	manager := newTestManager(t, mux)
+	manager.clusterConfig = &ClusterConfig{
+		IsUsingNewFormat: true,
+		ImagesForArch:    ImageList{Amd64: "ubuntu-22.04"},
+		NodeConfigs:      map[string]*NodeConfig{"pool1": {FallbackLocations: []string{"NBG1", "hel1"}}},
+	}
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		maxSize:            3,
+		instanceType:       "cx22",
+		region:             "fsn1",
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	require.NoError(t, nodeGroup.IncreaseSize(1))
+	assert.Equal(t, []string{"fsn1", "nbg1"}, locations)
+	assert.Equal(t, 1, nodeGroup.targetSize)
+	assert.Nil(t, nodeGroup.LastError())
+}
+
+func TestDeleteNodesConcurrencyLimit(t *testing.T) {
+	servers := make([]schema.Server, 0, 20)
+	nodes := make([]*apiv1.Node, 0, 20)
+	for id := int64(1); id <= 20; id++ {
+		servers = append(servers, testServer(id, "pool1"))
+		nodes = append(nodes, testNode(fmt.Sprintf("node-%d", id), id))
+	}
+	var mu sync.Mutex
+	var deleted []int64
+	inFlight, maxInFlight := 0, 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{Servers: servers})
+	})
+	mux.HandleFunc("DELETE /servers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		require.NoError(t, err)
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		// Servers 7 and 13 are locked by another action and fail to be deleted.
+		if id == 7 || id == 13 {
+			writeJSON(t, w, http.StatusLocked, schema.ErrorResponse{Error: schema.Error{
+				Code:    string(hcloud.ErrorCodeLocked),
+				Message: "server is locked",
+			}})
+			return
+		}
+		deleted = append(deleted, id)
+		writeJSON(t, w, http.StatusOK, schema.ServerDeleteResponse{Action: schema.Action{ID: id, Status: "running"}})
+	})
+	mux.HandleFunc("GET /actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, actionsResponse(t, r, hcloud.ActionStatusSuccess))
+	})
+
+	manager := newTestManager(t, mux)
+	manager.deleteConcurrency = 3
+	nodeGroup := &hetznerNodeGroup{
+		id:                 "pool1",
+		manager:            manager,
+		minSize:            0,
+		maxSize:            20,
+		targetSize:         20,
+		clusterUpdateMutex: &sync.Mutex{},
+	}
+
+	err := nodeGroup.DeleteNodes(nodes)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to delete server ID 7 for node node-7")
+	assert.Contains(t, err.Error(), "failed to delete server ID 13 for node node-13")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, deleted, 18)
+	assert.LessOrEqual(t, maxInFlight, 3)
+	assert.Positive(t, maxInFlight)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenSource returns the current Hetzner Cloud token.
+type tokenSource func() (string, error)
+
+// fileTokenSource returns a tokenSource reading the token from the file at path.
+// The file is read again whenever it's modified, so that the token can be rotated
+// without restarting.
+func fileTokenSource(path string) tokenSource {
+	var mutex sync.Mutex
+	var token string
+	var modTime time.Time
+	var size int64
+	return func() (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file %s: %v", path, err)
+		}
+		if token != "" && info.ModTime().Equal(modTime) && info.Size() == size {
+			return token, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file %s: %v", path, err)
+		}
+		newToken := strings.TrimSpace(string(content))
+		if newToken == "" {
+			return "", fmt.Errorf("token file %s is empty", path)
+		}
+		token, modTime, size = newToken, info.ModTime(), info.Size()
+		return token, nil
+	}
+}
+
+// tokenRoundTripper authenticates each request with the current token of source.
+func tokenRoundTripper(source tokenSource, next http.RoundTripper) roundTripperFunc {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		token, err := source()
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+		return next.RoundTrip(r)
+	})
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud"
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider/hetzner/hcloud-go/hcloud/schema"
+)
+
+func TestFileTokenSourceRotation(t *testing.T) {
+	var authorizations []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /servers", func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		writeJSON(t, w, http.StatusOK, schema.ServerListResponse{})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("old-token\n"), 0600))
+	source := fileTokenSource(tokenFile)
+	token, err := source()
+	require.NoError(t, err)
+	assert.Equal(t, "old-token", token)
+
+	client := hcloud.NewClient(
+		hcloud.WithEndpoint(server.URL),
+		hcloud.WithToken(token),
+		hcloud.WithHTTPClient(&http.Client{Transport: tokenRoundTripper(source, http.DefaultTransport)}),
+	)
+	_, err = client.Server.All(context.Background())
+	require.NoError(t, err)
+
+	// Rotate the token. The modification time is moved forward, as the file may be rewritten within its resolution.
+	require.NoError(t, os.WriteFile(tokenFile, []byte("new-token\n"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(tokenFile, later, later))
+	_, err = client.Server.All(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Bearer old-token", "Bearer new-token"}, authorizations)
+
+	// Requests fail rather than using a stale token once the file can't be read.
+	require.NoError(t, os.Remove(tokenFile))
+	_, err = client.Server.All(context.Background())
+	assert.Error(t, err)
+	assert.Len(t, authorizations, 2)
+}
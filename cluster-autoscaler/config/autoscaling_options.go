@@ -306,4 +306,8 @@ type KubeClientOptions struct {
 	KubeClientBurst int
 	// QPS setting for kubernetes client
 	KubeClientQPS float32
+	// Burst setting for the kubernetes client used for pod evictions. Unused if EvictionKubeClientQPS is 0.
+	EvictionKubeClientBurst int
+	// QPS setting for the kubernetes client used for pod evictions. If 0, evictions share the main kubernetes client.
+	EvictionKubeClientQPS float32
 }
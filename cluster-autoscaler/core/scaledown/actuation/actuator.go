@@ -40,6 +40,7 @@ import (
 	"k8s.io/autoscaler/cluster-autoscaler/utils/expiring"
 	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/taints"
+	"k8s.io/client-go/discovery"
 	"k8s.io/klog/v2"
 )
 
@@ -74,11 +75,20 @@ type actuatorNodeGroupConfigGetter interface {
 func NewActuator(ctx *context.AutoscalingContext, scaleStateNotifier nodegroupchange.NodeGroupChangeObserver, ndt *deletiontracker.NodeDeletionTracker, deleteOptions options.NodeDeleteOptions, drainabilityRules rules.Rules, configGetter actuatorNodeGroupConfigGetter) *Actuator {
 	ndb := NewNodeDeletionBatcher(ctx, scaleStateNotifier, ndt, ctx.NodeDeletionBatcherInterval)
 	legacyFlagDrainConfig := SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec)
+	discoveryClient := evictionDiscoveryClient(ctx)
 	var evictor Evictor
 	if len(ctx.DrainPriorityConfig) > 0 {
-		evictor = NewEvictor(ndt, ctx.DrainPriorityConfig, true)
+		evictor = NewEvictor(ndt, ctx.DrainPriorityConfig, true, discoveryClient)
 	} else {
-		evictor = NewEvictor(ndt, legacyFlagDrainConfig, false)
+		evictor = NewEvictor(ndt, legacyFlagDrainConfig, false, discoveryClient)
+	}
+	if ctx.KubeClientOpts.EvictionKubeClientQPS > 0 {
+		evictionClientSet, err := NewEvictionClientSet(kube_util.GetKubeConfig(ctx.KubeClientOpts), ctx.KubeClientOpts.EvictionKubeClientQPS, ctx.KubeClientOpts.EvictionKubeClientBurst)
+		if err != nil {
+			klog.Errorf("Failed to create eviction client, pod evictions will use the main client: %v", err)
+		} else {
+			evictor.EvictionClientSet = evictionClientSet
+		}
 	}
 	return &Actuator{
 		ctx:                       ctx,
@@ -93,6 +103,19 @@ func NewActuator(ctx *context.AutoscalingContext, scaleStateNotifier nodegroupch
 	}
 }
 
+// evictionDiscoveryClient returns the discovery client used to detect the eviction API version, or nil if the
+// client set doesn't talk to an API server, like the fake client sets used in tests.
+func evictionDiscoveryClient(ctx *context.AutoscalingContext) discovery.DiscoveryInterface {
+	if ctx.ClientSet == nil {
+		return nil
+	}
+	discoveryClient := ctx.ClientSet.Discovery()
+	if discoveryClient == nil || discoveryClient.RESTClient() == nil {
+		return nil
+	}
+	return discoveryClient
+}
+
 // CheckStatus should returns an immutable snapshot of ongoing deletions.
 func (a *Actuator) CheckStatus() scaledown.ActuationStatus {
 	return a.nodeDeletionTracker.Snapshot()
@@ -334,7 +357,7 @@ func (a *Actuator) scaleDownNodeToReport(node *apiv1.Node, drain bool) (*status.
 	}
 	var evictedPods []*apiv1.Pod
 	if drain {
-		_, nonDsPodsToEvict := podsToEvict(nodeInfo, a.ctx.DaemonSetEvictionForOccupiedNodes)
+		_, nonDsPodsToEvict := podsToEvict(nodeInfo, a.ctx.DaemonSetEvictionForOccupiedNodes, "")
 		evictedPods = nonDsPodsToEvict
 	}
 	return &status.ScaleDownNode{
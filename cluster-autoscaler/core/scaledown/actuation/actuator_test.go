@@ -1282,7 +1282,7 @@ func TestStartDeletion(t *testing.T) {
 
 				// Gather node deletion results for deletions started in the previous call, and verify that they look as expected.
 				nodeDeleteResults, _ := actuator.DeletionResults()
-				if diff := cmp.Diff(tc.wantNodeDeleteResults, nodeDeleteResults, cmpopts.EquateEmpty(), cmpopts.EquateErrors()); diff != "" {
+				if diff := cmp.Diff(tc.wantNodeDeleteResults, nodeDeleteResults, cmpopts.EquateEmpty(), cmpopts.EquateErrors(), cmpopts.IgnoreFields(status.PodEvictionResult{}, "DrainID")); diff != "" {
 					t.Errorf("NodeDeleteResults diff (-want +got):\n%s", diff)
 				}
 			})
@@ -18,21 +18,44 @@ package actuation
 
 import (
 	"context"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
 	kube_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/autoscaler/cluster-autoscaler/metrics"
+	"k8s.io/client-go/discovery"
+	kube_client "k8s.io/client-go/kubernetes"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
 	kubelet_config "k8s.io/kubernetes/pkg/kubelet/apis/config"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
 
 	acontext "k8s.io/autoscaler/cluster-autoscaler/context"
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/pdb"
 	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/status"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/daemonset"
+	"k8s.io/autoscaler/cluster-autoscaler/utils/drain"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/errors"
 	pod_util "k8s.io/autoscaler/cluster-autoscaler/utils/pod"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
@@ -44,164 +67,1936 @@ const (
 	// DefaultPodEvictionHeadroom is the extra time we wait to catch situations when the pod is ignoring SIGTERM and
 	// is killed with SIGKILL after GracePeriodSeconds elapses
 	DefaultPodEvictionHeadroom = 30 * time.Second
+	// defaultPollInterval is the interval between checks whether evicted pods are gone if no bounds are configured.
+	defaultPollInterval = 5 * time.Second
+	// EvictionHeadroomAnnotationKey is the node annotation overriding PodEvictionHeadroom, in seconds, for pods
+	// evicted from that node.
+	EvictionHeadroomAnnotationKey = "cluster-autoscaler.kubernetes.io/eviction-headroom-seconds"
+	// DrainStartedAtAnnotationKey is the node annotation holding the time CA started draining the node, if
+	// AnnotateDrainTimes is enabled.
+	DrainStartedAtAnnotationKey = "cluster-autoscaler.kubernetes.io/drain-started-at"
+	// DrainFinishedAtAnnotationKey is the node annotation holding the time CA finished draining the node,
+	// successfully or not, if AnnotateDrainTimes is enabled.
+	DrainFinishedAtAnnotationKey = "cluster-autoscaler.kubernetes.io/drain-finished-at"
+	// ForceDeletedPodsAnnotationKey is the node annotation listing the pods, as comma-separated namespace/name, CA
+	// force deleted from the node after ForceDrainAfter, so that operators can investigate why evicting them failed.
+	ForceDeletedPodsAnnotationKey = "cluster-autoscaler.kubernetes.io/force-deleted-pods"
+	// EvictLastAnnotationKey is the default EvictLastAnnotation, for pods like logging or service mesh sidecars
+	// that must outlive the other pods of the node.
+	EvictLastAnnotationKey = "cluster-autoscaler.kubernetes.io/evict-last"
 )
 
+// LocalStoragePolicy controls how DrainNode treats pods using local storage (emptyDir or hostPath volumes),
+// whose data is lost once they are evicted.
+type LocalStoragePolicy int
+
+const (
+	// LocalStorageEvict evicts pods using local storage like any other pod.
+	LocalStorageEvict LocalStoragePolicy = iota
+	// LocalStorageWarnAndEvict evicts pods using local storage, logging a warning for each of them.
+	LocalStorageWarnAndEvict
+	// LocalStorageBlock refuses to drain a node hosting pods using local storage.
+	LocalStorageBlock
+)
+
+// WithinGroupMode controls how pods within a single drain priority group are evicted.
+type WithinGroupMode int
+
+const (
+	// WithinGroupParallel evicts all pods of a group at once.
+	WithinGroupParallel WithinGroupMode = iota
+	// WithinGroupSequential evicts pods of a group one at a time, waiting for each to disappear before
+	// evicting the next one.
+	WithinGroupSequential
+	// WithinGroupPdbWaves evicts pods of a group in waves sized to the disruptions currently allowed by their
+	// PodDisruptionBudgets, waiting for each wave to disappear before evicting the next one. Pods not covered
+	// by any PDB are evicted in the first wave.
+	WithinGroupPdbWaves
+	// WithinGroupTopologySpreadWaves evicts pods of a group in waves holding at most MaxSkew pods matched by each of
+	// their topology spread constraints, waiting for each wave to disappear before evicting the next one, so that
+	// evicting a workload doesn't skew its spread across the cluster more than it allows. Pods without topology
+	// spread constraints are evicted in the first wave.
+	WithinGroupTopologySpreadWaves
+)
+
+// EvictionMethod controls how pods are removed from a node being drained.
+type EvictionMethod int
+
+const (
+	// EvictionAPI removes pods through the Eviction API, which enforces PodDisruptionBudgets.
+	EvictionAPI EvictionMethod = iota
+	// GracefulDelete removes pods by deleting them with the computed grace period. It bypasses the Eviction
+	// API, so PodDisruptionBudgets are NOT enforced and draining can take down more replicas of a workload
+	// than its PDB allows. It's meant for clusters where the eviction endpoint is slow or PDBs are misconfigured.
+	GracefulDelete
+)
+
+// NodeGroupEvictionPolicy overrides parts of the Evictor configuration for the nodes of a node group. Unset fields
+// keep the Evictor's configuration.
+type NodeGroupEvictionPolicy struct {
+	EvictionMethod  *EvictionMethod
+	ForceDrainAfter *time.Duration
+	// ShutdownGracePeriodByPodPriority replaces the drain priority groups and their grace periods, if it's set.
+	ShutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority
+	// MaxPodEvictionTime replaces the MaxPodEvictionTime of the autoscaling context, if it's set, e.g. to give up
+	// on evictions sooner for spot node groups reclaimed shortly after their notice.
+	MaxPodEvictionTime *time.Duration
+}
+
+// GraceClampPolicy controls how the grace period of an evicted pod is derived from its own
+// terminationGracePeriodSeconds and the grace period of its drain priority group.
+type GraceClampPolicy int
+
+const (
+	// ClampDown uses the pod's own grace period, clamped down to the group's grace period.
+	ClampDown GraceClampPolicy = iota
+	// NeverShorten uses the larger of the pod's own grace period and the group's grace period, so that a pod's
+	// requested graceful shutdown is never shortened. Draining still waits only for the group's grace period plus
+	// PodEvictionHeadroom for pods to disappear.
+	NeverShorten
+	// UseGroup uses the group's grace period regardless of the pod's own grace period.
+	UseGroup
+)
+
+// SystemCriticalPolicy controls how DrainNode treats system-critical pods, i.e. pods with a priority of at least
+// that of the system-cluster-critical priority class.
+type SystemCriticalPolicy int
+
+const (
+	// SystemCriticalByPriority evicts system-critical pods like any other pod, in the group of their priority.
+	SystemCriticalByPriority SystemCriticalPolicy = iota
+	// SystemCriticalBlock refuses to drain a node hosting system-critical pods, unless they're only evicted best effort.
+	SystemCriticalBlock
+	// SystemCriticalEvictLast evicts system-critical pods in a group of their own after all other groups, with the
+	// grace period of the last group.
+	SystemCriticalEvictLast
+	// SystemCriticalBestEffort evicts system-critical pods best effort, so failing to evict them doesn't fail the
+	// drain and they aren't waited for.
+	SystemCriticalBestEffort
+)
+
+// APIErrorClass is the category of an error returned by the API server when evicting or deleting a pod.
+type APIErrorClass int
+
+const (
+	// APIErrorRetryable errors may go away by retrying, e.g. timeouts, throttling or PDB violations.
+	APIErrorRetryable APIErrorClass = iota
+	// APIErrorPermanent errors won't go away by retrying, so the pod isn't retried.
+	APIErrorPermanent
+	// APIErrorSuccess errors mean the pod is gone anyway, e.g. NotFound, so they count as success.
+	APIErrorSuccess
+)
+
+// ForceDeleteTrigger is a reason for which draining escalates to force deleting a pod, see ForceDeleteTriggers.
+type ForceDeleteTrigger int
+
+const (
+	// ForceDeleteOnTimeout force deletes pods still present ForceDrainAfter after their eviction.
+	ForceDeleteOnTimeout ForceDeleteTrigger = iota
+	// ForceDeleteOnAPIError force deletes pods whose eviction failed with an API error other than a PDB violation.
+	ForceDeleteOnAPIError
+	// ForceDeleteOnPDBViolation force deletes pods whose eviction was refused by a PodDisruptionBudget until
+	// MaxPodEvictionTime, which takes down more replicas of the workload than the PDB allows.
+	ForceDeleteOnPDBViolation
+)
+
+// ErrDrainCancelled is returned by DrainNodeWithCancel if the drain was cancelled.
+var ErrDrainCancelled = errors.NewAutoscalerError(errors.TransientError, "drain cancelled")
+
+// ErrDrainTimeout is wrapped by the errors returned by DrainNode if pods were still on the node once the drain
+// timed out, so that callers can detect it with errors.Is.
+var ErrDrainTimeout = errors.NewAutoscalerError(errors.TransientError, "drain timed out")
+
+// errNodeDeleted is returned by waitPodsToDisappear if the node was deleted while waiting for its pods.
+var errNodeDeleted = errors.NewAutoscalerError(errors.TransientError, "node deleted")
+
 type evictionRegister interface {
 	RegisterEviction(*apiv1.Pod)
 }
 
-// Evictor keeps configurations of pod eviction
-type Evictor struct {
-	EvictionRetryTime                time.Duration
-	PodEvictionHeadroom              time.Duration
-	evictionRegister                 evictionRegister
-	shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority
-	fullDsEviction                   bool
+// AuditInitiator is the initiator of the evictions recorded to an AuditSink.
+const AuditInitiator = "cluster-autoscaler"
+
+// EvictionRecord describes a completed eviction of a pod, for an audit trail.
+type EvictionRecord struct {
+	// Time is when the pod was evicted or force deleted.
+	Time      time.Time
+	Namespace string
+	Name      string
+	UID       types.UID
+	NodeName  string
+	// Reason is ScaleDown for evictions, and ScaleDownForceDelete for pods force deleted after ForceDrainAfter.
+	Reason string
+	// Initiator is always AuditInitiator.
+	Initiator          string
+	GracePeriodSeconds int64
+	Forced             bool
+}
+
+// AuditSink receives a record of every pod evicted or force deleted while draining.
+type AuditSink interface {
+	RecordEviction(record EvictionRecord)
+}
+
+// Evictor keeps configurations of pod eviction
+type Evictor struct {
+	EvictionRetryTime   time.Duration
+	PodEvictionHeadroom time.Duration
+	// PodEvictionHeadroomFraction, if positive, makes the eviction headroom of each priority group this fraction of
+	// the group's grace period instead of PodEvictionHeadroom, bounded by MinPodEvictionHeadroom and
+	// MaxPodEvictionHeadroom. Zero MaxPodEvictionHeadroom means no upper bound. The node's
+	// EvictionHeadroomAnnotationKey annotation still takes precedence.
+	PodEvictionHeadroomFraction float64
+	MinPodEvictionHeadroom      time.Duration
+	MaxPodEvictionHeadroom      time.Duration
+	// MinGracePeriodSecondsByPriority maps the priority of a drain priority group to the minimum grace
+	// period given to pods in that group, so that pods with a tiny terminationGracePeriodSeconds still
+	// get a reasonable amount of time to shut down. The floor never exceeds the group's grace period.
+	MinGracePeriodSecondsByPriority map[int32]int64
+	// EnsureCordoned makes DrainNode verify that the node is unschedulable before evicting any pods,
+	// so that evicted pods are not rescheduled back onto the node being drained.
+	EnsureCordoned bool
+	// AutoCordon makes DrainNode cordon a node that isn't cordoned yet. If it's disabled, DrainNode
+	// fails for such a node instead. Only used if EnsureCordoned is enabled.
+	AutoCordon bool
+	// CordonSettle is how long to wait after cordoning a node before evicting any of its pods, giving the scheduler's
+	// informers time to observe the node as unschedulable so that evicted pods aren't rescheduled back onto it. It's
+	// only waited for if DrainNode cordoned the node itself, see AutoCordon. Zero means no wait.
+	CordonSettle time.Duration
+	// LocalStoragePolicy controls how pods using local storage are handled by DrainNode. Pods that mark
+	// their volumes as safe to evict with the safe-to-evict-local-volumes annotation are never affected.
+	LocalStoragePolicy LocalStoragePolicy
+	// TotalDrainTimeout bounds the time spent draining a node across all priority groups. Once it's exceeded,
+	// the remaining groups are abandoned. Zero means no limit.
+	TotalDrainTimeout time.Duration
+	// MaxConcurrentEvictionsPerOwner caps the number of in-flight evictions of pods controlled by the same
+	// owner, to avoid briefly taking down too many replicas of a workload at once. Zero means no limit.
+	MaxConcurrentEvictionsPerOwner int
+	// ReclassifyPod is consulted for each full eviction pod right before its eviction starts. Returning false
+	// downgrades the pod to best effort eviction, so failing to evict it no longer fails the drain. Pods
+	// can't be upgraded from best effort to full eviction. If nil, the original classification is kept.
+	ReclassifyPod func(pod *apiv1.Pod) (fullEviction bool)
+	// WithinGroupMode controls whether pods within a priority group are evicted in parallel, sequentially or in PDB sized waves.
+	WithinGroupMode WithinGroupMode
+	// SequentialEvictionDelay is the pause between evicting consecutive pods of a group in WithinGroupSequential mode.
+	SequentialEvictionDelay time.Duration
+	// MaxPodsEvictedPerWave caps how many full eviction pods of a node are evicted at a time, whatever the
+	// WithinGroupMode. Pods are evicted in waves of at most that many pods, waiting for each wave to disappear before
+	// evicting the next one. Best effort pods are still evicted at once. If 0, waves aren't capped.
+	MaxPodsEvictedPerWave int
+	// StrictNotFound makes an eviction returning NotFound count as successful only if the pod is confirmed to be
+	// gone, since a controller may have already recreated a pod with the same name.
+	StrictNotFound bool
+	// EvictionClientSet, if set, is used for the pod Evict and Get calls made while draining, so that eviction
+	// load is rate limited separately from the rest of CA. If nil, the AutoscalingContext ClientSet is used.
+	EvictionClientSet kube_client.Interface
+	// MinPollInterval and MaxPollInterval bound the interval between checks whether evicted pods are gone. The
+	// interval starts at MaxPollInterval and shrinks with the remaining wait time down to MinPollInterval, so that
+	// pods disappearing close to the deadline are noticed quickly. MaxPollInterval defaults to 5 seconds and
+	// MinPollInterval to MaxPollInterval, which makes the interval fixed.
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration
+	// SlowTerminationCallback, if set, is called when an evicted pod is still present past its own termination
+	// grace period, with the time it's overdue by. It's called at most once per pod, useful for alerting on
+	// stuck terminations before the eviction headroom runs out.
+	SlowTerminationCallback func(pod *apiv1.Pod, overBy time.Duration)
+	// AnnotateDrainTimes makes draining annotate the node with the times it started and finished, for postmortems.
+	// Failing to annotate the node doesn't fail the drain.
+	AnnotateDrainTimes bool
+	// SkipAbsentPods makes evictions check that each pod is still on the node first, so that draining a node
+	// again after a failure doesn't evict pods that are already gone. Absent pods count as evicted.
+	SkipAbsentPods bool
+	// EvictionMethod controls whether pods are evicted through the Eviction API or deleted directly. See
+	// GracefulDelete for the PodDisruptionBudget caveat.
+	EvictionMethod EvictionMethod
+	// NamespaceEvictionQPS and NamespaceEvictionBurst pace the start of evictions within each namespace
+	// separately, so that a namespace with many pods doesn't starve the others. MaxConcurrentEvictionsPerOwner
+	// still applies on top of it. Zero NamespaceEvictionQPS means no limit, and the burst defaults to 1.
+	NamespaceEvictionQPS   float32
+	NamespaceEvictionBurst int
+	// TolerationGate, if set, restricts draining to pods tolerating this taint, leaving the other pods for an
+	// external system coordinating through the taint. Mirror pods are never evicted regardless.
+	TolerationGate *apiv1.Taint
+	// EvictLastAnnotation, if set, is the pod annotation hinting that a pod prefers to stay on the node as long as
+	// possible. Pods with it set to "true" are evicted regardless of their priority in a group of their own after
+	// the last priority group, with that group's grace period, once the other pods are gone.
+	EvictLastAnnotation string
+	// MaxEvictionRetries caps the number of times a failed eviction of a pod is retried, in addition to the
+	// retry time limit. Zero means no cap. Permanent errors, like Forbidden or Invalid, are never retried.
+	MaxEvictionRetries int
+	// GroupRetryBudget caps the number of eviction retries shared by all pods of a priority group, so that many
+	// pods blocked at once, e.g. by a PDB, don't flood the API server with retries. Pods left without retries once
+	// it's used up fail as timed out. Zero means no cap.
+	GroupRetryBudget int
+	// WaitForVolumeDetach makes draining wait, after a pod is gone, until the persistent volumes it used are
+	// detached from the node, as seen in VolumeAttachments. Until then the pod isn't considered drained, so
+	// that the node isn't deleted while the volumes could still be attached, causing multi-attach errors.
+	WaitForVolumeDetach bool
+	// CheckNodeDeletion makes waiting for evicted pods check on every poll whether the node was deleted out-of-band,
+	// in which case its pods count as gone even if they are still reported on it. Otherwise the node is only
+	// checked once a poll finds a pod gone or on another node.
+	CheckNodeDeletion bool
+	// VolumeAttachmentLister, if set, is used to check VolumeAttachments for WaitForVolumeDetach, so that polling
+	// doesn't list them from the API server. It's typically backed by a shared informer. If nil, VolumeAttachments
+	// are listed from the API server on every check.
+	VolumeAttachmentLister storagelisters.VolumeAttachmentLister
+	// EvictionMetricsNamespaces enables counting evictions per namespace, for chargeback. Evictions in namespaces
+	// not in the set are counted under a shared "other" namespace label. If it's empty, evictions aren't
+	// counted per namespace.
+	EvictionMetricsNamespaces map[string]bool
+	// ForceDrainAfter, if set, escalates draining to force deletion of the pods still present this long after
+	// their eviction, instead of waiting for them until the timeout. Force deleted pods are deleted with a zero
+	// grace period, so they don't get to shut down cleanly.
+	ForceDrainAfter time.Duration
+	// ForceDeleteTriggers selects the failures escalating to force deletion once ForceDrainAfter is set. Pods
+	// whose eviction failed for a selected reason are force deleted right away and waited for like evicted pods,
+	// the others fail the drain as usual. If it's empty, only ForceDeleteOnTimeout is selected.
+	ForceDeleteTriggers map[ForceDeleteTrigger]bool
+	// ForceDeleteAllowedPriorityClasses, if set, restricts force deletion to pods of these priority classes. Pods
+	// of other classes are waited for until the timeout instead.
+	ForceDeleteAllowedPriorityClasses map[string]bool
+	// ForceDeletePropagationPolicy, if set, is the propagation policy pods are force deleted with, e.g. Foreground
+	// or Orphan for dependents that need it to be cleaned up correctly. The API server default is used if nil.
+	ForceDeletePropagationPolicy *metav1.DeletionPropagation
+	// BestEffortWait is how long to wait after evicting a priority group with only best effort pods, e.g.
+	// DaemonSet pods when fullDsEviction is off, which otherwise isn't waited for at all. A brief wait reduces
+	// noisy DaemonSet restarts. Zero means no wait.
+	BestEffortWait time.Duration
+	// PostDrainSettle is how long to wait once all pods of the node are gone before reporting the drain as complete,
+	// giving system components still terminating on the node time to settle before it's deleted. If
+	// PostDrainSettleCondition is set, the wait ends early once the node has that condition with status True. The
+	// drain completes anyway once PostDrainSettle is over. Zero means no wait.
+	PostDrainSettle          time.Duration
+	PostDrainSettleCondition apiv1.NodeConditionType
+	// JobEvictionDelay delays the eviction of pods controlled by a Job, giving them a chance to complete instead of
+	// losing their work. Pods that completed or are gone by then aren't evicted. Zero means no delay.
+	JobEvictionDelay time.Duration
+	// JobNearCompletionCondition, if set, limits JobEvictionDelay to Job pods reporting this condition as true, so
+	// that only Job pods about to complete are waited for and the others are evicted right away.
+	JobNearCompletionCondition apiv1.PodConditionType
+	// EvictUnreadyImmediately makes pods that haven't been ready for longer than UnreadyThreshold get evicted with
+	// UnreadyGracePeriodSeconds instead of their regular grace period, so that crash-looping or stuck pods don't
+	// hold up draining a broken node. Zero UnreadyGracePeriodSeconds means no grace period at all.
+	EvictUnreadyImmediately   bool
+	UnreadyThreshold          time.Duration
+	UnreadyGracePeriodSeconds int64
+	// EvictPendingImmediately makes pods in the Pending phase, which have no started containers to shut down
+	// gracefully, get evicted with no grace period.
+	EvictPendingImmediately bool
+	// DaemonSetGracePeriodSeconds, if set, overrides the grace period of DaemonSet pods evicted on a best effort
+	// basis, which often terminate quickly and don't need their group's grace period.
+	DaemonSetGracePeriodSeconds *int64
+	// StaticPodDrainWindow is how long to wait before deleting a drained node hosting static pods. Static pods
+	// aren't evicted, so this gives the services they run a chance to drain before the node goes away. Zero
+	// means no wait.
+	StaticPodDrainWindow time.Duration
+	// RequeueFailedEvictions makes full evictions that fail transiently, e.g. with 429 due to a PDB, get requeued
+	// on their first failure and retried once all other evictions of their priority group are initiated, as evicting
+	// their peers may free up disruption budget. The retries are still bounded by MaxPodEvictionTime.
+	RequeueFailedEvictions bool
+	// GraceClampPolicy controls how the grace period of evicted pods is derived, ClampDown by default.
+	GraceClampPolicy GraceClampPolicy
+	// FinalizerPodsDrained makes evicted pods that are terminating with all their containers stopped, but are
+	// held by finalizers, count as drained instead of being waited for until the timeout.
+	FinalizerPodsDrained bool
+	// RemoveFinalizer, if set, is a finalizer removed from evicted pods held by it the same way, so that they
+	// can go away.
+	RemoveFinalizer string
+	// EvictLargestFirst orders the pods of each priority group by descending CPU and memory requests, so that
+	// evicting the group frees the most requested capacity first. It's mostly useful with WithinGroupSequential
+	// or the per owner and namespace eviction limits, as the evictions of a group otherwise start all at once.
+	EvictLargestFirst bool
+	// NamespacePriority, if set, orders evictions across namespaces within each priority group. Pods in a namespace
+	// listed earlier are evicted and waited for before pods in namespaces listed later, and pods in namespaces not
+	// listed come last. Each namespace gets the full grace period of the group. If empty, pods are ordered by
+	// priority only.
+	NamespacePriority []string
+	// APIErrorClassifier, if set, overrides ClassifyAPIError for errors of pod evictions and force deletions. It's
+	// only called with non-nil errors.
+	APIErrorClassifier func(err error) APIErrorClass
+	// SystemCriticalPolicy controls how system-critical pods are drained, SystemCriticalByPriority by default.
+	SystemCriticalPolicy SystemCriticalPolicy
+	// OrphanedPodsBestEffort evicts pods whose controller is gone on a best effort basis, since nothing recreates
+	// them anyway, so that failing to evict them doesn't fail the drain.
+	OrphanedPodsBestEffort bool
+	// DrainLimiter, if set, bounds the number of drains running at once. It can be shared by several Evictors, so
+	// that mass scale-downs don't overwhelm the API server. Drains beyond the limit wait for a running one to finish.
+	DrainLimiter *DrainLimiter
+	// AuditSink, if set, is called with a record of each completed eviction and force deletion, e.g. for compliance.
+	// It's called concurrently from the eviction goroutines. If nil, evictions aren't recorded.
+	AuditSink AuditSink
+	// NodeGroupPolicies overrides the configuration for the nodes of the node groups it holds a policy for, keyed
+	// by node group ID. The node group of a node is read from its NodeGroupLabel label when draining it.
+	NodeGroupLabel    string
+	NodeGroupPolicies map[string]NodeGroupEvictionPolicy
+	// OwnerEvents makes each drain emit an event summarizing its evictions on the top-level owner of the evicted pods,
+	// e.g. their Deployment, for teams watching their workloads rather than the pods, which are gone.
+	OwnerEvents bool
+	// Tracer, if set, keeps a trace of the plan and outcome of the last drain of each node, for debugging.
+	Tracer *DrainTracer
+	// drains tracks the in-flight drains, so that Shutdown can cancel them. It's shared by all copies of an Evictor
+	// created by NewEvictor. Drains aren't tracked if it's nil.
+	drains *drainTracker
+	// clock is used for timing evictions. The real clock is used if it's nil.
+	clock                            clock.Clock
+	evictionRegister                 evictionRegister
+	shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority
+	fullDsEviction                   bool
+	// evictionGroupVersion is the policy API version used for evictions. policy/v1beta1 is used if it's empty.
+	evictionGroupVersion schema.GroupVersion
+}
+
+// NewEvictor returns an instance of Evictor. The eviction API version is detected once here using discoveryClient.
+func NewEvictor(evictionRegister evictionRegister, shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority, fullDsEviction bool, discoveryClient discovery.DiscoveryInterface) Evictor {
+	sort.Slice(shutdownGracePeriodByPodPriority, func(i, j int) bool {
+		return shutdownGracePeriodByPodPriority[i].Priority < shutdownGracePeriodByPodPriority[j].Priority
+	})
+
+	return Evictor{
+		EvictionRetryTime:                DefaultEvictionRetryTime,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		EvictLastAnnotation:              EvictLastAnnotationKey,
+		evictionRegister:                 evictionRegister,
+		shutdownGracePeriodByPodPriority: shutdownGracePeriodByPodPriority,
+		fullDsEviction:                   fullDsEviction,
+		evictionGroupVersion:             evictionGroupVersion(discoveryClient),
+		drains:                           newDrainTracker(),
+	}
+}
+
+// Validate returns an error if the Evictor configuration is invalid.
+func (e Evictor) Validate() errors.AutoscalerError {
+	if e.EvictionRetryTime <= 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "eviction retry time must be positive, got %v", e.EvictionRetryTime)
+	}
+	if e.PodEvictionHeadroom < 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "pod eviction headroom can't be negative, got %v", e.PodEvictionHeadroom)
+	}
+	if e.PodEvictionHeadroomFraction < 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "pod eviction headroom fraction can't be negative, got %v", e.PodEvictionHeadroomFraction)
+	}
+	if e.MaxPodEvictionHeadroom > 0 && e.MinPodEvictionHeadroom > e.MaxPodEvictionHeadroom {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "min pod eviction headroom %v can't exceed max %v", e.MinPodEvictionHeadroom, e.MaxPodEvictionHeadroom)
+	}
+	if e.MaxPodsEvictedPerWave < 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "max pods evicted per wave can't be negative, got %d", e.MaxPodsEvictedPerWave)
+	}
+	if e.DrainLimiter != nil && cap(e.DrainLimiter.slots) <= 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "drain limiter must allow at least one drain, got %d", cap(e.DrainLimiter.slots))
+	}
+	if e.UnreadyGracePeriodSeconds < 0 {
+		return errors.NewAutoscalerError(errors.ConfigurationError, "unready grace period can't be negative, got %d", e.UnreadyGracePeriodSeconds)
+	}
+	for i, period := range e.shutdownGracePeriodByPodPriority {
+		if period.ShutdownGracePeriodSeconds < 0 {
+			return errors.NewAutoscalerError(errors.ConfigurationError, "shutdown grace period of priority %d can't be negative, got %d", period.Priority, period.ShutdownGracePeriodSeconds)
+		}
+		if i > 0 && period.Priority <= e.shutdownGracePeriodByPodPriority[i-1].Priority {
+			return errors.NewAutoscalerError(errors.ConfigurationError, "drain priority thresholds must be strictly increasing, got %d after %d", period.Priority, e.shutdownGracePeriodByPodPriority[i-1].Priority)
+		}
+	}
+	return nil
+}
+
+// evictionGroupVersion returns the policy API version the API server serves evictions with, or policy/v1beta1
+// if it can't be discovered.
+func evictionGroupVersion(discoveryClient discovery.DiscoveryInterface) schema.GroupVersion {
+	if discoveryClient == nil {
+		return policyv1beta1.SchemeGroupVersion
+	}
+	resources, err := discoveryClient.ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		klog.Warningf("Failed to discover the eviction API version, using %v: %v", policyv1beta1.SchemeGroupVersion, err)
+		return policyv1beta1.SchemeGroupVersion
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "pods/eviction" && resource.Kind == "Eviction" {
+			return schema.GroupVersion{Group: resource.Group, Version: resource.Version}
+		}
+	}
+	klog.Warningf("Eviction API not found during discovery, using %v", policyv1beta1.SchemeGroupVersion)
+	return policyv1beta1.SchemeGroupVersion
+}
+
+// NewEvictionClientSet creates a client for pod evictions from the given config, with its own rate limiter
+// configured by qps and burst.
+func NewEvictionClientSet(kubeConfig *rest.Config, qps float32, burst int) (kube_client.Interface, error) {
+	evictionConfig := rest.CopyConfig(kubeConfig)
+	evictionConfig.QPS = qps
+	evictionConfig.Burst = burst
+	evictionConfig.RateLimiter = nil
+	return kube_client.NewForConfig(evictionConfig)
+}
+
+func (e Evictor) clientSet(ctx *acontext.AutoscalingContext) kube_client.Interface {
+	if e.EvictionClientSet != nil {
+		return e.EvictionClientSet
+	}
+	return ctx.ClientSet
+}
+
+// sleep waits for d, returning early with false if the drain is cancelled meanwhile.
+func (e Evictor) sleep(ds *drainState, d time.Duration) bool {
+	if ds.cancel == nil {
+		e.getClock().Sleep(d)
+		return true
+	}
+	select {
+	case <-ds.cancel:
+		return false
+	case <-e.getClock().After(d):
+		return true
+	}
+}
+
+func (e Evictor) getClock() clock.Clock {
+	if e.clock == nil {
+		return clock.RealClock{}
+	}
+	return e.clock
+}
+
+// RecommendedDeletionWait returns how long to wait after draining the node before deleting it, which is
+// StaticPodDrainWindow if the node hosts static pods and zero otherwise.
+func (e Evictor) RecommendedDeletionWait(nodeInfo *framework.NodeInfo) time.Duration {
+	if e.StaticPodDrainWindow <= 0 {
+		return 0
+	}
+	for _, podInfo := range nodeInfo.Pods {
+		if pod_util.IsMirrorPod(podInfo.Pod) {
+			return e.StaticPodDrainWindow
+		}
+	}
+	return 0
+}
+
+// waitBeforeDeletion waits for RecommendedDeletionWait, announcing it with an event on the node.
+func (e Evictor) waitBeforeDeletion(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) {
+	wait := e.RecommendedDeletionWait(nodeInfo)
+	if wait <= 0 {
+		return
+	}
+	ctx.Recorder.Eventf(nodeInfo.Node(), apiv1.EventTypeNormal, "ScaleDownStaticPodsWait", "waiting %v for static pods to drain before deleting the node", wait)
+	klog.V(2).Infof("Waiting %v for static pods on node %s to drain before deleting it", wait, nodeInfo.Node().Name)
+	e.getClock().Sleep(wait)
+}
+
+// DrainNode groups pods in the node in to priority groups and, evicts pods in the ascending order of priorities.
+// If priority evictor is not enable, eviction of daemonSet pods is the best effort.
+func (e Evictor) DrainNode(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) (map[string]status.PodEvictionResult, error) {
+	return e.DrainNodeFiltered(ctx, nodeInfo, nil)
+}
+
+// DrainNodeWithCancel works like DrainNode, but aborts the drain once cancel is closed, e.g. when a scale-up needs the
+// node back. Evictions not created yet and pods of the remaining priority groups are skipped, while pods already
+// evicted stay evicted, and ErrDrainCancelled is returned. Cancelling affects only this drain.
+func (e Evictor) DrainNodeWithCancel(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo, cancel <-chan struct{}) (map[string]status.PodEvictionResult, error) {
+	return e.drainNodeFiltered(ctx, &drainState{cancel: cancel}, nodeInfo, nil)
+}
+
+// DrainNodeFiltered works like DrainNode, but only evicts the pods accepted by podFilter. Pods that DrainNode
+// wouldn't evict, like mirror pods, are skipped regardless of the filter. A nil podFilter accepts all pods.
+func (e Evictor) DrainNodeFiltered(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo, podFilter func(*apiv1.Pod) bool) (map[string]status.PodEvictionResult, error) {
+	return e.drainNodeFiltered(ctx, &drainState{}, nodeInfo, podFilter)
+}
+
+// drainNodeFiltered works like DrainNodeFiltered, with the drain's state created by the caller, which can set its
+// cancel channel and result stream.
+func (e Evictor) drainNodeFiltered(ctx *acontext.AutoscalingContext, ds *drainState, nodeInfo *framework.NodeInfo, podFilter func(*apiv1.Pod) bool) (map[string]status.PodEvictionResult, error) {
+	if e.drains != nil {
+		if !e.drains.start() {
+			return map[string]status.PodEvictionResult{}, ErrDrainCancelled
+		}
+		defer e.drains.done()
+		var stop func()
+		ds.cancel, stop = mergeCancel(ds.cancel, e.drains.shutdown)
+		defer stop()
+	}
+	if e.DrainLimiter != nil {
+		if !e.DrainLimiter.acquire(ds.cancel) {
+			return map[string]status.PodEvictionResult{}, ErrDrainCancelled
+		}
+		defer e.DrainLimiter.release()
+	}
+	ds.id = newDrainID()
+	ds.timing = &drainTiming{}
+	klog.V(1).Infof("%sDraining node %s", ds.logPrefix(), nodeInfo.Node().Name)
+	evictionResults, err := e.drainNode(ctx, ds, nodeInfo, podFilter)
+	metrics.RegisterNodeDrain(nodeDrainResult(evictionResults, err))
+	metrics.RegisterNodeDrainDurations(ds.timing.eviction, ds.timing.wait)
+	if e.OwnerEvents {
+		e.recordOwnerEvents(ctx, ds, nodeInfo.Node(), evictionResults)
+	}
+	return evictionResults, err
+}
+
+// newDrainID returns a new unique drain ID.
+func newDrainID() string {
+	return string(uuid.NewUUID())
+}
+
+// drainState holds the state of a single drain. It's created when the drain starts and passed to everything the
+// drain does, so that concurrent drains of the same Evictor never share it.
+type drainState struct {
+	// id identifies the drain in its logs, events and results. It's empty outside of drains, e.g. in WaitForPodGone.
+	id string
+	// cancel, if set, aborts the drain once it's closed.
+	cancel <-chan struct{}
+	// trace builds the trace of the drain if Tracer is set.
+	trace *drainTrace
+	// timing accumulates the time the drain spends evicting pods and waiting for them.
+	timing *drainTiming
+	// retryBudget bounds the retries of the evictions of the group being drained. It's set per group.
+	retryBudget *retryBudget
+	// stream, if set, receives the eviction results of each priority group once the group is drained.
+	stream *resultStream
+	// maxPodEvictionTime overrides the MaxPodEvictionTime of the autoscaling context if it's positive. It's set from
+	// the node group policy.
+	maxPodEvictionTime time.Duration
+}
+
+// logPrefix returns the prefix of the log messages and events of the drain, identifying it by its ID. It's empty
+// outside of drains.
+func (ds *drainState) logPrefix() string {
+	if ds.id == "" {
+		return ""
+	}
+	return "[drain " + ds.id + "] "
+}
+
+func (ds *drainState) cancelled() bool {
+	select {
+	case <-ds.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainTiming accumulates the time a drain spends creating evictions and waiting for the evicted pods to disappear.
+// Its methods are no-ops on a nil drainTiming, so that evictions done outside of drains aren't timed.
+type drainTiming struct {
+	eviction time.Duration
+	wait     time.Duration
+}
+
+func (t *drainTiming) addEviction(d time.Duration) {
+	if t != nil {
+		t.eviction += d
+	}
+}
+
+func (t *drainTiming) addWait(d time.Duration) {
+	if t != nil {
+		t.wait += d
+	}
+}
+
+// Shutdown cancels all in-flight drains of the Evictor, as if DrainNodeWithCancel was cancelled, and waits for them
+// to return. Drains started afterwards fail right away with ErrDrainCancelled. It returns ctx's error if ctx is done
+// before all drains return. It's meant to be called once CA is shutting down.
+func (e Evictor) Shutdown(ctx context.Context) error {
+	if e.drains == nil {
+		return nil
+	}
+	return e.drains.stop(ctx)
+}
+
+// DrainLimiter bounds the number of drains running at once. It's safe for concurrent use.
+type DrainLimiter struct {
+	slots chan struct{}
+}
+
+// NewDrainLimiter returns a DrainLimiter allowing up to maxConcurrentDrains drains at once.
+func NewDrainLimiter(maxConcurrentDrains int) *DrainLimiter {
+	return &DrainLimiter{slots: make(chan struct{}, maxConcurrentDrains)}
+}
+
+// acquire waits until fewer than the maximum number of drains are running, returning false if cancel is closed
+// meanwhile.
+func (l *DrainLimiter) acquire(cancel <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// release marks a drain started by acquire as finished.
+func (l *DrainLimiter) release() {
+	<-l.slots
+}
+
+// drainTracker tracks in-flight drains and cancels them on shutdown. It's safe for concurrent use.
+type drainTracker struct {
+	mutex    sync.Mutex
+	stopped  bool
+	shutdown chan struct{}
+	inFlight sync.WaitGroup
+}
+
+func newDrainTracker() *drainTracker {
+	return &drainTracker{shutdown: make(chan struct{})}
+}
+
+// start registers a new drain, returning false if the tracker is already stopped.
+func (t *drainTracker) start() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.inFlight.Add(1)
+	return true
+}
+
+// done marks a drain registered by start as returned.
+func (t *drainTracker) done() {
+	t.inFlight.Done()
+}
+
+// stop cancels the in-flight drains and waits for them to return or for ctx to be done.
+func (t *drainTracker) stop(ctx context.Context) error {
+	t.mutex.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.shutdown)
+	}
+	t.mutex.Unlock()
+	returned := make(chan struct{})
+	go func() {
+		t.inFlight.Wait()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// mergeCancel returns a channel closed once either a or b is closed. A nil channel is never closed. The returned
+// function has to be called once the merged channel isn't needed anymore, to release the goroutine merging them.
+func mergeCancel(a, b <-chan struct{}) (<-chan struct{}, func()) {
+	if a == nil {
+		return b, func() {}
+	}
+	merged := make(chan struct{})
+	released := make(chan struct{})
+	go func() {
+		select {
+		case <-a:
+		case <-b:
+		case <-released:
+			return
+		}
+		close(merged)
+	}()
+	return merged, func() { close(released) }
+}
+
+// DrainNodeStream works like DrainNode, but runs in the background and streams eviction results as the drain
+// progresses. The results of each priority group are sent once the group is drained, and the results of pods in
+// groups that aren't drained, e.g. after a failure, are sent at the end. The channel is closed once the drain is done.
+// The returned function waits for the drain to finish and returns its error, the same one DrainNode would.
+func (e Evictor) DrainNodeStream(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) (<-chan status.PodEvictionResult, func() error) {
+	// The channel fits the results of all pods on the node, so that a slow consumer doesn't slow the drain down.
+	results := make(chan status.PodEvictionResult, len(nodeInfo.Pods))
+	ds := &drainState{stream: &resultStream{results: results, sent: make(map[string]bool)}}
+	done := make(chan struct{})
+	var drainErr error
+	go func() {
+		defer close(done)
+		defer close(results)
+		var evictionResults map[string]status.PodEvictionResult
+		evictionResults, drainErr = e.drainNodeFiltered(ctx, ds, nodeInfo, nil)
+		ds.stream.send(evictionResults)
+	}()
+	return results, func() error {
+		<-done
+		return drainErr
+	}
+}
+
+// resultStream sends each pod eviction result at most once. It's not safe for concurrent use.
+type resultStream struct {
+	results chan<- status.PodEvictionResult
+	sent    map[string]bool
+}
+
+// send sends the results of the pods not sent yet.
+func (s *resultStream) send(evictionResults map[string]status.PodEvictionResult) {
+	for name, result := range evictionResults {
+		if !s.sent[name] {
+			s.sent[name] = true
+			s.results <- result
+		}
+	}
+}
+
+func (e Evictor) drainNode(ctx *acontext.AutoscalingContext, ds *drainState, nodeInfo *framework.NodeInfo, podFilter func(*apiv1.Pod) bool) (evictionResults map[string]status.PodEvictionResult, err error) {
+	node := nodeInfo.Node()
+	e = e.withNodeGroupPolicy(ds, node)
+	ds.trace = newDrainTrace(e.Tracer, e.getClock(), node)
+	defer func() { ds.trace.finish(evictionResults, err) }()
+	if e.EnsureCordoned {
+		if err := e.ensureCordoned(ctx, ds, node); err != nil {
+			return map[string]status.PodEvictionResult{}, err
+		}
+	}
+	fullEvictionPods, bestEffortEvictionPods := e.podsToDrain(ctx, ds, nodeInfo, podFilter)
+	if err := e.checkLocalStorage(ds, node, fullEvictionPods); err != nil {
+		return map[string]status.PodEvictionResult{}, err
+	}
+	if err := e.checkSystemCritical(node, fullEvictionPods); err != nil {
+		return map[string]status.PodEvictionResult{}, err
+	}
+	evictionResults, err = e.drainNodeWithPodsBasedOnPodPriority(ctx, ds, node, fullEvictionPods, bestEffortEvictionPods)
+	if err == nil && e.PostDrainSettle > 0 {
+		err = e.settle(ctx, ds, node)
+	}
+	return evictionResults, err
+}
+
+// settle waits PostDrainSettle after the pods of the node are gone, or until the node has PostDrainSettleCondition
+// with status True if it's set. It returns ErrDrainCancelled if the drain is cancelled while waiting.
+func (e Evictor) settle(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node) error {
+	if e.PostDrainSettleCondition == "" {
+		if !e.sleep(ds, e.PostDrainSettle) {
+			return ErrDrainCancelled
+		}
+		return nil
+	}
+	clk := e.getClock()
+	start := clk.Now()
+	for {
+		freshNode, err := ctx.ClientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+		if kube_errors.IsNotFound(err) {
+			return nil
+		}
+		if err == nil && hasNodeCondition(freshNode, e.PostDrainSettleCondition) {
+			return nil
+		}
+		remaining := e.PostDrainSettle - clk.Since(start)
+		if remaining <= 0 {
+			klog.Warningf("%sNode %s doesn't have condition %s %v after its drain, considering it settled", ds.logPrefix(), node.Name, e.PostDrainSettleCondition, e.PostDrainSettle)
+			return nil
+		}
+		if !e.sleep(ds, e.pollInterval(remaining)) {
+			return ErrDrainCancelled
+		}
+	}
+}
+
+// hasNodeCondition returns whether the node has the condition with status True.
+func hasNodeCondition(node *apiv1.Node, conditionType apiv1.NodeConditionType) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// withNodeGroupPolicy returns the Evictor with the NodeGroupPolicies policy of the node's node group applied, if
+// there's one. The policy's MaxPodEvictionTime is applied to the drain's state.
+func (e Evictor) withNodeGroupPolicy(ds *drainState, node *apiv1.Node) Evictor {
+	if e.NodeGroupLabel == "" || len(e.NodeGroupPolicies) == 0 {
+		return e
+	}
+	policy, found := e.NodeGroupPolicies[node.Labels[e.NodeGroupLabel]]
+	if !found {
+		return e
+	}
+	klog.V(4).Infof("%sDraining node %s with the eviction policy of node group %s", ds.logPrefix(), node.Name, node.Labels[e.NodeGroupLabel])
+	if policy.EvictionMethod != nil {
+		e.EvictionMethod = *policy.EvictionMethod
+	}
+	if policy.ForceDrainAfter != nil {
+		e.ForceDrainAfter = *policy.ForceDrainAfter
+	}
+	if policy.MaxPodEvictionTime != nil {
+		ds.maxPodEvictionTime = *policy.MaxPodEvictionTime
+	}
+	if len(policy.ShutdownGracePeriodByPodPriority) > 0 {
+		e.shutdownGracePeriodByPodPriority = slices.Clone(policy.ShutdownGracePeriodByPodPriority)
+		sort.Slice(e.shutdownGracePeriodByPodPriority, func(i, j int) bool {
+			return e.shutdownGracePeriodByPodPriority[i].Priority < e.shutdownGracePeriodByPodPriority[j].Priority
+		})
+	}
+	return e
+}
+
+// CheckDrainFeasibility returns the pods whose eviction would be blocked by their PodDisruptionBudgets if the node
+// was drained, based on the PDBs in the listers, without evicting anything. Only pods whose eviction failures fail
+// the drain are checked, and pods covered by the same PDB use up its allowed disruptions in turn.
+func (e Evictor) CheckDrainFeasibility(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) ([]*apiv1.Pod, errors.AutoscalerError) {
+	pdbs, err := ctx.ListerRegistry.PodDisruptionBudgetLister().List()
+	if err != nil {
+		return nil, errors.NewAutoscalerError(errors.ApiCallError, "failed to list PodDisruptionBudgets: %v", err)
+	}
+	pdbTracker := pdb.NewBasicRemainingPdbTracker()
+	if err := pdbTracker.SetPdbs(pdbs); err != nil {
+		return nil, errors.NewAutoscalerError(errors.InternalError, "failed to parse PodDisruptionBudgets: %v", err)
+	}
+
+	fullEvictionPods, _ := e.podsToDrain(ctx, &drainState{}, nodeInfo, nil)
+	var blockedPods []*apiv1.Pod
+	for _, pod := range fullEvictionPods {
+		if canRemove, _, _ := pdbTracker.CanRemovePods([]*apiv1.Pod{pod}); !canRemove {
+			blockedPods = append(blockedPods, pod)
+			continue
+		}
+		pdbTracker.RemovePods([]*apiv1.Pod{pod})
+	}
+	return blockedPods, nil
+}
+
+// podsToDrain returns the pods that draining the node evicts, split into full eviction and best effort eviction pods.
+func (e Evictor) podsToDrain(ctx *acontext.AutoscalingContext, ds *drainState, nodeInfo *framework.NodeInfo, podFilter func(*apiv1.Pod) bool) (fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) {
+	dsPods, pods := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForOccupiedNodes, ds.logPrefix())
+	if podFilter != nil {
+		dsPods, pods = filterPods(dsPods, podFilter), filterPods(pods, podFilter)
+	}
+	if e.TolerationGate != nil {
+		dsPods, pods = filterPods(dsPods, e.toleratesGate), filterPods(pods, e.toleratesGate)
+	}
+	fullEvictionPods, bestEffortEvictionPods = pods, dsPods
+	if e.fullDsEviction {
+		fullEvictionPods, bestEffortEvictionPods = append(pods, dsPods...), nil
+	}
+	if e.SystemCriticalPolicy == SystemCriticalBestEffort {
+		bestEffortEvictionPods = append(bestEffortEvictionPods, filterPods(fullEvictionPods, isSystemCritical)...)
+		fullEvictionPods = filterPods(fullEvictionPods, func(pod *apiv1.Pod) bool { return !isSystemCritical(pod) })
+	}
+	if e.OrphanedPodsBestEffort {
+		var owned []*apiv1.Pod
+		for _, pod := range fullEvictionPods {
+			if isOrphaned(ctx, pod) {
+				klog.V(2).Infof("%sController of pod %s/%s is gone, evicting it on a best effort basis", ds.logPrefix(), pod.Namespace, pod.Name)
+				bestEffortEvictionPods = append(bestEffortEvictionPods, pod)
+			} else {
+				owned = append(owned, pod)
+			}
+		}
+		fullEvictionPods = owned
+	}
+	return fullEvictionPods, bestEffortEvictionPods
+}
+
+// isSystemCritical returns true if the pod's priority is at least that of the system-cluster-critical priority class.
+func isSystemCritical(pod *apiv1.Pod) bool {
+	return pod.Spec.Priority != nil && *pod.Spec.Priority >= scheduling.SystemCriticalPriority
+}
+
+// isOrphaned returns true if the pod's controller is known to be gone, i.e. it isn't listed anymore or was replaced
+// by another object of the same name. Pods without a controller, and pods whose controller can't be listed, aren't
+// orphaned.
+func isOrphaned(ctx *acontext.AutoscalingContext, pod *apiv1.Pod) bool {
+	ownerRef := metav1.GetControllerOf(pod)
+	if ownerRef == nil || ctx.ListerRegistry == nil {
+		return false
+	}
+	var owner metav1.Object
+	var err error
+	switch ownerRef.Kind {
+	case "ReplicaSet":
+		lister := ctx.ListerRegistry.ReplicaSetLister()
+		if lister == nil {
+			return false
+		}
+		owner, err = lister.ReplicaSets(pod.Namespace).Get(ownerRef.Name)
+	case "ReplicationController":
+		lister := ctx.ListerRegistry.ReplicationControllerLister()
+		if lister == nil {
+			return false
+		}
+		owner, err = lister.ReplicationControllers(pod.Namespace).Get(ownerRef.Name)
+	case "StatefulSet":
+		lister := ctx.ListerRegistry.StatefulSetLister()
+		if lister == nil {
+			return false
+		}
+		owner, err = lister.StatefulSets(pod.Namespace).Get(ownerRef.Name)
+	case "Job":
+		lister := ctx.ListerRegistry.JobLister()
+		if lister == nil {
+			return false
+		}
+		owner, err = lister.Jobs(pod.Namespace).Get(ownerRef.Name)
+	case "DaemonSet":
+		lister := ctx.ListerRegistry.DaemonSetLister()
+		if lister == nil {
+			return false
+		}
+		owner, err = lister.DaemonSets(pod.Namespace).Get(ownerRef.Name)
+	default:
+		return false
+	}
+	if err != nil {
+		return kube_errors.IsNotFound(err)
+	}
+	return ownerRef.UID != "" && owner.GetUID() != ownerRef.UID
+}
+
+// toleratesGate returns true if the pod tolerates the TolerationGate taint.
+func (e Evictor) toleratesGate(pod *apiv1.Pod) bool {
+	for i := range pod.Spec.Tolerations {
+		if pod.Spec.Tolerations[i].ToleratesTaint(e.TolerationGate) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupPods splits the pods into priority groups, moving the pods annotated with EvictLastAnnotation to a group after
+// the last one.
+// The groups are split further by NamespacePriority, if it's set. With SystemCriticalEvictLast, system-critical pods
+// are moved to a group of their own after all others.
+func (e Evictor) groupPods(fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) []podEvictionGroup {
+	if e.SystemCriticalPolicy != SystemCriticalEvictLast {
+		return splitByNamespace(e.groupPodsByPriority(fullEvictionPods, bestEffortEvictionPods), e.NamespacePriority)
+	}
+	notCritical := func(pod *apiv1.Pod) bool { return !isSystemCritical(pod) }
+	groups := splitByNamespace(e.groupPodsByPriority(filterPods(fullEvictionPods, notCritical), filterPods(bestEffortEvictionPods, notCritical)), e.NamespacePriority)
+	critical := podEvictionGroup{
+		FullEvictionPods:       filterPods(fullEvictionPods, isSystemCritical),
+		BestEffortEvictionPods: filterPods(bestEffortEvictionPods, isSystemCritical),
+	}
+	if len(e.shutdownGracePeriodByPodPriority) > 0 {
+		critical.ShutdownGracePeriodByPodPriority = e.shutdownGracePeriodByPodPriority[len(e.shutdownGracePeriodByPodPriority)-1]
+	}
+	return append(groups, critical)
+}
+
+func (e Evictor) groupPodsByPriority(fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) []podEvictionGroup {
+	if e.EvictLastAnnotation == "" {
+		return groupByPriority(e.shutdownGracePeriodByPodPriority, fullEvictionPods, bestEffortEvictionPods)
+	}
+	evictLast := func(pod *apiv1.Pod) bool { return pod.Annotations[e.EvictLastAnnotation] == "true" }
+	evictFirst := func(pod *apiv1.Pod) bool { return !evictLast(pod) }
+	groups := groupByPriority(e.shutdownGracePeriodByPodPriority, filterPods(fullEvictionPods, evictFirst), filterPods(bestEffortEvictionPods, evictFirst))
+	if len(groups) == 0 {
+		return groups
+	}
+	return append(groups, podEvictionGroup{
+		ShutdownGracePeriodByPodPriority: groups[len(groups)-1].ShutdownGracePeriodByPodPriority,
+		FullEvictionPods:                 filterPods(fullEvictionPods, evictLast),
+		BestEffortEvictionPods:           filterPods(bestEffortEvictionPods, evictLast),
+	})
+}
+
+// EstimateDrainDuration returns the worst-case time DrainNode could take for the node, without making any API calls.
+// Every priority group with pods to wait for contributes its ShutdownGracePeriodSeconds plus the eviction headroom,
+// and the sum is capped by TotalDrainTimeout if it's set.
+func (e Evictor) EstimateDrainDuration(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) time.Duration {
+	ds := &drainState{}
+	e = e.withNodeGroupPolicy(ds, nodeInfo.Node())
+	fullEvictionPods, bestEffortEvictionPods := e.podsToDrain(ctx, ds, nodeInfo, nil)
+	var estimate time.Duration
+	for _, group := range e.groupPods(fullEvictionPods, bestEffortEvictionPods) {
+		// Only full eviction pods are waited for, best effort evictions don't extend the drain.
+		if len(group.FullEvictionPods) == 0 {
+			continue
+		}
+		estimate += time.Duration(group.ShutdownGracePeriodSeconds)*time.Second + e.podEvictionHeadroom(ds, nodeInfo.Node(), group.ShutdownGracePeriodSeconds)
+	}
+	if e.TotalDrainTimeout > 0 && estimate > e.TotalDrainTimeout {
+		estimate = e.TotalDrainTimeout
+	}
+	return estimate
+}
+
+// EvictDaemonSetPods groups  daemonSet pods in the node in to priority groups and, evicts daemonSet pods in the ascending order of priorities.
+// If priority evictor is not enable, eviction of daemonSet pods is the best effort.
+func (e Evictor) EvictDaemonSetPods(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) (map[string]status.PodEvictionResult, error) {
+	node := nodeInfo.Node()
+	ds := &drainState{id: newDrainID()}
+	dsPods, _ := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForEmptyNodes, ds.logPrefix())
+	if e.TolerationGate != nil {
+		dsPods = filterPods(dsPods, e.toleratesGate)
+	}
+	klog.V(1).Infof("%sEvicting DaemonSet pods from node %s", ds.logPrefix(), node.Name)
+	var evictionResults map[string]status.PodEvictionResult
+	var err error
+	if e.fullDsEviction {
+		evictionResults, err = e.drainNodeWithPodsBasedOnPodPriority(ctx, ds, node, dsPods, nil)
+	} else {
+		evictionResults, err = e.drainNodeWithPodsBasedOnPodPriority(ctx, ds, node, nil, dsPods)
+	}
+	return evictionResults, err
+}
+
+// nodeDrainResult classifies the outcome of a drain for metrics. A drain times out if some pods
+// were still on the node when we stopped waiting for them, without any other error.
+func nodeDrainResult(evictionResults map[string]status.PodEvictionResult, err error) metrics.NodeDrainResult {
+	if err == nil {
+		return metrics.NodeDrainSucceed
+	}
+	for _, result := range evictionResults {
+		if result.TimedOut && result.Err == nil {
+			return metrics.NodeDrainTimedOut
+		}
+	}
+	return metrics.NodeDrainFailed
+}
+
+// ensureCordoned checks that the node is marked unschedulable and cordons it if AutoCordon is enabled, waiting
+// CordonSettle afterwards.
+func (e Evictor) ensureCordoned(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node) errors.AutoscalerError {
+	freshNode, err := ctx.ClientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.NewAutoscalerError(errors.ApiCallError, "Failed to check if node %s is cordoned: %v", node.Name, err)
+	}
+	if freshNode.Spec.Unschedulable {
+		return nil
+	}
+	if !e.AutoCordon {
+		return errors.NewAutoscalerError(errors.UnexpectedScaleDownStateError, "Refusing to drain node %s: node is not cordoned", node.Name)
+	}
+
+	patch := []byte(`{"spec":{"unschedulable":true}}`)
+	if _, err := ctx.ClientSet.CoreV1().Nodes().Patch(context.TODO(), node.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return errors.NewAutoscalerError(errors.ApiCallError, "Failed to cordon node %s: %v", node.Name, err)
+	}
+	klog.V(1).Infof("%sCordoned node %s before draining it", ds.logPrefix(), node.Name)
+	if e.CordonSettle > 0 && !e.sleep(ds, e.CordonSettle) {
+		return ErrDrainCancelled
+	}
+	return nil
+}
+
+// checkLocalStorage applies the LocalStoragePolicy to the pods about to be evicted from the node.
+func (e Evictor) checkLocalStorage(ds *drainState, node *apiv1.Node, pods []*apiv1.Pod) errors.AutoscalerError {
+	if e.LocalStoragePolicy == LocalStorageEvict {
+		return nil
+	}
+	for _, pod := range pods {
+		if !drain.HasBlockingLocalStorage(pod) {
+			continue
+		}
+		if e.LocalStoragePolicy == LocalStorageBlock {
+			return errors.NewAutoscalerError(errors.TransientError, "Node %s can't be drained: pod %s/%s uses local storage", node.Name, pod.Namespace, pod.Name)
+		}
+		klog.Warningf("%sEvicting pod %s/%s from node %s, its local storage will be lost", ds.logPrefix(), pod.Namespace, pod.Name, node.Name)
+	}
+	return nil
+}
+
+// checkSystemCritical refuses to drain the node if it hosts system-critical pods to evict and SystemCriticalPolicy is
+// SystemCriticalBlock.
+func (e Evictor) checkSystemCritical(node *apiv1.Node, pods []*apiv1.Pod) errors.AutoscalerError {
+	if e.SystemCriticalPolicy != SystemCriticalBlock {
+		return nil
+	}
+	for _, pod := range pods {
+		if isSystemCritical(pod) {
+			return errors.NewAutoscalerError(errors.TransientError, "Node %s can't be drained: pod %s/%s is system-critical", node.Name, pod.Namespace, pod.Name)
+		}
+	}
+	return nil
+}
+
+// drainNodeWithPodsBasedOnPodPriority performs drain logic on the node based on pod priorities.
+// Removes all pods, giving each pod group up to ShutdownGracePeriodSeconds to finish. The list of pods to evict has to be provided.
+func (e Evictor) drainNodeWithPodsBasedOnPodPriority(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) (map[string]status.PodEvictionResult, error) {
+	evictionResults := make(map[string]status.PodEvictionResult)
+	if e.AnnotateDrainTimes {
+		e.annotateDrainTime(ctx, ds, node, DrainStartedAtAnnotationKey)
+		defer e.annotateDrainTime(ctx, ds, node, DrainFinishedAtAnnotationKey)
+	}
+
+	groups := e.groupPods(fullEvictionPods, bestEffortEvictionPods)
+	if e.EvictLargestFirst {
+		sortLargestFirst(groups)
+	}
+	ds.trace.plan(groups)
+
+	var deadline time.Time
+	if e.TotalDrainTimeout > 0 {
+		deadline = e.getClock().Now().Add(e.TotalDrainTimeout)
+	}
+
+	for i, group := range groups {
+		// If there are no pods in a particular range,
+		// then do not wait for pods in that priority range.
+		if len(group.FullEvictionPods) == 0 && len(group.BestEffortEvictionPods) == 0 {
+			continue
+		}
+		if ds.cancelled() {
+			return skipGroups(ds, groups[i:], evictionResults), ErrDrainCancelled
+		}
+		if !deadline.IsZero() && !e.getClock().Now().Before(deadline) {
+			return abandonGroups(ds, node, groups[i:], evictionResults, e.TotalDrainTimeout)
+		}
+
+		group.FullEvictionPods, group.BestEffortEvictionPods = e.reclassifyPods(ds, group.FullEvictionPods, group.BestEffortEvictionPods, evictionResults)
+
+		var err error
+		ds.retryBudget = newRetryBudget(e.GroupRetryBudget)
+		ds.trace.groupStarted(i)
+		minTermination := e.MinGracePeriodSecondsByPriority[group.Priority]
+		timeout := time.Duration(group.ShutdownGracePeriodSeconds)*time.Second + e.podEvictionHeadroom(ds, node, group.ShutdownGracePeriodSeconds)
+		if !deadline.IsZero() {
+			if remaining := deadline.Sub(e.getClock().Now()); remaining < timeout {
+				timeout = remaining
+			}
+		}
+		switch e.WithinGroupMode {
+		case WithinGroupSequential:
+			evictionResults, err = e.evictGroupSequentially(ctx, ds, node, group, evictionResults, minTermination, timeout, deadline)
+		case WithinGroupPdbWaves:
+			evictionResults, err = e.evictGroupInWaves(ctx, ds, node, group, evictionResults, minTermination, timeout, deadline, e.capWave(func(pods []*apiv1.Pod) ([]*apiv1.Pod, []*apiv1.Pod, errors.AutoscalerError) {
+				return pdbEvictionWave(ctx, pods)
+			}))
+		case WithinGroupTopologySpreadWaves:
+			evictionResults, err = e.evictGroupInWaves(ctx, ds, node, group, evictionResults, minTermination, timeout, deadline, e.capWave(topologySpreadEvictionWave))
+		default:
+			if e.MaxPodsEvictedPerWave > 0 {
+				evictionResults, err = e.evictGroupInWaves(ctx, ds, node, group, evictionResults, minTermination, timeout, deadline, e.capWave(allPodsEvictionWave))
+			} else {
+				evictionResults, err = e.evictGroupInParallel(ctx, ds, node, group, evictionResults, minTermination, timeout, deadline)
+			}
+		}
+		ds.trace.groupFinished(i)
+		nodeDeleted := goerrors.Is(err, errNodeDeleted)
+		if nodeDeleted {
+			// The pods of the node are gone along with it, including the ones of the groups not drained yet.
+			klog.V(1).Infof("%sNode %s was deleted while draining it, considering its pods removed", ds.logPrefix(), node.Name)
+			evictionResults, err = podsGoneWithNode(ds, groups[i:], evictionResults), nil
+		}
+		if err == nil && !nodeDeleted && e.BestEffortWait > 0 && len(group.FullEvictionPods) == 0 {
+			e.sleep(ds, e.BestEffortWait)
+		}
+		if ds.stream != nil {
+			ds.stream.send(evictionResults)
+		}
+		if nodeDeleted {
+			return evictionResults, nil
+		}
+		if err != nil {
+			if !deadline.IsZero() && !e.getClock().Now().Before(deadline) {
+				return abandonGroups(ds, node, groups[i+1:], evictionResults, e.TotalDrainTimeout)
+			}
+			return skipGroups(ds, groups[i+1:], evictionResults), err
+		}
+	}
+	klog.V(1).Infof("%sAll pods removed from %s", ds.logPrefix(), node.Name)
+	return evictionResults, nil
+}
+
+// annotateDrainTime sets the annotation to the current time on the node. Errors are only logged.
+func (e Evictor) annotateDrainTime(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, annotation string) {
+	annotateNode(ds, ctx, node, annotation, e.getClock().Now().UTC().Format(time.RFC3339))
+}
+
+// annotateNode sets the annotation to value on the node. Errors are only logged.
+func annotateNode(ds *drainState, ctx *acontext.AutoscalingContext, node *apiv1.Node, annotation, value string) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{annotation: value},
+		},
+	})
+	if err == nil {
+		_, err = ctx.ClientSet.CoreV1().Nodes().Patch(context.TODO(), node.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		klog.Warningf("%sFailed to set annotation %s on node %s: %v", ds.logPrefix(), annotation, node.Name, err)
+	}
+}
+
+// evictGroupInParallel evicts all pods of the group at once and waits up to timeout for the full eviction pods to disappear.
+// Evictions aren't retried past deadline, if it's set.
+func (e Evictor) evictGroupInParallel(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, group podEvictionGroup, evictionResults map[string]status.PodEvictionResult,
+	minTermination int64, timeout time.Duration, deadline time.Time) (map[string]status.PodEvictionResult, error) {
+	evictionResults, err := e.initiateEviction(ctx, ds, node, group.FullEvictionPods, group.BestEffortEvictionPods, evictionResults, group.ShutdownGracePeriodSeconds, minTermination, deadline)
+	if err != nil {
+		return evictionResults, err
+	}
+	// Evictions created successfully, wait ShutdownGracePeriodSeconds + podEvictionHeadroom to see if fullEviction pods really disappeared.
+	return e.waitPodsToDisappear(ctx, ds, node, group.FullEvictionPods, evictionResults, timeout)
+}
+
+// evictGroupSequentially evicts the full eviction pods of the group one at a time, waiting for each of them to disappear
+// and then for SequentialEvictionDelay before evicting the next one. Best effort pods are evicted at once afterwards.
+// The whole group is bounded by timeout, pods not evicted by then are reported as timed out.
+func (e Evictor) evictGroupSequentially(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, group podEvictionGroup, evictionResults map[string]status.PodEvictionResult,
+	minTermination int64, timeout time.Duration, deadline time.Time) (map[string]status.PodEvictionResult, error) {
+	clk := e.getClock()
+	groupDeadline := clk.Now().Add(timeout)
+	for i, pod := range group.FullEvictionPods {
+		if i > 0 && e.SequentialEvictionDelay > 0 && !e.sleep(ds, e.SequentialEvictionDelay) {
+			return skipGroups(ds, []podEvictionGroup{{FullEvictionPods: group.FullEvictionPods[i:]}}, evictionResults), ErrDrainCancelled
+		}
+		remaining := groupDeadline.Sub(clk.Now())
+		if remaining <= 0 {
+			for _, pod := range group.FullEvictionPods[i:] {
+				evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil, DrainID: ds.id}
+			}
+			return evictionResults, podsRemainingError(node, group.FullEvictionPods[i:])
+		}
+
+		var err error
+		evictionResults, err = e.initiateEviction(ctx, ds, node, []*apiv1.Pod{pod}, nil, evictionResults, group.ShutdownGracePeriodSeconds, minTermination, deadline)
+		if err == nil {
+			evictionResults, err = e.waitPodsToDisappear(ctx, ds, node, []*apiv1.Pod{pod}, evictionResults, remaining)
+		}
+		if err != nil {
+			return skipGroups(ds, []podEvictionGroup{{FullEvictionPods: group.FullEvictionPods[i+1:]}}, evictionResults), err
+		}
+	}
+	return e.initiateEviction(ctx, ds, node, nil, group.BestEffortEvictionPods, evictionResults, group.ShutdownGracePeriodSeconds, minTermination, deadline)
+}
+
+// evictGroupInWaves evicts the full eviction pods of the group in the waves returned by nextWave, waiting for each
+// wave to disappear before evicting the next one. Best effort pods are evicted at once afterwards. The whole group
+// is bounded by timeout, pods not evicted by then are reported as timed out.
+func (e Evictor) evictGroupInWaves(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, group podEvictionGroup, evictionResults map[string]status.PodEvictionResult,
+	minTermination int64, timeout time.Duration, deadline time.Time, nextWave evictionWaveFunc) (map[string]status.PodEvictionResult, error) {
+	clk := e.getClock()
+	groupDeadline := clk.Now().Add(timeout)
+	pods := group.FullEvictionPods
+	for len(pods) > 0 {
+		remaining := groupDeadline.Sub(clk.Now())
+		if remaining <= 0 {
+			for _, pod := range pods {
+				evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil, DrainID: ds.id}
+			}
+			return evictionResults, podsRemainingError(node, pods)
+		}
+
+		wave, rest, err := nextWave(pods)
+		if err != nil {
+			return skipGroups(ds, []podEvictionGroup{{FullEvictionPods: pods}}, evictionResults), err
+		}
+		klog.V(2).Infof("%sEvicting a wave of %d pods from node %s, %d pods left in the group", ds.logPrefix(), len(wave), node.Name, len(rest))
+		evictionResults, err = e.initiateEviction(ctx, ds, node, wave, nil, evictionResults, group.ShutdownGracePeriodSeconds, minTermination, deadline)
+		if err == nil {
+			evictionResults, err = e.waitPodsToDisappear(ctx, ds, node, wave, evictionResults, remaining)
+		}
+		if err != nil {
+			return skipGroups(ds, []podEvictionGroup{{FullEvictionPods: rest}}, evictionResults), err
+		}
+		pods = rest
+	}
+	return e.initiateEviction(ctx, ds, node, nil, group.BestEffortEvictionPods, evictionResults, group.ShutdownGracePeriodSeconds, minTermination, deadline)
+}
+
+// evictionWaveFunc splits the pods left to evict into the next wave to evict and the rest.
+type evictionWaveFunc func(pods []*apiv1.Pod) (wave, rest []*apiv1.Pod, err errors.AutoscalerError)
+
+// capWave limits the waves returned by nextWave to MaxPodsEvictedPerWave pods, the pods over the limit are left for
+// later waves.
+func (e Evictor) capWave(nextWave evictionWaveFunc) evictionWaveFunc {
+	if e.MaxPodsEvictedPerWave <= 0 {
+		return nextWave
+	}
+	return func(pods []*apiv1.Pod) (wave, rest []*apiv1.Pod, err errors.AutoscalerError) {
+		wave, rest, err = nextWave(pods)
+		if err != nil || len(wave) <= e.MaxPodsEvictedPerWave {
+			return wave, rest, err
+		}
+		rest = append(append([]*apiv1.Pod{}, wave[e.MaxPodsEvictedPerWave:]...), rest...)
+		return wave[:e.MaxPodsEvictedPerWave], rest, nil
+	}
+}
+
+// allPodsEvictionWave puts all the pods in the wave.
+func allPodsEvictionWave(pods []*apiv1.Pod) (wave, rest []*apiv1.Pod, err errors.AutoscalerError) {
+	return pods, nil, nil
+}
+
+// pdbEvictionWave splits the pods into the ones that can be evicted at once according to the disruptions currently
+// allowed by their PDBs, and the rest. If PDBs allow no disruptions at all, the wave holds a single pod, whose
+// eviction is retried until the PDB allows it.
+func pdbEvictionWave(ctx *acontext.AutoscalingContext, pods []*apiv1.Pod) (wave, rest []*apiv1.Pod, err errors.AutoscalerError) {
+	pdbs, listErr := ctx.ListerRegistry.PodDisruptionBudgetLister().List()
+	if listErr != nil {
+		return nil, nil, errors.NewAutoscalerError(errors.ApiCallError, "failed to list PodDisruptionBudgets: %v", listErr)
+	}
+	pdbTracker := pdb.NewBasicRemainingPdbTracker()
+	if setErr := pdbTracker.SetPdbs(pdbs); setErr != nil {
+		return nil, nil, errors.NewAutoscalerError(errors.InternalError, "failed to parse PodDisruptionBudgets: %v", setErr)
+	}
+	for _, pod := range pods {
+		if canRemove, _, _ := pdbTracker.CanRemovePods([]*apiv1.Pod{pod}); canRemove {
+			wave = append(wave, pod)
+			pdbTracker.RemovePods([]*apiv1.Pod{pod})
+		} else {
+			rest = append(rest, pod)
+		}
+	}
+	if len(wave) == 0 {
+		return rest[:1], rest[1:], nil
+	}
+	return wave, rest, nil
+}
+
+// topologySpreadEvictionWave splits the pods into a wave holding at most MaxSkew pods matched by each topology spread
+// constraint of the pods, and the rest. Pods without topology spread constraints all go to the wave.
+func topologySpreadEvictionWave(pods []*apiv1.Pod) (wave, rest []*apiv1.Pod, err errors.AutoscalerError) {
+	inWave := map[string]int32{}
+	for _, pod := range pods {
+		keys := make([]string, 0, len(pod.Spec.TopologySpreadConstraints))
+		fits := true
+		for _, constraint := range pod.Spec.TopologySpreadConstraints {
+			selector, selectorErr := metav1.LabelSelectorAsSelector(constraint.LabelSelector)
+			if selectorErr != nil || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			key := pod.Namespace + "/" + constraint.TopologyKey + "/" + selector.String()
+			if inWave[key] >= max(constraint.MaxSkew, 1) {
+				fits = false
+				break
+			}
+			keys = append(keys, key)
+		}
+		if !fits {
+			rest = append(rest, pod)
+			continue
+		}
+		for _, key := range keys {
+			inWave[key]++
+		}
+		wave = append(wave, pod)
+	}
+	return wave, rest, nil
+}
+
+// reclassifyPods moves the full eviction pods rejected by ReclassifyPod to best effort eviction.
+func (e Evictor) reclassifyPods(ds *drainState, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult) ([]*apiv1.Pod, []*apiv1.Pod) {
+	if e.ReclassifyPod == nil {
+		return fullEvictionPods, bestEffortEvictionPods
+	}
+	var full []*apiv1.Pod
+	for _, pod := range fullEvictionPods {
+		if e.ReclassifyPod(pod) {
+			full = append(full, pod)
+			continue
+		}
+		klog.V(2).Infof("%sPod %s/%s reclassified to best effort eviction", ds.logPrefix(), pod.Namespace, pod.Name)
+		delete(evictionResults, pod.Name)
+		bestEffortEvictionPods = append(bestEffortEvictionPods, pod)
+	}
+	return full, bestEffortEvictionPods
+}
+
+// podEvictionHeadroom returns the eviction headroom for pods on the node in a group with the given grace period,
+// taking the node's EvictionHeadroomAnnotationKey annotation into account.
+func (e Evictor) podEvictionHeadroom(ds *drainState, node *apiv1.Node, gracePeriodSeconds int64) time.Duration {
+	value, found := node.Annotations[EvictionHeadroomAnnotationKey]
+	if !found {
+		return e.defaultPodEvictionHeadroom(gracePeriodSeconds)
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		headroom := e.defaultPodEvictionHeadroom(gracePeriodSeconds)
+		klog.Warningf("%sInvalid value %q of annotation %s on node %s, using the default eviction headroom of %v", ds.logPrefix(), value, EvictionHeadroomAnnotationKey, node.Name, headroom)
+		return headroom
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// defaultPodEvictionHeadroom returns the eviction headroom for a group with the given grace period, which is
+// PodEvictionHeadroom unless PodEvictionHeadroomFraction is set.
+func (e Evictor) defaultPodEvictionHeadroom(gracePeriodSeconds int64) time.Duration {
+	if e.PodEvictionHeadroomFraction <= 0 {
+		return e.PodEvictionHeadroom
+	}
+	headroom := time.Duration(e.PodEvictionHeadroomFraction * float64(time.Duration(gracePeriodSeconds)*time.Second))
+	if e.MaxPodEvictionHeadroom > 0 && headroom > e.MaxPodEvictionHeadroom {
+		headroom = e.MaxPodEvictionHeadroom
+	}
+	return max(headroom, e.MinPodEvictionHeadroom)
+}
+
+// skipGroups adds a placeholder result for every full eviction pod of the groups that won't be drained because of an
+// earlier failure. Best effort pods are left out, like they are from the results of drained groups.
+func skipGroups(ds *drainState, groups []podEvictionGroup, evictionResults map[string]status.PodEvictionResult) map[string]status.PodEvictionResult {
+	for _, group := range groups {
+		for _, pod := range group.FullEvictionPods {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Skipped: true,
+				Err: errors.NewAutoscalerError(errors.UnexpectedScaleDownStateError, "Eviction of the pod %s not attempted due to earlier failure", pod.Name), DrainID: ds.id}
+		}
+	}
+	return evictionResults
+}
+
+// podsGoneWithNode reports the full eviction pods of the groups as removed, once the node was deleted.
+func podsGoneWithNode(ds *drainState, groups []podEvictionGroup, evictionResults map[string]status.PodEvictionResult) map[string]status.PodEvictionResult {
+	for _, group := range groups {
+		for _, pod := range group.FullEvictionPods {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+		}
+	}
+	return evictionResults
+}
+
+// abandonGroups reports the full eviction pods of groups that won't be drained as timed out, once TotalDrainTimeout is exceeded.
+func abandonGroups(ds *drainState, node *apiv1.Node, groups []podEvictionGroup, evictionResults map[string]status.PodEvictionResult, totalDrainTimeout time.Duration) (map[string]status.PodEvictionResult, error) {
+	for _, group := range groups {
+		for _, pod := range group.FullEvictionPods {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil, DrainID: ds.id}
+		}
+	}
+	return evictionResults, errors.NewAutoscalerErrorWrapping(errors.TransientError, ErrDrainTimeout, "Failed to drain node %s/%s: total drain timeout of %v exceeded", node.Namespace, node.Name, totalDrainTimeout)
+}
+
+// pollInterval returns how long to wait before checking again whether evicted pods are gone, given the remaining
+// wait time. It's half of the remaining time, bounded by MinPollInterval and MaxPollInterval, and never exceeds
+// the remaining time.
+func (e Evictor) pollInterval(remaining time.Duration) time.Duration {
+	maxInterval := e.MaxPollInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultPollInterval
+	}
+	minInterval := e.MinPollInterval
+	if minInterval <= 0 || minInterval > maxInterval {
+		minInterval = maxInterval
+	}
+	interval := min(max(remaining/2, minInterval), maxInterval)
+	return min(interval, remaining)
+}
+
+// notifySlowTermination calls SlowTerminationCallback if the pod, still present after waiting for it for waited,
+// is past its termination grace period and wasn't reported before.
+func (e Evictor) notifySlowTermination(pod *apiv1.Pod, waited time.Duration, slowPods map[*apiv1.Pod]bool) {
+	if e.SlowTerminationCallback == nil || slowPods[pod] {
+		return
+	}
+	gracePeriod := time.Duration(apiv1.DefaultTerminationGracePeriodSeconds) * time.Second
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		gracePeriod = time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	}
+	if overBy := waited - gracePeriod; overBy > 0 {
+		slowPods[pod] = true
+		e.SlowTerminationCallback(pod, overBy)
+	}
+}
+
+func (e Evictor) waitPodsToDisappear(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, pods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult,
+	timeout time.Duration) (map[string]status.PodEvictionResult, error) {
+	clk := e.getClock()
+	start := clk.Now()
+	defer func() { ds.timing.addWait(clk.Since(start)) }()
+	slowPods := make(map[*apiv1.Pod]bool)
+	movedPods := make(map[*apiv1.Pod]bool)
+	finalizerPods := make(map[string]bool)
+	var podVolumes map[*apiv1.Pod][]string
+	if e.WaitForVolumeDetach {
+		podVolumes = e.podPersistentVolumes(ctx, ds, pods)
+	}
+	var allGone, forced bool
+	for ; clk.Since(start) < timeout; e.sleep(ds, e.pollInterval(timeout-clk.Since(start))) {
+		allGone = true
+		checkNode := e.CheckNodeDeletion
+		// All pods are checked even once one of them is found, so that each slow pod is noticed on time.
+		for _, pod := range pods {
+			podReturned, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			if kube_errors.IsNotFound(err) || (err == nil && podReturned.Spec.NodeName != node.Name) {
+				checkNode = true
+			}
+			if err == nil && !podMoved(node, pod, podReturned, movedPods) && !e.heldByFinalizers(ctx, ds, podReturned, finalizerPods) {
+				klog.V(1).Infof("%sNot deleted yet %s/%s", ds.logPrefix(), pod.Namespace, pod.Name)
+				e.notifySlowTermination(pod, clk.Since(start), slowPods)
+				allGone = false
+			} else if err != nil && !kube_errors.IsNotFound(err) {
+				klog.Errorf("%sFailed to check pod %s/%s: %v", ds.logPrefix(), pod.Namespace, pod.Name, err)
+				allGone = false
+			}
+		}
+		if allGone && len(podVolumes) > 0 {
+			allGone = len(e.podsWithAttachedVolumes(ctx, ds, node, podVolumes)) == 0
+		}
+		if allGone {
+			return evictionResults, nil
+		}
+		if ds.cancelled() {
+			return evictionResults, ErrDrainCancelled
+		}
+		if checkNode && e.nodeDeleted(ctx, node) {
+			for _, pod := range pods {
+				evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+			}
+			return evictionResults, errNodeDeleted
+		}
+		if e.forceDeleteOn(ForceDeleteOnTimeout) && !forced && clk.Since(start) >= e.ForceDrainAfter {
+			e.forceDeletePods(ctx, ds, node, pods, fmt.Sprintf("still present %v after eviction", e.ForceDrainAfter))
+			forced = true
+		}
+	}
+
+	var remainingPods []*apiv1.Pod
+	var attachedPods map[*apiv1.Pod]bool
+	if len(podVolumes) > 0 {
+		attachedPods = e.podsWithAttachedVolumes(ctx, ds, node, podVolumes)
+	}
+	for _, pod := range pods {
+		podReturned, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		if err == nil && !podMoved(node, pod, podReturned, movedPods) && !e.heldByFinalizers(ctx, ds, podReturned, finalizerPods) {
+			e.notifySlowTermination(pod, clk.Since(start), slowPods)
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil, DrainID: ds.id}
+			remainingPods = append(remainingPods, pod)
+		} else if err != nil && !kube_errors.IsNotFound(err) {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: err, DrainID: ds.id}
+			remainingPods = append(remainingPods, pod)
+		} else if attachedPods[pod] {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: fmt.Errorf("volumes of pod %s/%s are still attached to node %s", pod.Namespace, pod.Name, node.Name), DrainID: ds.id}
+			remainingPods = append(remainingPods, pod)
+		} else {
+			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+		}
+	}
+
+	return evictionResults, podsRemainingError(node, remainingPods)
+}
+
+// podMoved returns true if podReturned, the latest poll of the pod evicted from the node, shows the pod gone from
+// the node although a pod of its name still exists: it was replaced by a pod with another UID, or it was reported on
+// another node by two consecutive polls. Requiring two polls keeps a stale read, e.g. one with an empty NodeName,
+// from ending the wait early. movedPods tracks the pods reported on another node by the previous poll.
+func podMoved(node *apiv1.Node, pod, podReturned *apiv1.Pod, movedPods map[*apiv1.Pod]bool) bool {
+	if podReturned == nil {
+		return false
+	}
+	if pod.UID != "" && podReturned.UID != "" && podReturned.UID != pod.UID {
+		return true
+	}
+	if podReturned.Spec.NodeName == "" || podReturned.Spec.NodeName == node.Name {
+		delete(movedPods, pod)
+		return false
+	}
+	if movedPods[pod] {
+		return true
+	}
+	movedPods[pod] = true
+	return false
 }
 
-// NewEvictor returns an instance of Evictor.
-func NewEvictor(evictionRegister evictionRegister, shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority, fullDsEviction bool) Evictor {
-	sort.Slice(shutdownGracePeriodByPodPriority, func(i, j int) bool {
-		return shutdownGracePeriodByPodPriority[i].Priority < shutdownGracePeriodByPodPriority[j].Priority
-	})
+// nodeDeleted returns true if the node doesn't exist anymore. If that can't be checked, the node is assumed to exist.
+func (e Evictor) nodeDeleted(ctx *acontext.AutoscalingContext, node *apiv1.Node) bool {
+	_, err := ctx.ClientSet.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	return kube_errors.IsNotFound(err)
+}
 
-	return Evictor{
-		EvictionRetryTime:                DefaultEvictionRetryTime,
-		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
-		evictionRegister:                 evictionRegister,
-		shutdownGracePeriodByPodPriority: shutdownGracePeriodByPodPriority,
-		fullDsEviction:                   fullDsEviction,
+// WaitForPodGone waits until the pod is gone from its node, using the same checks and poll interval as draining.
+// The result is TimedOut if the pod is still there after timeout.
+func (e Evictor) WaitForPodGone(ctx *acontext.AutoscalingContext, pod *apiv1.Pod, timeout time.Duration) status.PodEvictionResult {
+	node := &apiv1.Node{ObjectMeta: metav1.ObjectMeta{Name: pod.Spec.NodeName}}
+	ds := &drainState{}
+	results, err := e.waitPodsToDisappear(ctx, ds, node, []*apiv1.Pod{pod}, map[string]status.PodEvictionResult{}, timeout)
+	if result, found := results[pod.Name]; found {
+		return result
 	}
+	return status.PodEvictionResult{Pod: pod, TimedOut: false, Err: err, DrainID: ds.id}
 }
 
-// DrainNode groups pods in the node in to priority groups and, evicts pods in the ascending order of priorities.
-// If priority evictor is not enable, eviction of daemonSet pods is the best effort.
-func (e Evictor) DrainNode(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) (map[string]status.PodEvictionResult, error) {
-	node := nodeInfo.Node()
-	dsPods, pods := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForOccupiedNodes)
-	if e.fullDsEviction {
-		return e.drainNodeWithPodsBasedOnPodPriority(ctx, node, append(pods, dsPods...), nil)
+// heldByFinalizers returns true if the pod is terminating with all its containers stopped, but is held by
+// finalizers, and FinalizerPodsDrained is enabled. If RemoveFinalizer is one of the finalizers, it's removed. Pods
+// are logged once, when they're first seen held by finalizers, and recorded in reported.
+func (e Evictor) heldByFinalizers(ctx *acontext.AutoscalingContext, ds *drainState, pod *apiv1.Pod, reported map[string]bool) bool {
+	if !e.FinalizerPodsDrained && e.RemoveFinalizer == "" {
+		return false
+	}
+	if pod == nil || pod.DeletionTimestamp == nil || len(pod.Finalizers) == 0 || len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Terminated == nil {
+			return false
+		}
+	}
+	if key := pod.Namespace + "/" + pod.Name; !reported[key] {
+		reported[key] = true
+		klog.V(1).Infof("%sPod %s is terminating, but held by finalizers %v", ds.logPrefix(), key, pod.Finalizers)
+	}
+	if e.RemoveFinalizer != "" && slices.Contains(pod.Finalizers, e.RemoveFinalizer) {
+		updated := pod.DeepCopy()
+		updated.Finalizers = slices.DeleteFunc(updated.Finalizers, func(f string) bool { return f == e.RemoveFinalizer })
+		if _, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+			klog.Errorf("%sFailed to remove finalizer %s from pod %s/%s: %v", ds.logPrefix(), e.RemoveFinalizer, pod.Namespace, pod.Name, err)
+		}
 	}
-	return e.drainNodeWithPodsBasedOnPodPriority(ctx, node, pods, dsPods)
+	return e.FinalizerPodsDrained
 }
 
-// EvictDaemonSetPods groups  daemonSet pods in the node in to priority groups and, evicts daemonSet pods in the ascending order of priorities.
-// If priority evictor is not enable, eviction of daemonSet pods is the best effort.
-func (e Evictor) EvictDaemonSetPods(ctx *acontext.AutoscalingContext, nodeInfo *framework.NodeInfo) (map[string]status.PodEvictionResult, error) {
-	node := nodeInfo.Node()
-	dsPods, _ := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForEmptyNodes)
-	if e.fullDsEviction {
-		return e.drainNodeWithPodsBasedOnPodPriority(ctx, node, dsPods, nil)
+// forceDeleteOn returns whether failures of the trigger's kind escalate to force deletion.
+func (e Evictor) forceDeleteOn(trigger ForceDeleteTrigger) bool {
+	if e.ForceDrainAfter <= 0 {
+		return false
+	}
+	if len(e.ForceDeleteTriggers) == 0 {
+		return trigger == ForceDeleteOnTimeout
 	}
-	return e.drainNodeWithPodsBasedOnPodPriority(ctx, node, nil, dsPods)
+	return e.ForceDeleteTriggers[trigger]
 }
 
-// drainNodeWithPodsBasedOnPodPriority performs drain logic on the node based on pod priorities.
-// Removes all pods, giving each pod group up to ShutdownGracePeriodSeconds to finish. The list of pods to evict has to be provided.
-func (e Evictor) drainNodeWithPodsBasedOnPodPriority(ctx *acontext.AutoscalingContext, node *apiv1.Node, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) (map[string]status.PodEvictionResult, error) {
-	evictionResults := make(map[string]status.PodEvictionResult)
+// evictionFailureTrigger returns the force delete trigger matching the error a pod's eviction failed with.
+func evictionFailureTrigger(err error) ForceDeleteTrigger {
+	if kube_errors.IsTooManyRequests(err) {
+		return ForceDeleteOnPDBViolation
+	}
+	return ForceDeleteOnAPIError
+}
 
-	groups := groupByPriority(e.shutdownGracePeriodByPodPriority, fullEvictionPods, bestEffortEvictionPods)
-	for _, group := range groups {
-		for _, pod := range group.FullEvictionPods {
-			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false,
-				Err: errors.NewAutoscalerError(errors.UnexpectedScaleDownStateError, "Eviction did not attempted for the pod %s because some of the previous evictions failed", pod.Name)}
+// forceDeleteFailedEvictions force deletes the pods whose eviction failed for a reason selected by
+// ForceDeleteTriggers. The force deleted pods are reported as evicted, so that they're waited for like the others.
+func (e Evictor) forceDeleteFailedEvictions(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, pods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult) {
+	var failed []*apiv1.Pod
+	for _, pod := range pods {
+		if result := evictionResults[pod.Name]; result.Err != nil && e.forceDeleteOn(evictionFailureTrigger(result.Err)) {
+			failed = append(failed, pod)
 		}
 	}
+	if len(failed) == 0 {
+		return
+	}
+	for _, pod := range e.forceDeletePods(ctx, ds, node, failed, "whose eviction failed") {
+		evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+	}
+}
 
-	for _, group := range groups {
-		// If there are no pods in a particular range,
-		// then do not wait for pods in that priority range.
-		if len(group.FullEvictionPods) == 0 && len(group.BestEffortEvictionPods) == 0 {
+// forceDeletePods deletes the pods still on the node with a zero grace period, and lists the deleted pods in the
+// ForceDeletedPodsAnnotationKey annotation of the node. It returns the deleted pods. The reason explains the
+// escalation in logs and events. Errors are only logged, the pods are waited for as usual
+// afterwards.
+func (e Evictor) forceDeletePods(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, pods []*apiv1.Pod, reason string) []*apiv1.Pod {
+	var forceDeleted []string
+	var deletedPods []*apiv1.Pod
+	for _, pod := range pods {
+		podReturned, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		if err != nil || podReturned == nil || podReturned.Spec.NodeName != node.Name || podReturned.UID != pod.UID {
 			continue
 		}
-
-		var err error
-		evictionResults, err = e.initiateEviction(ctx, node, group.FullEvictionPods, group.BestEffortEvictionPods, evictionResults, group.ShutdownGracePeriodSeconds)
-		if err != nil {
-			return evictionResults, err
+		if len(e.ForceDeleteAllowedPriorityClasses) > 0 && !e.ForceDeleteAllowedPriorityClasses[pod.Spec.PriorityClassName] {
+			klog.V(2).Infof("%sPod %s/%s of priority class %q still on node %s, not allowed to force delete it", ds.logPrefix(), pod.Namespace, pod.Name, pod.Spec.PriorityClassName, node.Name)
+			continue
 		}
-
-		// Evictions created successfully, wait ShutdownGracePeriodSeconds + podEvictionHeadroom to see if fullEviction pods really disappeared.
-		evictionResults, err = e.waitPodsToDisappear(ctx, node, group.FullEvictionPods, evictionResults, group.ShutdownGracePeriodSeconds)
-		if err != nil {
-			return evictionResults, err
+		klog.Warningf("%sPod %s/%s on node %s %s, force deleting it", ds.logPrefix(), pod.Namespace, pod.Name, node.Name, reason)
+		ctx.Recorder.Eventf(pod, apiv1.EventTypeWarning, "ScaleDownForceDelete", "%sforce deleting pod %s", ds.logPrefix(), reason)
+		err = e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: ptr.To(int64(0)),
+			Preconditions:      metav1.NewUIDPreconditions(string(pod.UID)),
+			PropagationPolicy:  e.ForceDeletePropagationPolicy,
+		})
+		if e.classifyAPIError(err) != APIErrorSuccess {
+			klog.Errorf("%sFailed to force delete pod %s/%s: %v", ds.logPrefix(), pod.Namespace, pod.Name, err)
+		} else if err == nil {
+			forceDeleted = append(forceDeleted, pod.Namespace+"/"+pod.Name)
+			deletedPods = append(deletedPods, pod)
+			e.recordEviction(pod, "ScaleDownForceDelete", 0, true)
 		}
 	}
-	klog.V(1).Infof("All pods removed from %s", node.Name)
-	return evictionResults, nil
+	if len(forceDeleted) > 0 {
+		sort.Strings(forceDeleted)
+		annotateNode(ds, ctx, node, ForceDeletedPodsAnnotationKey, strings.Join(forceDeleted, ","))
+	}
+	return deletedPods
 }
 
-func (e Evictor) waitPodsToDisappear(ctx *acontext.AutoscalingContext, node *apiv1.Node, pods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult,
-	maxTermination int64) (map[string]status.PodEvictionResult, error) {
-	var allGone bool
-	for start := time.Now(); time.Now().Sub(start) < time.Duration(maxTermination)*time.Second+e.PodEvictionHeadroom; time.Sleep(5 * time.Second) {
-		allGone = true
-		for _, pod := range pods {
-			podReturned, err := ctx.ClientSet.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
-			if err == nil && (podReturned == nil || podReturned.Spec.NodeName == node.Name) {
-				klog.V(1).Infof("Not deleted yet %s/%s", pod.Namespace, pod.Name)
-				allGone = false
-				break
+// recordEviction passes a record of the pod's eviction to AuditSink, if it's set.
+func (e Evictor) recordEviction(pod *apiv1.Pod, reason string, gracePeriodSeconds int64, forced bool) {
+	if e.AuditSink == nil {
+		return
+	}
+	e.AuditSink.RecordEviction(EvictionRecord{
+		Time:               e.getClock().Now(),
+		Namespace:          pod.Namespace,
+		Name:               pod.Name,
+		UID:                pod.UID,
+		NodeName:           pod.Spec.NodeName,
+		Reason:             reason,
+		Initiator:          AuditInitiator,
+		GracePeriodSeconds: gracePeriodSeconds,
+		Forced:             forced,
+	})
+}
+
+// podPersistentVolumes returns the names of the persistent volumes bound to the claims used by each pod. Claims
+// that can't be resolved are skipped, so their volumes aren't waited for.
+func (e Evictor) podPersistentVolumes(ctx *acontext.AutoscalingContext, ds *drainState, pods []*apiv1.Pod) map[*apiv1.Pod][]string {
+	podVolumes := make(map[*apiv1.Pod][]string)
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
 			}
-			if err != nil && !kube_errors.IsNotFound(err) {
-				klog.Errorf("Failed to check pod %s/%s: %v", pod.Namespace, pod.Name, err)
-				allGone = false
-				break
+			claim, err := e.clientSet(ctx).CoreV1().PersistentVolumeClaims(pod.Namespace).Get(context.TODO(), volume.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
+			if err != nil {
+				klog.Warningf("%sFailed to get claim %s/%s of pod %s, not waiting for its volume to detach: %v", ds.logPrefix(), pod.Namespace, volume.PersistentVolumeClaim.ClaimName, pod.Name, err)
+				continue
+			}
+			if claim.Spec.VolumeName != "" {
+				podVolumes[pod] = append(podVolumes[pod], claim.Spec.VolumeName)
 			}
-		}
-		if allGone {
-			return evictionResults, nil
 		}
 	}
+	return podVolumes
+}
 
-	for _, pod := range pods {
-		podReturned, err := ctx.ClientSet.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
-		if err == nil && (podReturned == nil || podReturned.Name == "" || podReturned.Spec.NodeName == node.Name) {
-			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil}
-		} else if err != nil && !kube_errors.IsNotFound(err) {
-			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: err}
-		} else {
-			evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil}
+// podsWithAttachedVolumes returns the pods with any of their persistent volumes still attached to the node. If
+// VolumeAttachments can't be listed, all pods with persistent volumes are assumed to have them attached.
+func (e Evictor) podsWithAttachedVolumes(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, podVolumes map[*apiv1.Pod][]string) map[*apiv1.Pod]bool {
+	attachedPods := make(map[*apiv1.Pod]bool)
+	attachments, err := e.volumeAttachments(ctx)
+	if err != nil {
+		klog.Errorf("%sFailed to list volume attachments: %v", ds.logPrefix(), err)
+		for pod := range podVolumes {
+			attachedPods[pod] = true
+		}
+		return attachedPods
+	}
+	attached := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.Spec.NodeName == node.Name && attachment.Spec.Source.PersistentVolumeName != nil {
+			attached[*attachment.Spec.Source.PersistentVolumeName] = true
+		}
+	}
+	for pod, volumes := range podVolumes {
+		for _, volume := range volumes {
+			if attached[volume] {
+				klog.V(1).Infof("%sVolume %s of pod %s/%s not detached from node %s yet", ds.logPrefix(), volume, pod.Namespace, pod.Name, node.Name)
+				attachedPods[pod] = true
+				break
+			}
 		}
 	}
+	return attachedPods
+}
+
+// volumeAttachments returns the VolumeAttachments from VolumeAttachmentLister, or from the API server if it's not set.
+func (e Evictor) volumeAttachments(ctx *acontext.AutoscalingContext) ([]*storagev1.VolumeAttachment, error) {
+	if e.VolumeAttachmentLister != nil {
+		return e.VolumeAttachmentLister.List(labels.Everything())
+	}
+	attachmentList, err := e.clientSet(ctx).StorageV1().VolumeAttachments().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]*storagev1.VolumeAttachment, 0, len(attachmentList.Items))
+	for i := range attachmentList.Items {
+		attachments = append(attachments, &attachmentList.Items[i])
+	}
+	return attachments, nil
+}
+
+// podsRemainingError returns the error reported when pods are still on the node after the drain timed out.
+func podsRemainingError(node *apiv1.Node, remainingPods []*apiv1.Pod) errors.AutoscalerError {
+	names := make([]string, 0, len(remainingPods))
+	for _, pod := range remainingPods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return errors.NewAutoscalerErrorWrapping(errors.TransientError, ErrDrainTimeout, "Failed to drain node %s/%s: pods remaining after timeout: %s", node.Namespace, node.Name, strings.Join(names, ", "))
+}
 
-	return evictionResults, errors.NewAutoscalerError(errors.TransientError, "Failed to drain node %s/%s: pods remaining after timeout", node.Namespace, node.Name)
+// podEvictionTime returns how long evictions are retried for, the MaxPodEvictionTime of the node group policy if it
+// set one, or of the autoscaling context otherwise.
+func (e Evictor) podEvictionTime(ctx *acontext.AutoscalingContext, ds *drainState) time.Duration {
+	if ds.maxPodEvictionTime > 0 {
+		return ds.maxPodEvictionTime
+	}
+	return ctx.MaxPodEvictionTime
 }
 
-func (e Evictor) initiateEviction(ctx *acontext.AutoscalingContext, node *apiv1.Node, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult,
-	maxTermination int64) (map[string]status.PodEvictionResult, error) {
+// initiateEviction evicts the pods, retrying failed evictions for up to MaxPodEvictionTime, but not past deadline
+// if it's set, so that retries don't overrun TotalDrainTimeout.
+func (e Evictor) initiateEviction(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod, evictionResults map[string]status.PodEvictionResult,
+	maxTermination, minTermination int64, deadline time.Time) (map[string]status.PodEvictionResult, error) {
 
-	retryUntil := time.Now().Add(ctx.MaxPodEvictionTime)
+	start := e.getClock().Now()
+	defer func() { ds.timing.addEviction(e.getClock().Since(start)) }()
+	bestEffortEvictionPods = withoutPods(ds, bestEffortEvictionPods, fullEvictionPods)
+	retryUntil := start.Add(e.podEvictionTime(ctx, ds))
+	if !deadline.IsZero() && deadline.Before(retryUntil) {
+		retryUntil = deadline
+	}
 	fullEvictionConfirmations := make(chan status.PodEvictionResult, len(fullEvictionPods))
 	bestEffortEvictionConfirmations := make(chan status.PodEvictionResult, len(bestEffortEvictionPods))
 
+	semaphores := newOwnerSemaphores(e.MaxConcurrentEvictionsPerOwner)
+	limiters := newNamespaceRateLimiters(e.NamespaceEvictionQPS, e.NamespaceEvictionBurst, e.getClock())
+
 	for _, pod := range fullEvictionPods {
-		evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil}
+		evictionResults[pod.Name] = status.PodEvictionResult{Pod: pod, TimedOut: true, Err: nil, DrainID: ds.id}
+		sem := semaphores.forPod(pod)
+		limiter := limiters.forPod(pod)
 		go func(pod *apiv1.Pod) {
-			fullEvictionConfirmations <- e.evictPod(ctx, pod, retryUntil, maxTermination, true)
+			podRetryUntil, done := e.delayJobPod(ctx, ds, pod, retryUntil, deadline)
+			if done {
+				fullEvictionConfirmations <- status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+				return
+			}
+			fullEvictionConfirmations <- withEvictionLimits(sem, limiter, func() status.PodEvictionResult {
+				if e.RequeueFailedEvictions {
+					return e.evictPodFirstPass(ctx, ds, pod, maxTermination, minTermination)
+				}
+				return e.evictPod(ctx, ds, pod, podRetryUntil, maxTermination, minTermination, true)
+			})
 		}(pod)
 	}
 
 	for _, pod := range bestEffortEvictionPods {
+		sem := semaphores.forPod(pod)
+		limiter := limiters.forPod(pod)
 		go func(pod *apiv1.Pod) {
-			bestEffortEvictionConfirmations <- e.evictPod(ctx, pod, retryUntil, maxTermination, false)
+			podRetryUntil, done := e.delayJobPod(ctx, ds, pod, retryUntil, deadline)
+			if done {
+				bestEffortEvictionConfirmations <- status.PodEvictionResult{Pod: pod, TimedOut: false, Err: nil, DrainID: ds.id}
+				return
+			}
+			bestEffortEvictionConfirmations <- withEvictionLimits(sem, limiter, func() status.PodEvictionResult {
+				return e.evictPod(ctx, ds, pod, podRetryUntil, maxTermination, minTermination, false)
+			})
 		}(pod)
 	}
 
+	recordResult := func(evictionResult status.PodEvictionResult) {
+		evictionResults[evictionResult.Pod.Name] = evictionResult
+		result := metrics.PodEvictionFailed
+		if evictionResult.WasEvictionSuccessful() {
+			result = metrics.PodEvictionSucceed
+		}
+		metrics.RegisterEvictions(1, result)
+		if len(e.EvictionMetricsNamespaces) > 0 {
+			metrics.RegisterEvictionsByNamespace(1, result, evictionResult.Pod.Namespace, e.EvictionMetricsNamespaces)
+		}
+	}
+
+	var requeuedPods []*apiv1.Pod
 	for i := 0; i < len(fullEvictionPods)+len(bestEffortEvictionPods); i++ {
 		select {
 		case evictionResult := <-fullEvictionConfirmations:
-			evictionResults[evictionResult.Pod.Name] = evictionResult
-			if evictionResult.WasEvictionSuccessful() {
-				metrics.RegisterEvictions(1, metrics.PodEvictionSucceed)
-			} else {
-				metrics.RegisterEvictions(1, metrics.PodEvictionFailed)
+			if e.RequeueFailedEvictions && evictionResult.TimedOut && !ds.cancelled() {
+				requeuedPods = append(requeuedPods, evictionResult.Pod)
+				continue
 			}
+			recordResult(evictionResult)
 		case <-bestEffortEvictionConfirmations:
 		}
 	}
 
+	// Evictions that failed transiently in the first pass are retried for the rest of the retry time. They're still
+	// subject to the owner and namespace limits.
+	requeueConfirmations := make(chan status.PodEvictionResult, len(requeuedPods))
+	for _, pod := range requeuedPods {
+		klog.V(2).Infof("%sRetrying eviction of pod %s/%s after the rest of its group", ds.logPrefix(), pod.Namespace, pod.Name)
+		sem := semaphores.forPod(pod)
+		limiter := limiters.forPod(pod)
+		go func(pod *apiv1.Pod) {
+			requeueConfirmations <- withEvictionLimits(sem, limiter, func() status.PodEvictionResult {
+				return e.evictPodWithRetries(ctx, ds, pod, retryUntil, maxTermination, minTermination, true)
+			})
+		}(pod)
+	}
+	for range requeuedPods {
+		recordResult(<-requeueConfirmations)
+	}
+
+	if !ds.cancelled() {
+		e.forceDeleteFailedEvictions(ctx, ds, node, fullEvictionPods, evictionResults)
+	}
 	evictionErrs := make([]error, 0)
 	for _, pod := range fullEvictionPods {
 		result := evictionResults[pod.Name]
@@ -209,53 +2004,446 @@ func (e Evictor) initiateEviction(ctx *acontext.AutoscalingContext, node *apiv1.
 			evictionErrs = append(evictionErrs, result.Err)
 		}
 	}
+	if len(evictionErrs) != 0 && ds.cancelled() {
+		return evictionResults, ErrDrainCancelled
+	}
 	if len(evictionErrs) != 0 {
 		return evictionResults, errors.NewAutoscalerError(errors.ApiCallError, "Failed to drain node %s/%s, due to following errors: %v", node.Namespace, node.Name, evictionErrs)
 	}
 	return evictionResults, nil
 }
 
-func (e Evictor) evictPod(ctx *acontext.AutoscalingContext, podToEvict *apiv1.Pod, retryUntil time.Time, maxTermination int64, fullEvictionPod bool) status.PodEvictionResult {
-	ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeNormal, "ScaleDown", "deleting pod for node scale down")
+// withEvictionLimits runs evict once the owner semaphore and the namespace rate limiter allow it. Both are optional.
+func withEvictionLimits(sem chan struct{}, limiter flowcontrol.RateLimiter, evict func() status.PodEvictionResult) status.PodEvictionResult {
+	if sem != nil {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+	}
+	if limiter != nil {
+		limiter.Accept()
+	}
+	return evict()
+}
+
+// ownerSemaphores limits the number of concurrent evictions of pods controlled by the same owner.
+type ownerSemaphores struct {
+	limit      int
+	semaphores map[types.UID]chan struct{}
+}
 
-	termination := int64(apiv1.DefaultTerminationGracePeriodSeconds)
-	if podToEvict.Spec.TerminationGracePeriodSeconds != nil {
-		termination = *podToEvict.Spec.TerminationGracePeriodSeconds
+func newOwnerSemaphores(limit int) *ownerSemaphores {
+	return &ownerSemaphores{limit: limit, semaphores: make(map[types.UID]chan struct{})}
+}
+
+// forPod returns the semaphore shared by pods with the same controller as the given pod, or nil if evictions
+// of the pod aren't limited. It's not safe for concurrent use, so it has to be called before evictions start.
+func (o *ownerSemaphores) forPod(pod *apiv1.Pod) chan struct{} {
+	if o.limit <= 0 {
+		return nil
 	}
-	if maxTermination > 0 && termination > maxTermination {
-		termination = maxTermination
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+	sem, found := o.semaphores[owner.UID]
+	if !found {
+		sem = make(chan struct{}, o.limit)
+		o.semaphores[owner.UID] = sem
+	}
+	return sem
+}
+
+// namespaceRateLimiters paces the start of evictions of pods in the same namespace.
+type namespaceRateLimiters struct {
+	qps      float32
+	burst    int
+	clock    flowcontrol.Clock
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+func newNamespaceRateLimiters(qps float32, burst int, clock flowcontrol.Clock) *namespaceRateLimiters {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &namespaceRateLimiters{qps: qps, burst: burst, clock: clock, limiters: make(map[string]flowcontrol.RateLimiter)}
+}
+
+// forPod returns the rate limiter shared by pods in the same namespace as the given pod, or nil if evictions
+// aren't rate limited. It's not safe for concurrent use, so it has to be called before evictions start.
+func (n *namespaceRateLimiters) forPod(pod *apiv1.Pod) flowcontrol.RateLimiter {
+	if n.qps <= 0 {
+		return nil
+	}
+	limiter, found := n.limiters[pod.Namespace]
+	if !found {
+		limiter = flowcontrol.NewTokenBucketRateLimiterWithClock(n.qps, n.burst, n.clock)
+		n.limiters[pod.Namespace] = limiter
+	}
+	return limiter
+}
+
+// withoutPods returns the pods that don't share a UID with any of the excluded pods.
+func withoutPods(ds *drainState, pods, excluded []*apiv1.Pod) []*apiv1.Pod {
+	if len(pods) == 0 || len(excluded) == 0 {
+		return pods
+	}
+	excludedUIDs := make(map[types.UID]bool, len(excluded))
+	for _, pod := range excluded {
+		excludedUIDs[pod.UID] = true
+	}
+	result := make([]*apiv1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if excludedUIDs[pod.UID] {
+			klog.Warningf("%sPod %s/%s is scheduled for both full and best effort eviction, evicting it only once", ds.logPrefix(), pod.Namespace, pod.Name)
+			continue
+		}
+		result = append(result, pod)
+	}
+	return result
+}
+
+func (e Evictor) evictPod(ctx *acontext.AutoscalingContext, ds *drainState, podToEvict *apiv1.Pod, retryUntil time.Time, maxTermination, minTermination int64, fullEvictionPod bool) status.PodEvictionResult {
+	if e.SkipAbsentPods && e.podAbsent(ctx, podToEvict) {
+		klog.V(2).Infof("%sPod %s/%s is already gone from node %s, not evicting it", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name, podToEvict.Spec.NodeName)
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: nil, BestEffort: !fullEvictionPod, DrainID: ds.id}
 	}
+	ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeNormal, "ScaleDown", "%sdeleting pod for node scale down", ds.logPrefix())
+	result := e.evictPodWithRetries(ctx, ds, podToEvict, retryUntil, maxTermination, minTermination, fullEvictionPod)
+	result.BestEffort = !fullEvictionPod
+	return result
+}
+
+// evictPodFirstPass makes a single eviction attempt of a full eviction pod, for RequeueFailedEvictions. A transient
+// failure is returned as timed out without being reported, so that initiateEviction requeues the pod.
+func (e Evictor) evictPodFirstPass(ctx *acontext.AutoscalingContext, ds *drainState, podToEvict *apiv1.Pod, maxTermination, minTermination int64) status.PodEvictionResult {
+	if e.SkipAbsentPods && e.podAbsent(ctx, podToEvict) {
+		klog.V(2).Infof("%sPod %s/%s is already gone from node %s, not evicting it", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name, podToEvict.Spec.NodeName)
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: nil, DrainID: ds.id}
+	}
+	if ds.cancelled() {
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: ErrDrainCancelled, DrainID: ds.id}
+	}
+	ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeNormal, "ScaleDown", "%sdeleting pod for node scale down", ds.logPrefix())
+	termination := e.evictionTermination(ds, podToEvict, maxTermination, minTermination, true)
+	class, err := e.tryEvict(ctx, podToEvict, termination)
+	switch class {
+	case APIErrorSuccess:
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: nil, DrainID: ds.id}
+	case APIErrorPermanent:
+		klog.Errorf("%sFailed to evict pod %s, permanent error: %v", ds.logPrefix(), podToEvict.Name, err)
+		ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeWarning, "ScaleDownFailed", "%sfailed to delete pod for ScaleDown", ds.logPrefix())
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: fmt.Errorf("failed to evict pod %s/%s, not retrying permanent error: %w", podToEvict.Namespace, podToEvict.Name, err), DrainID: ds.id}
+	default:
+		klog.V(2).Infof("%sEviction of pod %s/%s failed transiently, requeueing it: %v", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name, err)
+		return status.PodEvictionResult{Pod: podToEvict, TimedOut: true, Err: err, DrainID: ds.id}
+	}
+}
 
+// evictPodWithRetries evicts the pod, retrying failed evictions until retryUntil. Unlike evictPod, it doesn't
+// announce the eviction with an event, so that evicting the pod again later doesn't repeat it. Only failures are
+// recorded as events.
+func (e Evictor) evictPodWithRetries(ctx *acontext.AutoscalingContext, ds *drainState, podToEvict *apiv1.Pod, retryUntil time.Time, maxTermination, minTermination int64, fullEvictionPod bool) status.PodEvictionResult {
+	termination := e.evictionTermination(ds, podToEvict, maxTermination, minTermination, fullEvictionPod)
+	clk := e.getClock()
 	var lastError error
-	for first := true; first || time.Now().Before(retryUntil); time.Sleep(e.EvictionRetryTime) {
-		first = false
-		eviction := &policyv1beta1.Eviction{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: podToEvict.Namespace,
-				Name:      podToEvict.Name,
-			},
-			DeleteOptions: &metav1.DeleteOptions{
-				GracePeriodSeconds: &termination,
-			},
-		}
-		lastError = ctx.ClientSet.CoreV1().Pods(podToEvict.Namespace).Evict(context.TODO(), eviction)
-		if lastError == nil || kube_errors.IsNotFound(lastError) {
-			if e.evictionRegister != nil {
-				e.evictionRegister.RegisterEviction(podToEvict)
+	for attempt := 0; attempt == 0 || clk.Now().Before(retryUntil); e.sleep(ds, e.EvictionRetryTime) {
+		attempt++
+		if ds.cancelled() {
+			return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: ErrDrainCancelled, DrainID: ds.id}
+		}
+		if attempt > 1 && !ds.retryBudget.take() {
+			klog.V(2).Infof("%sRetry budget of the group of pod %s/%s is used up, not retrying its eviction", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name)
+			break
+		}
+		var class APIErrorClass
+		class, lastError = e.tryEvict(ctx, podToEvict, termination)
+		if class == APIErrorSuccess {
+			return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: nil, DrainID: ds.id}
+		}
+		if class == APIErrorPermanent {
+			if fullEvictionPod {
+				klog.Errorf("%sFailed to evict pod %s, permanent error: %v", ds.logPrefix(), podToEvict.Name, lastError)
+				ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeWarning, "ScaleDownFailed", "%sfailed to delete pod for ScaleDown", ds.logPrefix())
 			}
-			return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: nil}
+			return status.PodEvictionResult{Pod: podToEvict, TimedOut: false, Err: fmt.Errorf("failed to evict pod %s/%s, not retrying permanent error: %w", podToEvict.Namespace, podToEvict.Name, lastError), DrainID: ds.id}
+		}
+		if e.MaxEvictionRetries > 0 && attempt > e.MaxEvictionRetries {
+			break
 		}
 	}
 	if fullEvictionPod {
-		klog.Errorf("Failed to evict pod %s, error: %v", podToEvict.Name, lastError)
-		ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeWarning, "ScaleDownFailed", "failed to delete pod for ScaleDown")
+		klog.Errorf("%sFailed to evict pod %s, error: %v", ds.logPrefix(), podToEvict.Name, lastError)
+		ctx.Recorder.Eventf(podToEvict, apiv1.EventTypeWarning, "ScaleDownFailed", "%sfailed to delete pod for ScaleDown", ds.logPrefix())
+	}
+	return status.PodEvictionResult{Pod: podToEvict, TimedOut: true, Err: fmt.Errorf("failed to evict pod %s/%s within allowed timeout (last error: %w)", podToEvict.Namespace, podToEvict.Name, lastError), DrainID: ds.id}
+}
+
+// evictionTermination returns the grace period the pod is evicted with.
+func (e Evictor) evictionTermination(ds *drainState, podToEvict *apiv1.Pod, maxTermination, minTermination int64, fullEvictionPod bool) int64 {
+	termination := podTerminationGracePeriod(podToEvict, maxTermination, minTermination, e.GraceClampPolicy)
+	if !fullEvictionPod && e.DaemonSetGracePeriodSeconds != nil && pod_util.IsDaemonSetPod(podToEvict) {
+		termination = *e.DaemonSetGracePeriodSeconds
+	}
+	if e.EvictUnreadyImmediately && podUnreadyFor(podToEvict, e.getClock().Now()) > e.UnreadyThreshold && termination > e.UnreadyGracePeriodSeconds {
+		klog.V(2).Infof("%sPod %s/%s is unready for longer than %v, evicting it with a %ds grace period", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name, e.UnreadyThreshold, e.UnreadyGracePeriodSeconds)
+		termination = e.UnreadyGracePeriodSeconds
+	}
+	if e.EvictPendingImmediately && podToEvict.Status.Phase == apiv1.PodPending && termination > 0 {
+		klog.V(2).Infof("%sPod %s/%s is pending, evicting it with no grace period", ds.logPrefix(), podToEvict.Namespace, podToEvict.Name)
+		termination = 0
+	}
+	return termination
+}
+
+// tryEvict makes one eviction attempt of the pod and returns the class of its outcome along with the error. A
+// successful eviction is registered and recorded.
+func (e Evictor) tryEvict(ctx *acontext.AutoscalingContext, podToEvict *apiv1.Pod, termination int64) (APIErrorClass, error) {
+	err := e.evict(ctx, podToEvict, termination)
+	if kube_errors.IsNotFound(err) && e.StrictNotFound {
+		err = e.verifyPodGone(ctx, podToEvict)
+	}
+	class := e.classifyAPIError(err)
+	if class == APIErrorSuccess {
+		if e.evictionRegister != nil {
+			e.evictionRegister.RegisterEviction(podToEvict)
+		}
+		if err == nil {
+			e.recordEviction(podToEvict, "ScaleDown", termination, false)
+		}
+	}
+	return class, err
+}
+
+// classifyAPIError returns the class of err using the APIErrorClassifier override, if it's set. A nil error is always
+// a success.
+func (e Evictor) classifyAPIError(err error) APIErrorClass {
+	if err == nil {
+		return APIErrorSuccess
+	}
+	if e.APIErrorClassifier != nil {
+		return e.APIErrorClassifier(err)
+	}
+	return ClassifyAPIError(err)
+}
+
+// ClassifyAPIError returns the class of an error returned by the API server when evicting or deleting a pod. NotFound
+// is a success, request errors that won't change by retrying are permanent, and all other errors, including
+// unknown ones, are retryable.
+func ClassifyAPIError(err error) APIErrorClass {
+	switch {
+	case err == nil, kube_errors.IsNotFound(err):
+		return APIErrorSuccess
+	case kube_errors.IsForbidden(err), kube_errors.IsInvalid(err), kube_errors.IsBadRequest(err), kube_errors.IsMethodNotSupported(err):
+		return APIErrorPermanent
+	default:
+		return APIErrorRetryable
+	}
+}
+
+// retryBudget is a number of retries shared by several evictions. A nil retryBudget is unbounded. It's safe for
+// concurrent use.
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+// newRetryBudget returns a budget of the given number of retries, or nil if it's not positive.
+func newRetryBudget(retries int) *retryBudget {
+	if retries <= 0 {
+		return nil
+	}
+	b := &retryBudget{}
+	b.remaining.Store(int64(retries))
+	return b
+}
+
+// take uses up a retry, returning false if none are left.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	return b.remaining.Add(-1) >= 0
+}
+
+// podUnreadyFor returns how long the pod has been unready for, according to its Ready condition, or zero if it's
+// ready or that's unknown.
+func podUnreadyFor(pod *apiv1.Pod, now time.Time) time.Duration {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodReady && condition.Status != apiv1.ConditionTrue && !condition.LastTransitionTime.IsZero() {
+			return now.Sub(condition.LastTransitionTime.Time)
+		}
+	}
+	return 0
+}
+
+// delayJobPod waits for the JobEvictionDelay of the pod, if any, before its eviction starts. It's done outside of the
+// owner semaphore, so that a delayed pod doesn't hold up the evictions of its peers. The retry time limit is pushed back
+// by the delay, but not past deadline if it's set. It returns true if the pod completed or is gone by then, so it
+// doesn't need evicting.
+func (e Evictor) delayJobPod(ctx *acontext.AutoscalingContext, ds *drainState, pod *apiv1.Pod, retryUntil, deadline time.Time) (time.Time, bool) {
+	delay := e.jobEvictionDelay(pod)
+	if delay <= 0 {
+		return retryUntil, false
+	}
+	klog.V(2).Infof("%sDelaying eviction of Job pod %s/%s by %v to let it complete", ds.logPrefix(), pod.Namespace, pod.Name, delay)
+	e.sleep(ds, delay)
+	if e.jobPodDone(ctx, pod) {
+		klog.V(2).Infof("%sJob pod %s/%s completed, not evicting it", ds.logPrefix(), pod.Namespace, pod.Name)
+		return retryUntil, true
+	}
+	retryUntil = retryUntil.Add(delay)
+	if !deadline.IsZero() && deadline.Before(retryUntil) {
+		retryUntil = deadline
+	}
+	return retryUntil, false
+}
+
+// jobEvictionDelay returns how long to delay the eviction of the pod, which is non-zero only for pods controlled
+// by a Job that report JobNearCompletionCondition, if it's set.
+func (e Evictor) jobEvictionDelay(pod *apiv1.Pod) time.Duration {
+	if e.JobEvictionDelay <= 0 {
+		return 0
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "Job" {
+		return 0
+	}
+	if e.JobNearCompletionCondition != "" && !hasPodCondition(pod, e.JobNearCompletionCondition) {
+		return 0
+	}
+	return e.JobEvictionDelay
+}
+
+func hasPodCondition(pod *apiv1.Pod, conditionType apiv1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// jobPodDone returns true if the pod completed or is gone. If that can't be checked, the pod is assumed to be
+// still running.
+func (e Evictor) jobPodDone(ctx *acontext.AutoscalingContext, pod *apiv1.Pod) bool {
+	podReturned, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if kube_errors.IsNotFound(err) {
+		return true
+	}
+	if err != nil || podReturned == nil {
+		return false
+	}
+	if podReturned.UID != pod.UID || podReturned.Spec.NodeName != pod.Spec.NodeName {
+		return true
+	}
+	return podReturned.Status.Phase == apiv1.PodSucceeded || podReturned.Status.Phase == apiv1.PodFailed
+}
+
+// podAbsent returns true if the pod doesn't exist anymore, or was replaced by another pod with the same name, or
+// isn't on the same node anymore. If that can't be checked, the pod is assumed to be present.
+func (e Evictor) podAbsent(ctx *acontext.AutoscalingContext, pod *apiv1.Pod) bool {
+	podReturned, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if kube_errors.IsNotFound(err) {
+		return true
+	}
+	if err != nil || podReturned == nil {
+		return false
+	}
+	return podReturned.UID != pod.UID || podReturned.Spec.NodeName != pod.Spec.NodeName
+}
+
+// evict creates an eviction for the pod using the eviction API version detected by NewEvictor, or deletes the pod
+// with the same grace period if EvictionMethod is GracefulDelete.
+func (e Evictor) evict(ctx *acontext.AutoscalingContext, pod *apiv1.Pod, termination int64) error {
+	objectMeta := metav1.ObjectMeta{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+	}
+	deleteOptions := &metav1.DeleteOptions{
+		GracePeriodSeconds: &termination,
+	}
+	pods := e.clientSet(ctx).CoreV1().Pods(pod.Namespace)
+	if e.EvictionMethod == GracefulDelete {
+		return pods.Delete(context.TODO(), pod.Name, *deleteOptions)
+	}
+	if e.evictionGroupVersion == policyv1.SchemeGroupVersion {
+		return pods.EvictV1(context.TODO(), &policyv1.Eviction{ObjectMeta: objectMeta, DeleteOptions: deleteOptions})
+	}
+	return pods.EvictV1beta1(context.TODO(), &policyv1beta1.Eviction{ObjectMeta: objectMeta, DeleteOptions: deleteOptions})
+}
+
+// verifyPodGone returns nil if the pod doesn't exist anymore.
+func (e Evictor) verifyPodGone(ctx *acontext.AutoscalingContext, pod *apiv1.Pod) error {
+	_, err := e.clientSet(ctx).CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if kube_errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify that pod %s/%s is gone: %v", pod.Namespace, pod.Name, err)
+	}
+	return fmt.Errorf("eviction of pod %s/%s returned NotFound, but the pod still exists", pod.Namespace, pod.Name)
+}
+
+// podTerminationGracePeriod returns the grace period used to evict the pod. It starts from the pod's own
+// terminationGracePeriodSeconds, clamps it down to maxTermination and raises it to at least minTermination.
+// The floor itself never exceeds maxTermination, since the drain only waits that long for the pod to disappear.
+// NeverShorten and UseGroup policies derive it from maxTermination instead, if it's set.
+func podTerminationGracePeriod(pod *apiv1.Pod, maxTermination, minTermination int64, policy GraceClampPolicy) int64 {
+	termination := int64(apiv1.DefaultTerminationGracePeriodSeconds)
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		termination = *pod.Spec.TerminationGracePeriodSeconds
+	}
+	switch {
+	case policy == UseGroup && maxTermination > 0:
+		return maxTermination
+	case policy == NeverShorten && termination < maxTermination:
+		return maxTermination
+	case policy == NeverShorten:
+		return termination
+	}
+	if maxTermination > 0 && termination > maxTermination {
+		termination = maxTermination
+	}
+	if maxTermination > 0 && minTermination > maxTermination {
+		minTermination = maxTermination
+	}
+	if termination < minTermination {
+		termination = minTermination
+	}
+	return termination
+}
+
+// podsToEvict returns the DaemonSet and other pods to evict from the node. The summary it logs is prefixed with
+// logPrefix, which identifies the drain, if any.
+func podsToEvict(nodeInfo *framework.NodeInfo, evictDsByDefault bool, logPrefix string) (dsPods, nonDsPods []*apiv1.Pod) {
+	dsPods, nonDsPods, summary := classifyPodsToEvict(nodeInfo, evictDsByDefault)
+	if nodeInfo.Node() != nil {
+		klog.V(4).Infof("%sPods to evict from node %s: %s", logPrefix, nodeInfo.Node().Name, summary)
 	}
-	return status.PodEvictionResult{Pod: podToEvict, TimedOut: true, Err: fmt.Errorf("failed to evict pod %s/%s within allowed timeout (last error: %v)", podToEvict.Namespace, podToEvict.Name, lastError)}
+	return dsPods, nonDsPods
+}
+
+// podsToEvictSummary counts the pods of a node by how they're treated when draining it.
+type podsToEvictSummary struct {
+	evictable         int
+	daemonSet         int
+	skippedDaemonSet  int
+	skippedMirrorPods int
+	skippedFakePods   int
 }
 
-func podsToEvict(nodeInfo *framework.NodeInfo, evictDsByDefault bool) (dsPods, nonDsPods []*apiv1.Pod) {
+func (s podsToEvictSummary) String() string {
+	return fmt.Sprintf("%d evictable, %d DaemonSet, %d skipped DaemonSet, %d skipped mirror pods, %d skipped fake pods", s.evictable, s.daemonSet, s.skippedDaemonSet, s.skippedMirrorPods, s.skippedFakePods)
+}
+
+func classifyPodsToEvict(nodeInfo *framework.NodeInfo, evictDsByDefault bool) (dsPodsToEvict, nonDsPods []*apiv1.Pod, summary podsToEvictSummary) {
+	var dsPods []*apiv1.Pod
 	for _, podInfo := range nodeInfo.Pods {
 		if pod_util.IsMirrorPod(podInfo.Pod) {
+			summary.skippedMirrorPods++
+			continue
+		} else if pod_util.IsFakePod(podInfo.Pod) {
+			// Fake pods only exist in the cluster snapshot, so there is nothing to evict.
+			summary.skippedFakePods++
 			continue
 		} else if pod_util.IsDaemonSetPod(podInfo.Pod) {
 			dsPods = append(dsPods, podInfo.Pod)
@@ -263,8 +2451,21 @@ func podsToEvict(nodeInfo *framework.NodeInfo, evictDsByDefault bool) (dsPods, n
 			nonDsPods = append(nonDsPods, podInfo.Pod)
 		}
 	}
-	dsPodsToEvict := daemonset.PodsToEvict(dsPods, evictDsByDefault)
-	return dsPodsToEvict, nonDsPods
+	dsPodsToEvict = daemonset.PodsToEvict(dsPods, evictDsByDefault)
+	summary.evictable = len(nonDsPods)
+	summary.daemonSet = len(dsPodsToEvict)
+	summary.skippedDaemonSet = len(dsPods) - len(dsPodsToEvict)
+	return dsPodsToEvict, nonDsPods, summary
+}
+
+func filterPods(pods []*apiv1.Pod, podFilter func(*apiv1.Pod) bool) []*apiv1.Pod {
+	var filtered []*apiv1.Pod
+	for _, pod := range pods {
+		if podFilter(pod) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
 }
 
 type podEvictionGroup struct {
@@ -17,8 +17,19 @@ limitations under the License.
 package actuation
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"flag"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	goruntime "runtime"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -26,24 +37,43 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ktypes "k8s.io/apimachinery/pkg/types"
 	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
 	"k8s.io/autoscaler/cluster-autoscaler/config"
 	acontext "k8s.io/autoscaler/cluster-autoscaler/context"
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/status"
 	. "k8s.io/autoscaler/cluster-autoscaler/core/test"
 	"k8s.io/autoscaler/cluster-autoscaler/core/utils"
+	"k8s.io/autoscaler/cluster-autoscaler/metrics"
 	"k8s.io/autoscaler/cluster-autoscaler/simulator/clustersnapshot"
 	"k8s.io/autoscaler/cluster-autoscaler/utils/daemonset"
+	caerrors "k8s.io/autoscaler/cluster-autoscaler/utils/errors"
 	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	pod_util "k8s.io/autoscaler/cluster-autoscaler/utils/pod"
 	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/rest"
 	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	kube_record "k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
 	kubelet_config "k8s.io/kubernetes/pkg/kubelet/apis/config"
 	"k8s.io/kubernetes/pkg/kubelet/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
 )
 
 func TestDaemonSetEvictionForEmptyNodes(t *testing.T) {
@@ -700,7 +730,7 @@ func TestPodsToEvict(t *testing.T) {
 			if err != nil {
 				t.Fatalf("NodeInfos().Get() unexpected error: %v", err)
 			}
-			gotDsPods, gotNonDsPods := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForOccupiedNodes)
+			gotDsPods, gotNonDsPods := podsToEvict(nodeInfo, ctx.DaemonSetEvictionForOccupiedNodes, "")
 			if diff := cmp.Diff(tc.wantDsPods, gotDsPods, cmpopts.EquateEmpty()); diff != "" {
 				t.Errorf("podsToEvict dsPods diff (-want +got):\n%s", diff)
 			}
@@ -730,6 +760,17 @@ func mirrorPod(name string) *apiv1.Pod {
 	}
 }
 
+func fakePod(name string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				pod_util.FakePodAnnotationKey: "true",
+			},
+		},
+	}
+}
+
 func dsPod(name string, evictable bool) *apiv1.Pod {
 	pod := &apiv1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -753,3 +794,4590 @@ func (eR *evRegister) RegisterEviction(pod *apiv1.Pod) {
 	defer eR.Unlock()
 	eR.pods = append(eR.pods, pod)
 }
+
+func TestDrainNodeMinGracePeriod(t *testing.T) {
+	gracePeriods := make(chan int64, 10)
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p1.Spec.TerminationGracePeriodSeconds = int64Ptr(2)
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	p2.Spec.TerminationGracePeriodSeconds = int64Ptr(40)
+	SetNodeReadyState(n1, true, time.Time{})
+
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		gracePeriods <- *eviction.DeleteOptions.GracePeriodSeconds
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxGracefulTerminationSec: 60,
+		MaxPodEvictionTime:        5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	legacyFlagDrainConfig := SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec)
+	evictor := Evictor{
+		EvictionRetryTime:                0,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		MinGracePeriodSecondsByPriority:  map[int32]int64{legacyFlagDrainConfig[0].Priority: 30},
+		shutdownGracePeriodByPodPriority: legacyFlagDrainConfig,
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	// p1 is raised to the group's floor, p2 keeps its own, larger grace period.
+	got := []int64{<-gracePeriods, <-gracePeriods}
+	assert.ElementsMatch(t, []int64{30, 40}, got)
+}
+
+func TestPodTerminationGracePeriod(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		podGrace       *int64
+		maxTermination int64
+		minTermination int64
+		want           int64
+	}{
+		"pod spec below floor is raised to the floor": {
+			podGrace:       int64Ptr(2),
+			maxTermination: 60,
+			minTermination: 30,
+			want:           30,
+		},
+		"pod spec above floor is kept": {
+			podGrace:       int64Ptr(45),
+			maxTermination: 60,
+			minTermination: 30,
+			want:           45,
+		},
+		"pod spec above group grace period is clamped down": {
+			podGrace:       int64Ptr(120),
+			maxTermination: 60,
+			minTermination: 30,
+			want:           60,
+		},
+		"floor never exceeds group grace period": {
+			podGrace:       int64Ptr(2),
+			maxTermination: 10,
+			minTermination: 30,
+			want:           10,
+		},
+		"default grace period is used when pod spec is not set": {
+			maxTermination: 60,
+			want:           apiv1.DefaultTerminationGracePeriodSeconds,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			pod := BuildTestPod("p", 100, 0)
+			pod.Spec.TerminationGracePeriodSeconds = tc.podGrace
+			assert.Equal(t, tc.want, podTerminationGracePeriod(pod, tc.maxTermination, tc.minTermination, ClampDown))
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func TestDrainNodeEnsureCordoned(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		unschedulable   bool
+		autoCordon      bool
+		wantErr         bool
+		wantPatched     bool
+		wantCordonedNow bool
+	}{
+		"already cordoned node is a no-op": {
+			unschedulable:   true,
+			autoCordon:      true,
+			wantCordonedNow: true,
+		},
+		"uncordoned node is cordoned with auto-cordon enabled": {
+			autoCordon:      true,
+			wantPatched:     true,
+			wantCordonedNow: true,
+		},
+		"uncordoned node fails the drain with auto-cordon disabled": {
+			wantErr: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			n1.Spec.Unschedulable = tc.unschedulable
+			fakeClient := fake.NewSimpleClientset(n1)
+
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				EnsureCordoned:                   true,
+				AutoCordon:                       tc.autoCordon,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(0),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, nil)
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			patched := false
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "patch" && action.GetResource().Resource == "nodes" {
+					patched = true
+				}
+			}
+			assert.Equal(t, tc.wantPatched, patched)
+
+			node, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), n1.Name, metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantCordonedNow, node.Spec.Unschedulable)
+		})
+	}
+}
+
+func TestDrainNodeCordonSettle(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		unschedulable     bool
+		cordonSettle      time.Duration
+		wantEvictionDelay time.Duration
+	}{
+		"no settle by default": {},
+		"eviction starts after the settle window": {
+			cordonSettle:      3 * time.Second,
+			wantEvictionDelay: 3 * time.Second,
+		},
+		"already cordoned node isn't waited for": {
+			unschedulable: true,
+			cordonSettle:  3 * time.Second,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			n1.Spec.Unschedulable = tc.unschedulable
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			start := clk.Now()
+			var evictedAt time.Time
+			fakeClient := fake.NewSimpleClientset(n1)
+			fakeClient.Fake.PrependReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.PrependReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				evictedAt = clk.Now()
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				EnsureCordoned:                   true,
+				AutoCordon:                       true,
+				CordonSettle:                     tc.cordonSettle,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clk,
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.True(t, evictionResults[p1.Name].WasEvictionSuccessful())
+			assert.Equal(t, tc.wantEvictionDelay, evictedAt.Sub(start))
+		})
+	}
+}
+
+func TestDrainNodeLocalStoragePolicy(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		policy      LocalStoragePolicy
+		wantErr     bool
+		wantEvicted []string
+	}{
+		"evict policy evicts the pod": {
+			policy:      LocalStorageEvict,
+			wantEvicted: []string{"p1", "p2"},
+		},
+		"warn-and-evict policy evicts the pod": {
+			policy:      LocalStorageWarnAndEvict,
+			wantEvicted: []string{"p1", "p2"},
+		},
+		"block policy reports the node as undrainable": {
+			policy:  LocalStorageBlock,
+			wantErr: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			var evictedMutex sync.Mutex
+			var evicted []string
+			fakeClient := &fake.Clientset{}
+
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.Volumes = []apiv1.Volume{{Name: "scratch", VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}}}}
+			p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+			SetNodeReadyState(n1, true, time.Time{})
+
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+				evictedMutex.Lock()
+				defer evictedMutex.Unlock()
+				evicted = append(evicted, eviction.Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxGracefulTerminationSec: 20,
+				MaxPodEvictionTime:        5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				LocalStoragePolicy:               tc.policy,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), p1.Name)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.ElementsMatch(t, tc.wantEvicted, evicted)
+		})
+	}
+}
+
+func TestDrainNodeTotalDrainTimeout(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for i, priority := range []int32{0, 1000, 2000} {
+		p := BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name))
+		p.Spec.Priority = &priority
+		pods = append(pods, p)
+	}
+
+	// Pods never go away, so each group waits for its whole grace period.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		name := action.(core.GetAction).GetName()
+		return true, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		TotalDrainTimeout:   2 * time.Second,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 30},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 30},
+			{Priority: 2000, ShutdownGracePeriodSeconds: 30},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	elapsed := time.Since(start)
+
+	assert.Error(t, err)
+	assert.Less(t, elapsed, 4*time.Second)
+	assert.Len(t, results, len(pods))
+	for _, p := range pods {
+		assert.True(t, results[p.Name].TimedOut, "pod %s should be reported as timed out", p.Name)
+	}
+}
+
+func TestDrainNodeTotalDrainTimeoutBoundsEvictionRetries(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	// The eviction keeps failing, so it would be retried for the whole MaxPodEvictionTime.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: time.Minute,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		EvictionRetryTime:   100 * time.Millisecond,
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		TotalDrainTimeout:   time.Second,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 30},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	elapsed := time.Since(start)
+
+	assert.Error(t, err)
+	assert.Less(t, elapsed, 3*time.Second)
+	assert.False(t, results[p1.Name].WasEvictionSuccessful())
+}
+
+func TestInitiateEvictionDeduplicatesPods(t *testing.T) {
+	var evictionsMutex sync.Mutex
+	evictions := 0
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		evictionsMutex.Lock()
+		defer evictionsMutex.Unlock()
+		evictions++
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{}
+	results, err := evictor.initiateEviction(&ctx, &drainState{}, n1, []*apiv1.Pod{d1}, []*apiv1.Pod{d1}, map[string]status.PodEvictionResult{}, 20, 0, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, evictions)
+	assert.Len(t, results, 1)
+	assert.True(t, results[d1.Name].WasEvictionSuccessful())
+}
+
+func TestDrainNodeMetrics(t *testing.T) {
+	registerMetricsOnce.Do(func() { metrics.RegisterAll(false) })
+
+	for tn, tc := range map[string]struct {
+		podsGone   bool
+		wantResult metrics.NodeDrainResult
+	}{
+		"successful drain": {
+			podsGone:   true,
+			wantResult: metrics.NodeDrainSucceed,
+		},
+		"timed out drain": {
+			podsGone:   false,
+			wantResult: metrics.NodeDrainTimedOut,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClient := &fake.Clientset{}
+
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			SetNodeReadyState(n1, true, time.Time{})
+
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if tc.podsGone {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+				}
+				return true, BuildTestPod(p1.Name, 100, 0, WithNodeName(n1.Name)), nil
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				PodEvictionHeadroom:              100 * time.Millisecond,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(0),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			before := nodeDrainsCount(t, tc.wantResult)
+			_, _ = evictor.DrainNode(&ctx, nodeInfo)
+			assert.Equal(t, before+1, nodeDrainsCount(t, tc.wantResult))
+		})
+	}
+}
+
+func TestDrainNodeDurationMetrics(t *testing.T) {
+	registerMetricsOnce.Do(func() { metrics.RegisterAll(false) })
+
+	fakeClient := &fake.Clientset{}
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	SetNodeReadyState(n1, true, time.Time{})
+	// The pod never disappears, so the drain waits for it until it times out.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, BuildTestPod(p1.Name, 100, 0, WithNodeName(n1.Name)), nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom:              100 * time.Millisecond,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(0),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	evictionCount, _ := histogramSamples(t, "cluster_autoscaler_node_drain_eviction_duration_seconds")
+	waitCount, waitSum := histogramSamples(t, "cluster_autoscaler_node_drain_wait_duration_seconds")
+	_, _ = evictor.DrainNode(&ctx, nodeInfo)
+	newEvictionCount, _ := histogramSamples(t, "cluster_autoscaler_node_drain_eviction_duration_seconds")
+	newWaitCount, newWaitSum := histogramSamples(t, "cluster_autoscaler_node_drain_wait_duration_seconds")
+	assert.Equal(t, evictionCount+1, newEvictionCount)
+	assert.Equal(t, waitCount+1, newWaitCount)
+	assert.GreaterOrEqual(t, newWaitSum-waitSum, 0.1)
+}
+
+func histogramSamples(t *testing.T, name string) (count uint64, sum float64) {
+	t.Helper()
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+var registerMetricsOnce sync.Once
+
+func nodeDrainsCount(t *testing.T, result metrics.NodeDrainResult) float64 {
+	t.Helper()
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "cluster_autoscaler_node_drains_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() == string(result) {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestDrainNodeEvictionHeadroomAnnotation(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		annotation string
+		minWait    time.Duration
+		maxWait    time.Duration
+	}{
+		"no annotation uses the default headroom": {
+			maxWait: time.Second,
+		},
+		"annotation extends the wait window": {
+			annotation: "2",
+			minWait:    2 * time.Second,
+			maxWait:    3 * time.Second,
+		},
+		"invalid annotation falls back to the default headroom": {
+			annotation: "two",
+			maxWait:    time.Second,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClient := &fake.Clientset{}
+
+			n1 := BuildTestNode("n1", 1000, 1000)
+			if tc.annotation != "" {
+				n1.Annotations = map[string]string{EvictionHeadroomAnnotationKey: tc.annotation}
+			}
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			SetNodeReadyState(n1, true, time.Time{})
+
+			// The pod never goes away, so the drain waits for the whole window.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, BuildTestPod(p1.Name, 100, 0, WithNodeName(n1.Name)), nil
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				PodEvictionHeadroom:              100 * time.Millisecond,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(0),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			start := time.Now()
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			elapsed := time.Since(start)
+			assert.Error(t, err)
+			assert.GreaterOrEqual(t, elapsed, tc.minWait)
+			assert.Less(t, elapsed, tc.maxWait)
+		})
+	}
+}
+
+func TestInitiateEvictionMaxConcurrentEvictionsPerOwner(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight := map[string]int{}
+	maxInFlight := map[string]int{}
+	maxTotalInFlight, totalInFlight := 0, 0
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	var pods []*apiv1.Pod
+	podOwners := map[string]string{}
+	for _, owner := range []string{"rs1", "rs2"} {
+		for i := 0; i < 3; i++ {
+			p := BuildTestPod(fmt.Sprintf("%s-p%d", owner, i), 100, 0, WithNodeName(n1.Name))
+			p.OwnerReferences = GenerateOwnerReferences(owner, "ReplicaSet", "apps/v1", ktypes.UID(owner))
+			pods = append(pods, p)
+			podOwners[p.Name] = owner
+		}
+	}
+
+	// The fake client serializes API calls, so an eviction stays in flight until it's registered.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		owner := podOwners[action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name]
+		mutex.Lock()
+		defer mutex.Unlock()
+		inFlight[owner]++
+		totalInFlight++
+		maxInFlight[owner] = max(maxInFlight[owner], inFlight[owner])
+		maxTotalInFlight = max(maxTotalInFlight, totalInFlight)
+		return true, nil, nil
+	})
+	register := evictionRegisterFunc(func(pod *apiv1.Pod) {
+		time.Sleep(50 * time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		inFlight[podOwners[pod.Name]]--
+		totalInFlight--
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{MaxConcurrentEvictionsPerOwner: 1, evictionRegister: register}
+	results, err := evictor.initiateEviction(&ctx, &drainState{}, n1, pods, nil, map[string]status.PodEvictionResult{}, 20, 0, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, results, len(pods))
+	assert.Equal(t, map[string]int{"rs1": 1, "rs2": 1}, maxInFlight)
+	// Pods of different owners are still evicted concurrently.
+	assert.Equal(t, 2, maxTotalInFlight)
+}
+
+type evictionRegisterFunc func(*apiv1.Pod)
+
+func (f evictionRegisterFunc) RegisterEviction(pod *apiv1.Pod) {
+	f(pod)
+}
+
+func TestDrainNodeReclassifyPod(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	SetNodeReadyState(n1, true, time.Time{})
+
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		if eviction.Name == p1.Name {
+			return true, nil, fmt.Errorf("eviction of %s refused", eviction.Name)
+		}
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxGracefulTerminationSec: 20,
+		MaxPodEvictionTime:        100 * time.Millisecond,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		ReclassifyPod:                    func(pod *apiv1.Pod) bool { return pod.Name != p1.Name },
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.NotContains(t, results, p1.Name)
+	assert.True(t, results[p2.Name].WasEvictionSuccessful())
+
+	// Without the hook, failing to evict p1 fails the drain.
+	evictor.ReclassifyPod = nil
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.Error(t, err)
+}
+
+func TestDrainNodeSkippedResultsAfterFailure(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	highPriority := int32(1000)
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	p2.Spec.Priority = &highPriority
+	d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+	d1.Spec.Priority = &highPriority
+	SetNodeReadyState(n1, true, time.Time{})
+
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("eviction refused")
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime:                0,
+		DaemonSetEvictionForOccupiedNodes: true,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 10},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2, d1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.Error(t, err)
+	assert.Len(t, results, 2)
+	assert.False(t, results[p1.Name].Skipped)
+	assert.Error(t, results[p1.Name].Err)
+	assert.True(t, results[p2.Name].Skipped)
+	assert.Contains(t, results[p2.Name].Err.Error(), "not attempted due to earlier failure")
+	// Best effort pods are never reported, even if their group is skipped.
+	assert.NotContains(t, results, d1.Name)
+}
+
+func TestDrainNodeFiltered(t *testing.T) {
+	var evictedMutex sync.Mutex
+	var evicted []string
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+	d2 := BuildTestPod("d2", 100, 0, WithNodeName(n1.Name), WithDSController())
+	m1 := BuildTestPod("m1", 100, 0, WithNodeName(n1.Name))
+	m1.Annotations = map[string]string{types.ConfigMirrorAnnotationKey: "some-key"}
+	SetNodeReadyState(n1, true, time.Time{})
+
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		evictedMutex.Lock()
+		defer evictedMutex.Unlock()
+		evicted = append(evicted, eviction.Name)
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxGracefulTerminationSec:         20,
+		MaxPodEvictionTime:                5 * time.Second,
+		DaemonSetEvictionForOccupiedNodes: true,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2, d1, d2, m1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	accepted := map[string]bool{p1.Name: true, d1.Name: true, m1.Name: true}
+	_, err = evictor.DrainNodeFiltered(&ctx, nodeInfo, func(pod *apiv1.Pod) bool { return accepted[pod.Name] })
+	assert.NoError(t, err)
+	// The mirror pod is skipped even though the filter accepts it.
+	assert.ElementsMatch(t, []string{p1.Name, d1.Name}, evicted)
+}
+
+func TestDrainNodeSequentialWithinGroup(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for tn, tc := range map[string]struct {
+		gracePeriodSeconds int64
+		wantEvictedAfter   []time.Duration
+		wantErr            bool
+	}{
+		"pods are evicted one at a time with a delay": {
+			gracePeriodSeconds: 60,
+			// Each pod is still there on the first check, so the next eviction happens after one poll and the delay.
+			wantEvictedAfter: []time.Duration{0, 15 * time.Second, 30 * time.Second},
+		},
+		"group timeout bounds the sequential eviction": {
+			gracePeriodSeconds: 12,
+			wantEvictedAfter:   []time.Duration{0},
+			wantErr:            true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClock := clocktesting.NewFakeClock(start)
+			var mutex sync.Mutex
+			var evicted []string
+			var evictedAfter []time.Duration
+			checked := map[string]bool{}
+			fakeClient := &fake.Clientset{}
+
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+			p3 := BuildTestPod("p3", 100, 0, WithNodeName(n1.Name))
+			SetNodeReadyState(n1, true, time.Time{})
+
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				name := action.(core.GetAction).GetName()
+				if !checked[name] {
+					checked[name] = true
+					return true, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), nil
+				}
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), name)
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				evicted = append(evicted, action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				evictedAfter = append(evictedAfter, fakeClock.Since(start))
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				WithinGroupMode:                  WithinGroupSequential,
+				SequentialEvictionDelay:          10 * time.Second,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(int(tc.gracePeriodSeconds)),
+				clock:                            fakeClock,
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2, p3})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+			var podOrder []string
+			for _, podInfo := range nodeInfo.Pods {
+				podOrder = append(podOrder, podInfo.Pod.Name)
+			}
+
+			results, err := evictor.DrainNode(&ctx, nodeInfo)
+			if tc.wantErr {
+				assert.Error(t, err)
+				for _, name := range podOrder[len(tc.wantEvictedAfter):] {
+					assert.True(t, results[name].TimedOut, "pod %s should be reported as timed out", name)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, podOrder[:len(tc.wantEvictedAfter)], evicted)
+			assert.Equal(t, tc.wantEvictedAfter, evictedAfter)
+		})
+	}
+}
+
+func TestDrainNodePdbWavesWithinGroup(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakeClock(start)
+	var mutex sync.Mutex
+	evictedAfter := map[string]time.Duration{}
+	checked := map[string]bool{}
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for i := 0; i < 5; i++ {
+		p := BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name))
+		p.Labels = map[string]string{"app": "web"}
+		pods = append(pods, p)
+	}
+	webPdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 2},
+	}
+
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		name := action.(core.GetAction).GetName()
+		if !checked[name] {
+			checked[name] = true
+			return true, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), nil
+		}
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), name)
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		evictedAfter[action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name] = fakeClock.Since(start)
+		return true, nil, nil
+	})
+
+	pdbLister := kube_util.NewTestPodDisruptionBudgetLister([]*policyv1.PodDisruptionBudget{webPdb})
+	registry := kube_util.NewListerRegistry(nil, nil, nil, pdbLister, nil, nil, nil, nil, nil)
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{MaxPodEvictionTime: 5 * time.Second}, fakeClient, registry, nil, nil, nil)
+	assert.NoError(t, err)
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		WithinGroupMode:                  WithinGroupPdbWaves,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(60),
+		clock:                            fakeClock,
+	}
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	// The PDB allows 2 disruptions, so the 5 pods are evicted in waves of 2, 2 and 1, each wave
+	// starting only after the previous one disappeared.
+	wavesByTime := map[time.Duration]int{}
+	for _, after := range evictedAfter {
+		wavesByTime[after]++
+	}
+	var waveStarts []time.Duration
+	for after := range wavesByTime {
+		waveStarts = append(waveStarts, after)
+	}
+	sort.Slice(waveStarts, func(i, j int) bool { return waveStarts[i] < waveStarts[j] })
+	var waveSizes []int
+	for _, after := range waveStarts {
+		waveSizes = append(waveSizes, wavesByTime[after])
+	}
+	assert.Len(t, evictedAfter, 5)
+	assert.Equal(t, []int{2, 2, 1}, waveSizes)
+}
+
+func TestDrainNodeTimeoutErrorListsRemainingPods(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	SetNodeReadyState(n1, true, time.Time{})
+
+	// p1 never goes away, p2 disappears right after eviction.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		name := action.(core.GetAction).GetName()
+		if name == p1.Name {
+			return true, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), nil
+		}
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), name)
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		PodEvictionHeadroom:              100 * time.Millisecond,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(0),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), p1.Namespace+"/"+p1.Name)
+	assert.NotContains(t, err.Error(), p2.Namespace+"/"+p2.Name)
+}
+
+func TestClassifyPodsToEvictSummary(t *testing.T) {
+	snapshot := clustersnapshot.NewBasicClusterSnapshot()
+	node := BuildTestNode("test-node", 1000, 1000)
+	pods := []*apiv1.Pod{
+		mirrorPod("mirror-pod-1"), mirrorPod("mirror-pod-2"),
+		fakePod("fake-pod-1"),
+		dsPod("ds-pod-1", true), dsPod("ds-pod-2", false), dsPod("ds-pod-3", false),
+		regularPod("regular-pod-1"), regularPod("regular-pod-2"), regularPod("regular-pod-3"), regularPod("regular-pod-4"),
+	}
+	if err := snapshot.AddNodeWithPods(node, pods); err != nil {
+		t.Fatalf("AddNodeWithPods unexpected error: %v", err)
+	}
+	nodeInfo, err := snapshot.NodeInfos().Get(node.Name)
+	if err != nil {
+		t.Fatalf("NodeInfos().Get() unexpected error: %v", err)
+	}
+
+	_, _, summary := classifyPodsToEvict(nodeInfo, false)
+	want := podsToEvictSummary{evictable: 4, daemonSet: 1, skippedDaemonSet: 2, skippedMirrorPods: 2, skippedFakePods: 1}
+	assert.Equal(t, want, summary)
+	assert.Equal(t, "4 evictable, 1 DaemonSet, 2 skipped DaemonSet, 2 skipped mirror pods, 1 skipped fake pods", summary.String())
+}
+
+func TestEvictPodStrictNotFound(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		strict      bool
+		wantSuccess bool
+	}{
+		"NotFound counts as success by default": {
+			strict:      false,
+			wantSuccess: true,
+		},
+		"recreated pod is not a success in strict mode": {
+			strict:      true,
+			wantSuccess: false,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClient := &fake.Clientset{}
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), p1.Name)
+			})
+			// A controller recreated the pod under the same name.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, BuildTestPod(p1.Name, 100, 0, WithNodeName(n1.Name)), nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 50 * time.Millisecond,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				EvictionRetryTime: 10 * time.Millisecond,
+				StrictNotFound:    tc.strict,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, p1, time.Now().Add(ctx.MaxPodEvictionTime), 20, 0, true)
+			assert.Equal(t, tc.wantSuccess, result.WasEvictionSuccessful())
+		})
+	}
+}
+
+func TestEvictionClientSetRateLimiter(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	var pods []*apiv1.Pod
+	for i := 0; i < 4; i++ {
+		pods = append(pods, BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name)))
+	}
+
+	var mu sync.Mutex
+	evictions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/eviction") {
+			mu.Lock()
+			evictions++
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		body, _ := json.Marshal(pods[0])
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	mainConfig := &rest.Config{Host: server.URL, QPS: 1000, Burst: 1000}
+	mainClient, err := kube_client.NewForConfig(mainConfig)
+	assert.NoError(t, err)
+	evictionClient, err := NewEvictionClientSet(mainConfig, 5, 1)
+	assert.NoError(t, err)
+
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, mainClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{EvictionClientSet: evictionClient}
+
+	start := time.Now()
+	for _, pod := range pods {
+		result := evictor.evictPod(&ctx, &drainState{}, pod, time.Now().Add(time.Minute), 20, 0, true)
+		assert.True(t, result.WasEvictionSuccessful())
+	}
+	// With QPS 5 and burst 1, the last three evictions each wait ~200ms for the eviction client's limiter.
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, len(pods), evictions)
+
+	start = time.Now()
+	for _, pod := range pods {
+		_, err := mainClient.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		assert.NoError(t, err)
+	}
+	// The main client's limiter is not affected by the evictions.
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestEstimateDrainDuration(t *testing.T) {
+	fakeClient := &fake.Clientset{}
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	p3 := BuildTestPod("p3", 100, 0, WithNodeName(n1.Name))
+	d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+	for pod, priority := range map[*apiv1.Pod]int32{p1: 0, p2: 500, p3: 1500, d1: 3000} {
+		priority := priority
+		pod.Spec.Priority = &priority
+	}
+
+	options := config.AutoscalingOptions{
+		DaemonSetEvictionForOccupiedNodes: true,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2, p3, d1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	shutdownGracePeriodByPodPriority := []kubelet_config.ShutdownGracePeriodByPodPriority{
+		{Priority: 0, ShutdownGracePeriodSeconds: 10},
+		{Priority: 1000, ShutdownGracePeriodSeconds: 20},
+		{Priority: 2000, ShutdownGracePeriodSeconds: 30},
+	}
+	for tn, tc := range map[string]struct {
+		fullDsEviction    bool
+		totalDrainTimeout time.Duration
+		want              time.Duration
+	}{
+		"best effort DaemonSet pods don't extend the drain": {
+			// Groups 0 (p1, p2) and 1000 (p3): (10s + 5s) + (20s + 5s).
+			want: 40 * time.Second,
+		},
+		"full DaemonSet eviction waits for the highest priority group too": {
+			fullDsEviction: true,
+			// Groups 0 (p1, p2), 1000 (p3) and 2000 (d1): (10s + 5s) + (20s + 5s) + (30s + 5s).
+			want: 75 * time.Second,
+		},
+		"capped by total drain timeout": {
+			totalDrainTimeout: 30 * time.Second,
+			want:              30 * time.Second,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			evictor := Evictor{
+				PodEvictionHeadroom:              5 * time.Second,
+				TotalDrainTimeout:                tc.totalDrainTimeout,
+				shutdownGracePeriodByPodPriority: shutdownGracePeriodByPodPriority,
+				fullDsEviction:                   tc.fullDsEviction,
+			}
+			assert.Equal(t, tc.want, evictor.EstimateDrainDuration(&ctx, nodeInfo))
+		})
+	}
+	assert.Empty(t, fakeClient.Actions())
+}
+
+// sleepRecordingClock is a fake clock recording the durations it was asked to sleep for.
+type sleepRecordingClock struct {
+	*clocktesting.FakeClock
+	sleeps []time.Duration
+}
+
+func (c *sleepRecordingClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.FakeClock.Sleep(d)
+}
+
+func TestWaitPodsToDisappearNodeFlicker(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	unbound := BuildTestPod(p1.Name, 100, 0)
+	moved := BuildTestPod(p1.Name, 100, 0, WithNodeName("n2"))
+	replaced := BuildTestPod(p1.Name, 100, 0, WithNodeName("n2"))
+	replaced.UID = "p1-replacement"
+
+	for tn, tc := range map[string]struct {
+		// polls are the pods returned by consecutive polls, nil meaning NotFound. The last one is repeated.
+		polls     []*apiv1.Pod
+		wantPolls int
+		wantErr   bool
+	}{
+		"pod briefly reported without a node isn't gone": {
+			polls:     []*apiv1.Pod{unbound, p1, nil},
+			wantPolls: 3,
+		},
+		"pod reported without a node isn't gone until the timeout": {
+			polls:   []*apiv1.Pod{unbound},
+			wantErr: true,
+		},
+		"pod briefly reported on another node isn't gone": {
+			polls:     []*apiv1.Pod{moved, p1, moved, nil},
+			wantPolls: 4,
+		},
+		"pod reported on another node by two polls is gone": {
+			polls:     []*apiv1.Pod{moved, moved},
+			wantPolls: 2,
+		},
+		"pod replaced by another pod of the same name is gone": {
+			polls:     []*apiv1.Pod{replaced},
+			wantPolls: 1,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			polls := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				pod := tc.polls[min(polls, len(tc.polls)-1)]
+				polls++
+				if pod == nil {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), p1.Name)
+				}
+				return true, pod, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{clock: clocktesting.NewFakeClock(time.Now())}
+			_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 20*time.Second)
+			if tc.wantErr {
+				assert.True(t, goerrors.Is(err, ErrDrainTimeout))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantPolls, polls)
+		})
+	}
+}
+
+func TestWaitPodsToDisappearPollInterval(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	for tn, tc := range map[string]struct {
+		minPollInterval time.Duration
+		maxPollInterval time.Duration
+		wantSleeps      []time.Duration
+	}{
+		"fixed 5s interval by default": {
+			wantSleeps: []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second, 2 * time.Second},
+		},
+		"interval shrinks towards the deadline": {
+			minPollInterval: time.Second,
+			maxPollInterval: 8 * time.Second,
+			// Half of the remaining time, bounded by [1s, 8s] and by the remaining time: 22s, 14s, 7s, 3.5s, 1.75s, 0.75s left.
+			wantSleeps: []time.Duration{8 * time.Second, 7 * time.Second, 3500 * time.Millisecond, 1750 * time.Millisecond, time.Second, 750 * time.Millisecond},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClient := &fake.Clientset{}
+			// The pod never goes away.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, p1, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{
+				MinPollInterval: tc.minPollInterval,
+				MaxPollInterval: tc.maxPollInterval,
+				clock:           clk,
+			}
+			_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 22*time.Second)
+			assert.Error(t, err)
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+		})
+	}
+}
+
+func TestWaitPodsToDisappearSlowTerminationCallback(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	slow := BuildTestPod("slow", 100, 0, WithNodeName(n1.Name))
+	slow.Spec.TerminationGracePeriodSeconds = ptr.To(int64(3))
+	fast := BuildTestPod("fast", 100, 0, WithNodeName(n1.Name))
+	fast.Spec.TerminationGracePeriodSeconds = ptr.To(int64(60))
+
+	fakeClient := &fake.Clientset{}
+	// Neither pod goes away, but only the slow one outlives its grace period within the timeout.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		if action.(core.GetAction).GetName() == slow.Name {
+			return true, slow, nil
+		}
+		return true, fast, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	overdue := map[string][]time.Duration{}
+	evictor := Evictor{
+		SlowTerminationCallback: func(pod *apiv1.Pod, overBy time.Duration) {
+			overdue[pod.Name] = append(overdue[pod.Name], overBy)
+		},
+		clock: clocktesting.NewFakeClock(time.Now()),
+	}
+	_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{slow, fast}, map[string]status.PodEvictionResult{}, 20*time.Second)
+	assert.Error(t, err)
+	// The slow pod is first seen past its 3s grace period at the 5s check, and reported only then.
+	assert.Equal(t, map[string][]time.Duration{slow.Name: {2 * time.Second}}, overdue)
+}
+
+func TestWaitPodsToDisappearSlowTerminationCallbackReportsEachPod(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	first := BuildTestPod("first", 100, 0, WithNodeName(n1.Name))
+	first.Spec.TerminationGracePeriodSeconds = ptr.To(int64(3))
+	second := BuildTestPod("second", 100, 0, WithNodeName(n1.Name))
+	second.Spec.TerminationGracePeriodSeconds = ptr.To(int64(8))
+
+	fakeClient := &fake.Clientset{}
+	// Both pods linger past their grace periods.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		if action.(core.GetAction).GetName() == first.Name {
+			return true, first, nil
+		}
+		return true, second, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	overdue := map[string][]time.Duration{}
+	evictor := Evictor{
+		SlowTerminationCallback: func(pod *apiv1.Pod, overBy time.Duration) {
+			overdue[pod.Name] = append(overdue[pod.Name], overBy)
+		},
+		clock: clocktesting.NewFakeClock(time.Now()),
+	}
+	_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{first, second}, map[string]status.PodEvictionResult{}, 20*time.Second)
+	assert.Error(t, err)
+	// The second pod is checked on every poll even though the first one is still there, so it's reported at the
+	// 10s check rather than only at the end of the wait.
+	assert.Equal(t, map[string][]time.Duration{first.Name: {2 * time.Second}, second.Name: {2 * time.Second}}, overdue)
+}
+
+func TestNewEvictorEvictionAPIVersion(t *testing.T) {
+	evictionResource := func(version string) *metav1.APIResourceList {
+		return &metav1.APIResourceList{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod"},
+				{Name: "pods/eviction", Kind: "Eviction", Group: "policy", Version: version},
+			},
+		}
+	}
+	for tn, tc := range map[string]struct {
+		resources    []*metav1.APIResourceList
+		wantEviction runtime.Object
+	}{
+		"policy/v1 only": {
+			resources:    []*metav1.APIResourceList{evictionResource("v1")},
+			wantEviction: &policyv1.Eviction{},
+		},
+		"policy/v1beta1 only": {
+			resources:    []*metav1.APIResourceList{evictionResource("v1beta1")},
+			wantEviction: &policyv1beta1.Eviction{},
+		},
+		"discovery failure falls back to policy/v1beta1": {
+			wantEviction: &policyv1beta1.Eviction{},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			fakeClient := &fake.Clientset{}
+			var evictions []runtime.Object
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				evictions = append(evictions, action.(core.CreateAction).GetObject())
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			fakeClient.Resources = tc.resources
+			discoveryClient := &fakediscovery.FakeDiscovery{Fake: &fakeClient.Fake}
+			evictor := NewEvictor(nil, SingleRuleDrainConfig(20), false, discoveryClient)
+			// Discovery happens once, at construction.
+			discoveryCalls := len(fakeClient.Actions())
+			n1 := BuildTestNode("n1", 1000, 1000)
+			for _, name := range []string{"p1", "p2"} {
+				result := evictor.evictPod(&ctx, &drainState{}, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), time.Now(), 20, 0, true)
+				assert.True(t, result.WasEvictionSuccessful())
+			}
+
+			assert.Len(t, evictions, 2)
+			for _, eviction := range evictions {
+				assert.IsType(t, tc.wantEviction, eviction)
+			}
+			assert.Len(t, fakeClient.Actions(), discoveryCalls+2)
+		})
+	}
+}
+
+func TestDrainNodeAnnotateDrainTimes(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	annotations := map[string]string{}
+	fakeClient.Fake.AddReactor("patch", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+		patchAction := action.(core.PatchAction)
+		assert.Equal(t, n1.Name, patchAction.GetName())
+		assert.Equal(t, ktypes.MergePatchType, patchAction.GetPatchType())
+		var patch struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		assert.NoError(t, json.Unmarshal(patchAction.GetPatch(), &patch))
+		for key, value := range patch.Metadata.Annotations {
+			annotations[key] = value
+		}
+		return true, n1, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxGracefulTerminationSec: 20,
+		MaxPodEvictionTime:        5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		AnnotateDrainTimes:               true,
+		clock:                            clocktesting.NewFakeClock(now),
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(ctx.MaxGracefulTerminationSec),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		DrainStartedAtAnnotationKey:  "2024-05-01T12:00:00Z",
+		DrainFinishedAtAnnotationKey: "2024-05-01T12:00:00Z",
+	}, annotations)
+}
+
+func TestDrainNodeCancel(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p1.Spec.Priority = ptr.To(int32(0))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	p2.Spec.Priority = ptr.To(int32(1000))
+
+	cancel := make(chan struct{})
+	var cancelOnce sync.Once
+	var evicted []string
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		evicted = append(evicted, eviction.Name)
+		// A scale-up needs the node back while the first group is being drained.
+		cancelOnce.Do(func() { close(cancel) })
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 10},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNodeWithCancel(&ctx, nodeInfo, cancel)
+	assert.Equal(t, ErrDrainCancelled, err)
+	assert.Equal(t, []string{p1.Name}, evicted)
+	assert.True(t, results[p1.Name].WasEvictionSuccessful())
+	assert.True(t, results[p2.Name].Skipped)
+
+	// Cancelling one drain doesn't affect later drains done by the same Evictor.
+	evicted = nil
+	results, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{p1.Name, p2.Name}, evicted)
+	assert.True(t, results[p2.Name].WasEvictionSuccessful())
+}
+
+func TestDrainNodeCancelInterruptsWait(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	// The pod never goes away, so the drain polls for it until cancelled.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, p1, nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		MaxPollInterval:     time.Hour,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 600},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	cancel := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(cancel) })
+	start := time.Now()
+	_, err = evictor.DrainNodeWithCancel(&ctx, nodeInfo, cancel)
+	assert.Equal(t, ErrDrainCancelled, err)
+	// The long poll interval is cut short by the cancellation.
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestEvictorShutdown(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	var evictions sync.WaitGroup
+	evictions.Add(3)
+	fakeClient := &fake.Clientset{}
+	// The pod never goes away, so the drains poll for it until they're shut down.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, p1, nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		evictions.Done()
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		MaxPollInterval:     time.Hour,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 600},
+		},
+		drains: newDrainTracker(),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	goroutines := goruntime.NumGoroutine()
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := evictor.DrainNode(&ctx, nodeInfo)
+			errs <- err
+		}()
+	}
+	evictions.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, evictor.Shutdown(shutdownCtx))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, ErrDrainCancelled, <-errs)
+	}
+	assert.Eventually(t, func() bool { return goruntime.NumGoroutine() <= goroutines }, 5*time.Second, 10*time.Millisecond)
+
+	// Drains started after Shutdown don't evict anything.
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.Equal(t, ErrDrainCancelled, err)
+}
+
+func TestDrainNodeDrainLimiter(t *testing.T) {
+	var nodes []*apiv1.Node
+	var pods []*apiv1.Pod
+	for i := 0; i < 6; i++ {
+		node := BuildTestNode(fmt.Sprintf("n%d", i), 1000, 1000)
+		SetNodeReadyState(node, true, time.Time{})
+		nodes = append(nodes, node)
+		pods = append(pods, BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(node.Name)))
+	}
+
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+	})
+	// Each drain evicts a single pod, so the evictions in progress show the drains running at once.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mutex.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		DrainLimiter:                     NewDrainLimiter(2),
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, nodes, pods)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(node.Name)
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := evictor.DrainNode(&ctx, nodeInfo)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.LessOrEqual(t, maxRunning, 2)
+	assert.Positive(t, maxRunning)
+}
+
+func TestDrainNodeTwiceSkipAbsentPods(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	pods := map[string]*apiv1.Pod{}
+	var allPods []*apiv1.Pod
+	for _, name := range []string{"p1", "p2", "p3", "p4"} {
+		pod := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		pods[name] = pod
+		allPods = append(allPods, pod)
+	}
+	// p3 and p4 are protected by a PDB during the first drain.
+	blocked := map[string]bool{"p3": true, "p4": true}
+
+	var mu sync.Mutex
+	var evicted []string
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if pod, found := pods[action.(core.GetAction).GetName()]; found {
+			return true, pod, nil
+		}
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		name := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name
+		mu.Lock()
+		defer mu.Unlock()
+		if blocked[name] {
+			return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+		}
+		evicted = append(evicted, name)
+		delete(pods, name)
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 50 * time.Millisecond,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		SkipAbsentPods:                   true,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	// The snapshot isn't updated between the drains, like when a retry uses stale node info.
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, allPods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.Error(t, err)
+	assert.ElementsMatch(t, []string{"p1", "p2"}, evicted)
+
+	mu.Lock()
+	evicted = nil
+	blocked = map[string]bool{}
+	mu.Unlock()
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	// p1 and p2 are already gone and aren't evicted again.
+	assert.ElementsMatch(t, []string{"p3", "p4"}, evicted)
+	for _, pod := range allPods {
+		assert.True(t, results[pod.Name].WasEvictionSuccessful())
+	}
+}
+
+func TestPodEvictionHeadroomFraction(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		gracePeriodSeconds int64
+		annotation         string
+		wantHeadroom       time.Duration
+	}{
+		"long grace period gets a proportional headroom up to the max": {
+			gracePeriodSeconds: 3600,
+			wantHeadroom:       5 * time.Minute,
+		},
+		"medium grace period gets a proportional headroom": {
+			gracePeriodSeconds: 600,
+			wantHeadroom:       time.Minute,
+		},
+		"short grace period gets at least the min headroom": {
+			gracePeriodSeconds: 5,
+			wantHeadroom:       10 * time.Second,
+		},
+		"node annotation takes precedence": {
+			gracePeriodSeconds: 3600,
+			annotation:         "42",
+			wantHeadroom:       42 * time.Second,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			if tc.annotation != "" {
+				n1.Annotations = map[string]string{EvictionHeadroomAnnotationKey: tc.annotation}
+			}
+			evictor := Evictor{
+				PodEvictionHeadroom:         DefaultPodEvictionHeadroom,
+				PodEvictionHeadroomFraction: 0.1,
+				MinPodEvictionHeadroom:      10 * time.Second,
+				MaxPodEvictionHeadroom:      5 * time.Minute,
+			}
+			assert.Equal(t, tc.wantHeadroom, evictor.podEvictionHeadroom(&drainState{}, n1, tc.gracePeriodSeconds))
+		})
+	}
+
+	// Without a fraction, the fixed headroom is used regardless of the grace period.
+	n1 := BuildTestNode("n1", 1000, 1000)
+	evictor := Evictor{PodEvictionHeadroom: DefaultPodEvictionHeadroom}
+	assert.Equal(t, DefaultPodEvictionHeadroom, evictor.podEvictionHeadroom(&drainState{}, n1, 3600))
+	assert.Equal(t, DefaultPodEvictionHeadroom, evictor.podEvictionHeadroom(&drainState{}, n1, 5))
+}
+
+func TestEvictorValidate(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		evictor Evictor
+		wantErr bool
+	}{
+		"valid": {
+			evictor: NewEvictor(nil, []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+				{Priority: 0, ShutdownGracePeriodSeconds: 20},
+			}, true, nil),
+		},
+		"zero retry time": {
+			evictor: Evictor{EvictionRetryTime: 0, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"negative retry time": {
+			evictor: Evictor{EvictionRetryTime: -time.Second, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"negative headroom": {
+			evictor: Evictor{EvictionRetryTime: time.Second, PodEvictionHeadroom: -time.Second, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"negative unready grace period": {
+			evictor: Evictor{EvictionRetryTime: time.Second, UnreadyGracePeriodSeconds: -1, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"negative headroom fraction": {
+			evictor: Evictor{EvictionRetryTime: time.Second, PodEvictionHeadroomFraction: -0.1, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"min headroom above max": {
+			evictor: Evictor{EvictionRetryTime: time.Second, MinPodEvictionHeadroom: time.Minute, MaxPodEvictionHeadroom: time.Second, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"negative max pods evicted per wave": {
+			evictor: Evictor{EvictionRetryTime: time.Second, MaxPodsEvictedPerWave: -1, shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"drain limiter without slots": {
+			evictor: Evictor{EvictionRetryTime: time.Second, DrainLimiter: NewDrainLimiter(0), shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)},
+			wantErr: true,
+		},
+		"duplicate priority thresholds": {
+			evictor: NewEvictor(nil, []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+				{Priority: 1000, ShutdownGracePeriodSeconds: 20},
+			}, true, nil),
+			wantErr: true,
+		},
+		"unsorted priority thresholds": {
+			evictor: Evictor{EvictionRetryTime: time.Second, shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+				{Priority: 0, ShutdownGracePeriodSeconds: 20},
+			}},
+			wantErr: true,
+		},
+		"negative grace period": {
+			evictor: NewEvictor(nil, SingleRuleDrainConfig(-1), false, nil),
+			wantErr: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			err := tc.evictor.Validate()
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDrainNodeGracefulDelete(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p1.Spec.TerminationGracePeriodSeconds = ptr.To(int64(5))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	p2.Spec.TerminationGracePeriodSeconds = ptr.To(int64(60))
+
+	var mu sync.Mutex
+	deleted := map[string]int64{}
+	evictCalled := false
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evictCalled = true
+		return true, nil, nil
+	})
+	fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		deleteAction := action.(core.DeleteAction)
+		mu.Lock()
+		defer mu.Unlock()
+		deleted[deleteAction.GetName()] = *deleteAction.GetDeleteOptions().GracePeriodSeconds
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		EvictionMethod:                   GracefulDelete,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.True(t, results["p1"].WasEvictionSuccessful())
+	assert.True(t, results["p2"].WasEvictionSuccessful())
+	assert.False(t, evictCalled)
+	// The grace period is the pod's own one, capped by the drain priority config.
+	assert.Equal(t, map[string]int64{"p1": 5, "p2": 20}, deleted)
+}
+
+func TestDrainNodeNamespaceEvictionRateLimit(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for i := 0; i < 5; i++ {
+		pod := BuildTestPod(fmt.Sprintf("a-%d", i), 100, 0, WithNodeName(n1.Name))
+		pod.Namespace = "busy"
+		pods = append(pods, pod)
+	}
+	for i := 0; i < 2; i++ {
+		pod := BuildTestPod(fmt.Sprintf("b-%d", i), 100, 0, WithNodeName(n1.Name))
+		pod.Namespace = "quiet"
+		pods = append(pods, pod)
+	}
+
+	var mu sync.Mutex
+	evictionTimes := map[string][]time.Time{}
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evictionTimes[action.GetNamespace()] = append(evictionTimes[action.GetNamespace()], time.Now())
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		NamespaceEvictionQPS:             10,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	busy, quiet := evictionTimes["busy"], evictionTimes["quiet"]
+	assert.Len(t, busy, 5)
+	assert.Len(t, quiet, 2)
+	// Each namespace is paced on its own, so the quiet namespace doesn't wait behind the busy one.
+	for _, times := range [][]time.Time{busy, quiet} {
+		for i := 1; i < len(times); i++ {
+			assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 80*time.Millisecond)
+		}
+	}
+	assert.True(t, quiet[1].Before(busy[2]), "evictions in the quiet namespace should finish before the busy namespace's third eviction")
+}
+
+func TestWaitPodsToDisappearTimeoutError(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	// The pod never goes away.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, p1, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{clock: clocktesting.NewFakeClock(time.Now())}
+	_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 20*time.Second)
+	assert.Error(t, err)
+	assert.True(t, goerrors.Is(err, ErrDrainTimeout))
+	assert.False(t, goerrors.Is(err, ErrDrainCancelled))
+	// The original error type and message are kept.
+	autoscalerErr, ok := err.(caerrors.AutoscalerError)
+	assert.True(t, ok)
+	assert.Equal(t, caerrors.TransientError, autoscalerErr.Type())
+	assert.Contains(t, err.Error(), "pods remaining after timeout: default/p1")
+}
+
+func TestDrainNodeTolerationGate(t *testing.T) {
+	gate := &apiv1.Taint{Key: "example.com/draining", Value: "true", Effect: apiv1.TaintEffectNoSchedule}
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	tolerating := BuildTestPod("tolerating", 100, 0, WithNodeName(n1.Name))
+	tolerating.Spec.Tolerations = []apiv1.Toleration{{Key: gate.Key, Operator: apiv1.TolerationOpEqual, Value: "true", Effect: apiv1.TaintEffectNoSchedule}}
+	toleratingAll := BuildTestPod("tolerating-all", 100, 0, WithNodeName(n1.Name))
+	toleratingAll.Spec.Tolerations = []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}}
+	otherToleration := BuildTestPod("other-toleration", 100, 0, WithNodeName(n1.Name))
+	otherToleration.Spec.Tolerations = []apiv1.Toleration{{Key: "example.com/other", Operator: apiv1.TolerationOpExists}}
+	plain := BuildTestPod("plain", 100, 0, WithNodeName(n1.Name))
+	mirror := BuildTestPod("mirror", 100, 0, WithNodeName(n1.Name))
+	mirror.Annotations = map[string]string{types.ConfigMirrorAnnotationKey: "some-key"}
+	mirror.Spec.Tolerations = []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}}
+
+	var mu sync.Mutex
+	var evicted []string
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evicted = append(evicted, action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		TolerationGate:                   gate,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{tolerating, toleratingAll, otherToleration, plain, mirror})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tolerating", "tolerating-all"}, evicted)
+	var drained []string
+	for name := range results {
+		drained = append(drained, name)
+	}
+	assert.ElementsMatch(t, []string{"tolerating", "tolerating-all"}, drained)
+}
+
+func TestDrainNodeEvictLastAnnotation(t *testing.T) {
+	const evictLastAnnotation = "example.com/evict-last"
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	low := BuildTestPod("low", 100, 0, WithNodeName(n1.Name))
+	low.Spec.Priority = ptr.To(int32(0))
+	high := BuildTestPod("high", 100, 0, WithNodeName(n1.Name))
+	high.Spec.Priority = ptr.To(int32(1000))
+	lowEvictLast := BuildTestPod("low-evict-last", 100, 0, WithNodeName(n1.Name))
+	lowEvictLast.Spec.Priority = ptr.To(int32(0))
+	lowEvictLast.Annotations = map[string]string{evictLastAnnotation: "true"}
+
+	type eviction struct {
+		name  string
+		grace int64
+	}
+	var mu sync.Mutex
+	var evictions []eviction
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		e := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		mu.Lock()
+		defer mu.Unlock()
+		evictions = append(evictions, eviction{name: e.Name, grace: *e.DeleteOptions.GracePeriodSeconds})
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:   10 * time.Millisecond,
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		EvictLastAnnotation: evictLastAnnotation,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 5},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{low, high, lowEvictLast})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	// The annotated pod is evicted after the final group, with that group's grace period, despite its low priority.
+	assert.Len(t, evictions, 3)
+	assert.Equal(t, eviction{name: "low", grace: 5}, evictions[0])
+	assert.ElementsMatch(t, []eviction{{name: "high", grace: 10}, {name: "low-evict-last", grace: 10}}, evictions[1:])
+}
+
+func TestDrainNodeEvictLastSidecar(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	sidecar := BuildTestPod("log-shipper", 100, 0, WithNodeName(n1.Name))
+	sidecar.Annotations = map[string]string{EvictLastAnnotationKey: "true"}
+	pods := []*apiv1.Pod{
+		sidecar,
+		BuildTestPod("app-1", 100, 0, WithNodeName(n1.Name)),
+		BuildTestPod("app-2", 100, 0, WithNodeName(n1.Name)),
+	}
+
+	var mutex sync.Mutex
+	var waves [][]string
+	waiting := false
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		waiting = true
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if waiting || len(waves) == 0 {
+			waves = append(waves, nil)
+			waiting = false
+		}
+		waves[len(waves)-1] = append(waves[len(waves)-1], action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := NewEvictor(nil, SingleRuleDrainConfig(20), false, nil)
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	// The annotated sidecar is only evicted once the app pods of the same priority are gone.
+	if assert.Len(t, waves, 2) {
+		assert.ElementsMatch(t, []string{"app-1", "app-2"}, waves[0])
+		assert.Equal(t, []string{"log-shipper"}, waves[1])
+	}
+}
+
+func TestEvictPodRetries(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		err          error
+		maxRetries   int
+		wantAttempts int
+		wantTimedOut bool
+	}{
+		"Forbidden is not retried": {
+			err:          errors.NewForbidden(apiv1.Resource("pods"), "p1", fmt.Errorf("not allowed")),
+			wantAttempts: 1,
+		},
+		"Invalid is not retried": {
+			err:          errors.NewInvalid(schema.GroupKind{Group: "policy", Kind: "Eviction"}, "p1", nil),
+			wantAttempts: 1,
+		},
+		"transient error is retried up to the cap": {
+			err:          errors.NewTooManyRequests("PDB violated", 0),
+			maxRetries:   2,
+			wantAttempts: 3,
+			wantTimedOut: true,
+		},
+		"transient error is retried until the timeout without a cap": {
+			err:          errors.NewTooManyRequests("PDB violated", 0),
+			wantAttempts: 6,
+			wantTimedOut: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			attempts := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				attempts++
+				return true, nil, tc.err
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := clocktesting.NewFakeClock(time.Now())
+			evictor := Evictor{
+				EvictionRetryTime:  10 * time.Second,
+				MaxEvictionRetries: tc.maxRetries,
+				clock:              clk,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, p1, clk.Now().Add(time.Minute), 20, 0, true)
+			assert.False(t, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.wantTimedOut, result.TimedOut)
+			assert.Equal(t, tc.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestWaitPodsToDisappearVolumeDetach(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		detachAfterLists int
+		wantErr          bool
+	}{
+		"volume detaches after the pod is gone": {
+			detachAfterLists: 2,
+		},
+		"volume never detaches": {
+			detachAfterLists: math.MaxInt,
+			wantErr:          true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.Volumes = []apiv1.Volume{{
+				Name:         "data",
+				VolumeSource: apiv1.VolumeSource{PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: "data-p1"}},
+			}}
+
+			lists := 0
+			fakeClient := &fake.Clientset{}
+			// The pod is already gone, only its volume may still be attached.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("get", "persistentvolumeclaims", func(action core.Action) (bool, runtime.Object, error) {
+				return true, &apiv1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: "data-p1", Namespace: p1.Namespace},
+					Spec:       apiv1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+				}, nil
+			})
+			fakeClient.Fake.AddReactor("list", "volumeattachments", func(action core.Action) (bool, runtime.Object, error) {
+				lists++
+				attachments := &storagev1.VolumeAttachmentList{Items: []storagev1.VolumeAttachment{{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-node"},
+					Spec:       storagev1.VolumeAttachmentSpec{NodeName: "n2", Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To("pv-1")}},
+				}}}
+				if lists <= tc.detachAfterLists {
+					attachments.Items = append(attachments.Items, storagev1.VolumeAttachment{
+						ObjectMeta: metav1.ObjectMeta{Name: "this-node"},
+						Spec:       storagev1.VolumeAttachmentSpec{NodeName: n1.Name, Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To("pv-1")}},
+					})
+				}
+				return true, attachments, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				WaitForVolumeDetach: true,
+				clock:               clocktesting.NewFakeClock(time.Now()),
+			}
+			results, err := evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 20*time.Second)
+			if tc.wantErr {
+				assert.True(t, goerrors.Is(err, ErrDrainTimeout))
+				assert.False(t, results[p1.Name].WasEvictionSuccessful())
+				assert.True(t, results[p1.Name].TimedOut)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.detachAfterLists+1, lists)
+			}
+		})
+	}
+}
+
+func TestWaitPodsToDisappearVolumeDetachUsesLister(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p1.Spec.Volumes = []apiv1.Volume{{
+		Name:         "data",
+		VolumeSource: apiv1.VolumeSource{PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: "data-p1"}},
+	}}
+	attachment := &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: "this-node"},
+		Spec:       storagev1.VolumeAttachmentSpec{NodeName: n1.Name, Source: storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To("pv-1")}},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(attachment))
+
+	gets := 0
+	fakeClient := &fake.Clientset{}
+	// The pod is already gone, and its volume detaches once it was checked twice.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		gets++
+		if gets == 2 {
+			assert.NoError(t, indexer.Delete(attachment))
+		}
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("get", "persistentvolumeclaims", func(action core.Action) (bool, runtime.Object, error) {
+		return true, &apiv1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-p1", Namespace: p1.Namespace},
+			Spec:       apiv1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+		}, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		WaitForVolumeDetach:    true,
+		VolumeAttachmentLister: storagelisters.NewVolumeAttachmentLister(indexer),
+		clock:                  clocktesting.NewFakeClock(time.Now()),
+	}
+	_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 20*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, gets)
+	for _, action := range fakeClient.Actions() {
+		assert.False(t, action.Matches("list", "volumeattachments"), "VolumeAttachments shouldn't be listed from the API server")
+	}
+}
+
+func TestDrainNodeStream(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for _, name := range []string{"low-1", "low-2", "high"} {
+		pod := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		pod.Spec.Priority = ptr.To(int32(0))
+		if name == "high" {
+			pod.Spec.Priority = ptr.To(int32(1000))
+		}
+		pods = append(pods, pod)
+	}
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:   10 * time.Millisecond,
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 5},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 10},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	var received []string
+	drainIDs := make(map[string]bool)
+	results, wait := evictor.DrainNodeStream(&ctx, nodeInfo)
+	for result := range results {
+		assert.True(t, result.WasEvictionSuccessful())
+		received = append(received, result.Pod.Name)
+		drainIDs[result.DrainID] = true
+	}
+	assert.NoError(t, wait())
+	// One result per pod, with the lower priority group streamed first.
+	assert.Len(t, received, 3)
+	assert.ElementsMatch(t, []string{"low-1", "low-2"}, received[:2])
+	assert.Equal(t, "high", received[2])
+	// Results streamed before the drain ends carry its ID too.
+	assert.Len(t, drainIDs, 1)
+	assert.NotContains(t, drainIDs, "")
+}
+
+func TestDrainNodeStreamReturnsDrainError(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(apiv1.Resource("pods"), p1.Name, fmt.Errorf("not allowed"))
+	})
+
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(10),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, wait := evictor.DrainNodeStream(&ctx, nodeInfo)
+	var received []status.PodEvictionResult
+	for result := range results {
+		received = append(received, result)
+	}
+	assert.Len(t, received, 1)
+	assert.False(t, received[0].WasEvictionSuccessful())
+	assert.Error(t, wait())
+}
+
+func TestDrainNodeEvictionMetricsNamespaces(t *testing.T) {
+	registerMetricsOnce.Do(func() { metrics.RegisterAll(false) })
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for i, namespace := range []string{"team-a", "team-a", "team-b", "kube-system"} {
+		pod := BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name))
+		pod.Namespace = namespace
+		pods = append(pods, pod)
+	}
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                10 * time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		EvictionMetricsNamespaces:        map[string]bool{"team-a": true, "team-b": true},
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	before := map[string]float64{}
+	for _, namespace := range []string{"team-a", "team-b", "kube-system", metrics.OtherNamespace} {
+		before[namespace] = namespacedEvictionsCount(t, namespace)
+	}
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	assert.Equal(t, before["team-a"]+2, namespacedEvictionsCount(t, "team-a"))
+	assert.Equal(t, before["team-b"]+1, namespacedEvictionsCount(t, "team-b"))
+	// Namespaces that aren't configured fold into "other".
+	assert.Equal(t, before["kube-system"], namespacedEvictionsCount(t, "kube-system"))
+	assert.Equal(t, before[metrics.OtherNamespace]+1, namespacedEvictionsCount(t, metrics.OtherNamespace))
+}
+
+func namespacedEvictionsCount(t *testing.T, namespace string) float64 {
+	t.Helper()
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "cluster_autoscaler_evicted_pods_by_namespace_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["namespace"] == namespace && labels["eviction_result"] == string(metrics.PodEvictionSucceed) {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestWaitPodsToDisappearForceDrainAfter(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		forceDrainAfter time.Duration
+		priorityClass   string
+		allowedClasses  map[string]bool
+		wantForced      bool
+	}{
+		"stragglers are force deleted after the threshold": {
+			forceDrainAfter: 10 * time.Second,
+			wantForced:      true,
+		},
+		"stragglers are waited for without a threshold": {},
+		"stragglers of allowed priority classes are force deleted": {
+			forceDrainAfter: 10 * time.Second,
+			priorityClass:   "batch-low",
+			allowedClasses:  map[string]bool{"batch-low": true},
+			wantForced:      true,
+		},
+		"stragglers of other priority classes are not force deleted": {
+			forceDrainAfter: 10 * time.Second,
+			priorityClass:   "system-critical",
+			allowedClasses:  map[string]bool{"batch-low": true},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.PriorityClassName = tc.priorityClass
+			clk := clocktesting.NewFakeClock(time.Now())
+			start := clk.Now()
+
+			var deletedAfter time.Duration
+			var deleteOptions metav1.DeleteOptions
+			deleted := false
+			fakeClient := &fake.Clientset{}
+			// The pod ignores the eviction and only goes away once it's force deleted.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if deleted {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+				}
+				return true, p1, nil
+			})
+			fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				deleted = true
+				deletedAfter = clk.Since(start)
+				deleteOptions = action.(core.DeleteAction).GetDeleteOptions()
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				ForceDrainAfter:                   tc.forceDrainAfter,
+				ForceDeleteAllowedPriorityClasses: tc.allowedClasses,
+				clock:                             clk,
+			}
+			_, err = evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, map[string]status.PodEvictionResult{}, 60*time.Second)
+			if !tc.wantForced {
+				assert.False(t, deleted)
+				assert.True(t, goerrors.Is(err, ErrDrainTimeout))
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, deleted)
+			assert.GreaterOrEqual(t, deletedAfter, tc.forceDrainAfter)
+			assert.Less(t, deletedAfter, 60*time.Second)
+			assert.Equal(t, ptr.To(int64(0)), deleteOptions.GracePeriodSeconds)
+			// The escalation is recorded as an event on the pod.
+			recorder := ctx.Recorder.(*kube_record.FakeRecorder)
+			select {
+			case event := <-recorder.Events:
+				assert.Contains(t, event, "ScaleDownForceDelete")
+			default:
+				t.Error("no event recorded for the force deletion")
+			}
+		})
+	}
+}
+
+func TestDrainNodeForceDeleteTriggers(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		evictionErr error
+		triggers    map[ForceDeleteTrigger]bool
+		wantForced  bool
+	}{
+		"PDB violations aren't force deleted by default": {
+			evictionErr: errors.NewTooManyRequests("PDB violated", 0),
+		},
+		"PDB violations aren't force deleted when excluded": {
+			evictionErr: errors.NewTooManyRequests("PDB violated", 0),
+			triggers:    map[ForceDeleteTrigger]bool{ForceDeleteOnTimeout: true, ForceDeleteOnAPIError: true},
+		},
+		"PDB violations are force deleted when selected": {
+			evictionErr: errors.NewTooManyRequests("PDB violated", 0),
+			triggers:    map[ForceDeleteTrigger]bool{ForceDeleteOnPDBViolation: true},
+			wantForced:  true,
+		},
+		"API errors are force deleted when selected": {
+			evictionErr: errors.NewInternalError(fmt.Errorf("etcd unavailable")),
+			triggers:    map[ForceDeleteTrigger]bool{ForceDeleteOnAPIError: true},
+			wantForced:  true,
+		},
+		"API errors aren't force deleted when excluded": {
+			evictionErr: errors.NewInternalError(fmt.Errorf("etcd unavailable")),
+			triggers:    map[ForceDeleteTrigger]bool{ForceDeleteOnPDBViolation: true},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			deleted := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if deleted {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+				}
+				return true, p1, nil
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, tc.evictionErr
+			})
+			fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				deleted = true
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				EvictionRetryTime:                time.Second,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				ForceDrainAfter:                  10 * time.Second,
+				ForceDeleteTriggers:              tc.triggers,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clocktesting.NewFakeClock(time.Now()),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.Equal(t, tc.wantForced, deleted)
+			if tc.wantForced {
+				assert.NoError(t, err)
+				assert.True(t, evictionResults[p1.Name].WasEvictionSuccessful())
+			} else {
+				assert.Error(t, err)
+				assert.False(t, evictionResults[p1.Name].WasEvictionSuccessful())
+			}
+		})
+	}
+}
+
+func TestForceDeletePodsAnnotatesNode(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		patchErr        error
+		wantAnnotations map[string]string
+	}{
+		"node is annotated with the force deleted pods": {
+			wantAnnotations: map[string]string{ForceDeletedPodsAnnotationKey: "batch/p2,default/p1"},
+		},
+		"failing to annotate the node doesn't stop force deletion": {
+			patchErr:        errors.NewServiceUnavailable("unavailable"),
+			wantAnnotations: map[string]string{},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Namespace = "default"
+			p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+			p2.Namespace = "batch"
+			// p3 is gone already, so it's not force deleted.
+			p3 := BuildTestPod("p3", 100, 0, WithNodeName(n1.Name))
+			pods := map[string]*apiv1.Pod{p1.Name: p1, p2.Name: p2}
+
+			var deleted []string
+			annotations := map[string]string{}
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				name := action.(core.GetAction).GetName()
+				if pod, found := pods[name]; found {
+					return true, pod, nil
+				}
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), name)
+			})
+			fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				deleted = append(deleted, action.(core.DeleteAction).GetName())
+				return true, nil, nil
+			})
+			fakeClient.Fake.AddReactor("patch", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+				if tc.patchErr != nil {
+					return true, nil, tc.patchErr
+				}
+				var patch struct {
+					Metadata struct {
+						Annotations map[string]string `json:"annotations"`
+					} `json:"metadata"`
+				}
+				assert.NoError(t, json.Unmarshal(action.(core.PatchAction).GetPatch(), &patch))
+				for key, value := range patch.Metadata.Annotations {
+					annotations[key] = value
+				}
+				return true, n1, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{ForceDrainAfter: 10 * time.Second}
+			evictor.forceDeletePods(&ctx, &drainState{}, n1, []*apiv1.Pod{p1, p2, p3}, "still present")
+			assert.ElementsMatch(t, []string{p1.Name, p2.Name}, deleted)
+			assert.Equal(t, tc.wantAnnotations, annotations)
+		})
+	}
+}
+
+func TestForceDeletePodsPropagationPolicy(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		policy *metav1.DeletionPropagation
+	}{
+		"default propagation policy": {},
+		"foreground propagation":     {policy: ptr.To(metav1.DeletePropagationForeground)},
+		"orphan propagation":         {policy: ptr.To(metav1.DeletePropagationOrphan)},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			var deleteOptions []metav1.DeleteOptions
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, p1, nil
+			})
+			fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				deleteOptions = append(deleteOptions, action.(core.DeleteAction).GetDeleteOptions())
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{ForceDrainAfter: 10 * time.Second, ForceDeletePropagationPolicy: tc.policy}
+			evictor.forceDeletePods(&ctx, &drainState{}, n1, []*apiv1.Pod{p1}, "still present")
+			if assert.Len(t, deleteOptions, 1) {
+				assert.Equal(t, tc.policy, deleteOptions[0].PropagationPolicy)
+				assert.Equal(t, ptr.To(int64(0)), deleteOptions[0].GracePeriodSeconds)
+			}
+		})
+	}
+}
+
+func TestDrainNodeBestEffortWait(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		bestEffortWait time.Duration
+		wantSleeps     []time.Duration
+	}{
+		"no wait by default": {},
+		"configured wait after a best effort only group": {
+			bestEffortWait: 3 * time.Second,
+			wantSleeps:     []time.Duration{3 * time.Second},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+			d2 := BuildTestPod("d2", 100, 0, WithNodeName(n1.Name), WithDSController())
+
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime:                5 * time.Second,
+				DaemonSetEvictionForOccupiedNodes: true,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{
+				EvictionRetryTime:                10 * time.Millisecond,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				BestEffortWait:                   tc.bestEffortWait,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clk,
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{d1, d2})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+		})
+	}
+}
+
+func TestDrainNodePostDrainSettle(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		settle       time.Duration
+		condition    apiv1.NodeConditionType
+		settledAfter int
+		wantSleeps   []time.Duration
+		wantNodeGets int
+	}{
+		"no settle by default": {},
+		"configured settle delay": {
+			settle:     10 * time.Second,
+			wantSleeps: []time.Duration{10 * time.Second},
+		},
+		"settle ends once the node has the condition": {
+			settle:       30 * time.Second,
+			condition:    "Drained",
+			settledAfter: 2,
+			wantSleeps:   []time.Duration{5 * time.Second},
+			wantNodeGets: 2,
+		},
+		"settle ends after the delay without the condition": {
+			settle:       8 * time.Second,
+			condition:    "Drained",
+			settledAfter: 10,
+			wantSleeps:   []time.Duration{5 * time.Second, 3 * time.Second},
+			wantNodeGets: 3,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			nodeGets := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+			fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+				nodeGets++
+				node := n1.DeepCopy()
+				if nodeGets >= tc.settledAfter {
+					node.Status.Conditions = append(node.Status.Conditions, apiv1.NodeCondition{Type: tc.condition, Status: apiv1.ConditionTrue})
+				}
+				return true, node, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{
+				EvictionRetryTime:                10 * time.Millisecond,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				PostDrainSettle:                  tc.settle,
+				PostDrainSettleCondition:         tc.condition,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clk,
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.True(t, evictionResults[p1.Name].WasEvictionSuccessful())
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+			assert.Equal(t, tc.wantNodeGets, nodeGets)
+		})
+	}
+}
+
+func TestInitiateEvictionJobEvictionDelay(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	jobPod := func(name string, conditions ...apiv1.PodCondition) *apiv1.Pod {
+		p := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		p.OwnerReferences = GenerateOwnerReferences("job", "Job", "batch/v1", "job-uid")
+		p.Status.Conditions = conditions
+		return p
+	}
+	rsPod := BuildTestPod("rs-pod", 100, 0, WithNodeName(n1.Name))
+	rsPod.OwnerReferences = GenerateOwnerReferences("rs", "ReplicaSet", "apps/v1", "rs-uid")
+	nearCompletion := apiv1.PodCondition{Type: "NearCompletion", Status: apiv1.ConditionTrue}
+
+	for tn, tc := range map[string]struct {
+		pod         *apiv1.Pod
+		condition   apiv1.PodConditionType
+		completed   bool
+		wantSleeps  []time.Duration
+		wantEvicted bool
+	}{
+		"Job pod is delayed": {
+			pod:         jobPod("job-pod"),
+			wantSleeps:  []time.Duration{time.Minute},
+			wantEvicted: true,
+		},
+		"Job pod completed during the delay isn't evicted": {
+			pod:        jobPod("job-pod"),
+			completed:  true,
+			wantSleeps: []time.Duration{time.Minute},
+		},
+		"non-Job pod isn't delayed": {
+			pod:         rsPod,
+			wantEvicted: true,
+		},
+		"Job pod near completion is delayed": {
+			pod:         jobPod("job-pod", nearCompletion),
+			condition:   nearCompletion.Type,
+			wantSleeps:  []time.Duration{time.Minute},
+			wantEvicted: true,
+		},
+		"Job pod not near completion isn't delayed": {
+			pod:         jobPod("job-pod"),
+			condition:   nearCompletion.Type,
+			wantEvicted: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			evicted := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				pod := tc.pod.DeepCopy()
+				if tc.completed {
+					pod.Status.Phase = apiv1.PodSucceeded
+				}
+				return true, pod, nil
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				evicted = true
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{
+				EvictionRetryTime:          10 * time.Second,
+				JobEvictionDelay:           time.Minute,
+				JobNearCompletionCondition: tc.condition,
+				clock:                      clk,
+			}
+			results, err := evictor.initiateEviction(&ctx, &drainState{}, n1, []*apiv1.Pod{tc.pod}, nil, map[string]status.PodEvictionResult{}, 20, 0, time.Time{})
+			assert.NoError(t, err)
+			assert.True(t, results[tc.pod.Name].WasEvictionSuccessful())
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+			assert.Equal(t, tc.wantEvicted, evicted)
+		})
+	}
+}
+
+func TestInitiateEvictionJobEvictionDelayOutsideOwnerLimit(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	var pods []*apiv1.Pod
+	for _, name := range []string{"job-pod-1", "job-pod-2"} {
+		p := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		p.OwnerReferences = GenerateOwnerReferences("job", "Job", "batch/v1", "job-uid")
+		pods = append(pods, p)
+	}
+
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		name := action.(core.GetAction).GetName()
+		return true, BuildTestPod(name, 100, 0, WithNodeName(n1.Name)), nil
+	})
+	// The first eviction attempt of each pod fails, so it needs a retry after the delay.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		name := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name
+		attempts[name]++
+		if attempts[name] == 1 {
+			return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+		}
+		return true, nil, nil
+	})
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 100 * time.Millisecond,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		EvictionRetryTime:              10 * time.Millisecond,
+		JobEvictionDelay:               300 * time.Millisecond,
+		MaxConcurrentEvictionsPerOwner: 1,
+	}
+	start := time.Now()
+	results, err := evictor.initiateEviction(&ctx, &drainState{}, n1, pods, nil, map[string]status.PodEvictionResult{}, 20, 0, time.Time{})
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	for _, pod := range pods {
+		// The retry time limit starts after the delay, which is longer than MaxPodEvictionTime.
+		assert.True(t, results[pod.Name].WasEvictionSuccessful(), "pod %s should be evicted", pod.Name)
+	}
+	// Both pods are delayed at the same time, instead of one after the other within the owner limit.
+	assert.Less(t, elapsed, 550*time.Millisecond)
+}
+
+func TestEvictPodUnreadyImmediately(t *testing.T) {
+	now := time.Now()
+	n1 := BuildTestNode("n1", 1000, 1000)
+	podWithReady := func(status apiv1.ConditionStatus, since time.Duration) *apiv1.Pod {
+		p := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+		p.Spec.TerminationGracePeriodSeconds = ptr.To(int64(30))
+		p.Status.Conditions = []apiv1.PodCondition{{
+			Type:               apiv1.PodReady,
+			Status:             status,
+			LastTransitionTime: metav1.NewTime(now.Add(-since)),
+		}}
+		return p
+	}
+
+	for tn, tc := range map[string]struct {
+		pod                     *apiv1.Pod
+		evictUnreadyImmediately bool
+		wantGracePeriod         int64
+	}{
+		"pod unready past the threshold gets the short grace period": {
+			pod:                     podWithReady(apiv1.ConditionFalse, 10*time.Minute),
+			evictUnreadyImmediately: true,
+			wantGracePeriod:         2,
+		},
+		"pod unready within the threshold keeps its grace period": {
+			pod:                     podWithReady(apiv1.ConditionFalse, time.Minute),
+			evictUnreadyImmediately: true,
+			wantGracePeriod:         30,
+		},
+		"ready pod keeps its grace period": {
+			pod:                     podWithReady(apiv1.ConditionTrue, 10*time.Minute),
+			evictUnreadyImmediately: true,
+			wantGracePeriod:         30,
+		},
+		"unready pod keeps its grace period without the option": {
+			pod:             podWithReady(apiv1.ConditionFalse, 10*time.Minute),
+			wantGracePeriod: 30,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			var gracePeriod int64
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+				gracePeriod = *eviction.DeleteOptions.GracePeriodSeconds
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := clocktesting.NewFakeClock(now)
+			evictor := Evictor{
+				EvictionRetryTime:         10 * time.Second,
+				EvictUnreadyImmediately:   tc.evictUnreadyImmediately,
+				UnreadyThreshold:          5 * time.Minute,
+				UnreadyGracePeriodSeconds: 2,
+				clock:                     clk,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, tc.pod, clk.Now().Add(time.Minute), 60, 0, true)
+			assert.True(t, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.wantGracePeriod, gracePeriod)
+		})
+	}
+}
+
+func TestEvictPodPendingImmediately(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		phase                   apiv1.PodPhase
+		evictPendingImmediately bool
+		wantGracePeriod         int64
+	}{
+		"pending pod gets no grace period": {
+			phase:                   apiv1.PodPending,
+			evictPendingImmediately: true,
+			wantGracePeriod:         0,
+		},
+		"running pod keeps its grace period": {
+			phase:                   apiv1.PodRunning,
+			evictPendingImmediately: true,
+			wantGracePeriod:         30,
+		},
+		"pending pod keeps its grace period without the option": {
+			phase:           apiv1.PodPending,
+			wantGracePeriod: 30,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			p1 := BuildTestPod("p1", 100, 0)
+			p1.Spec.TerminationGracePeriodSeconds = ptr.To(int64(30))
+			p1.Status.Phase = tc.phase
+
+			var gracePeriod int64
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+				gracePeriod = *eviction.DeleteOptions.GracePeriodSeconds
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				EvictionRetryTime:       10 * time.Second,
+				EvictPendingImmediately: tc.evictPendingImmediately,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, p1, time.Now().Add(time.Minute), 60, 0, true)
+			assert.True(t, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.wantGracePeriod, gracePeriod)
+		})
+	}
+}
+
+func TestEvictPodDaemonSetGracePeriod(t *testing.T) {
+	dsPod := BuildTestPod("ds", 100, 0, WithDSController())
+	dsPod.Spec.TerminationGracePeriodSeconds = ptr.To(int64(30))
+	appPod := BuildTestPod("app", 100, 0)
+	appPod.Spec.TerminationGracePeriodSeconds = ptr.To(int64(30))
+
+	for tn, tc := range map[string]struct {
+		pod             *apiv1.Pod
+		fullEviction    bool
+		override        *int64
+		wantGracePeriod int64
+	}{
+		"best effort DaemonSet pod gets the override": {
+			pod:             dsPod,
+			override:        ptr.To(int64(2)),
+			wantGracePeriod: 2,
+		},
+		"best effort DaemonSet pod keeps its grace period without the override": {
+			pod:             dsPod,
+			wantGracePeriod: 30,
+		},
+		"fully evicted DaemonSet pod keeps its grace period": {
+			pod:             dsPod,
+			fullEviction:    true,
+			override:        ptr.To(int64(2)),
+			wantGracePeriod: 30,
+		},
+		"best effort pod of another controller keeps its grace period": {
+			pod:             appPod,
+			override:        ptr.To(int64(2)),
+			wantGracePeriod: 30,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			var gracePeriod int64
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+				gracePeriod = *eviction.DeleteOptions.GracePeriodSeconds
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				EvictionRetryTime:           10 * time.Second,
+				DaemonSetGracePeriodSeconds: tc.override,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, tc.pod, time.Now().Add(time.Minute), 60, 0, tc.fullEviction)
+			assert.True(t, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.wantGracePeriod, gracePeriod)
+		})
+	}
+}
+
+func TestEvictPodBestEffortResults(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	d1 := BuildTestPod("d1", 100, 0, WithNodeName(n1.Name), WithDSController())
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{}
+
+	// DrainNode evicts DaemonSet pods on a best effort basis and app pods fully.
+	dsResult := evictor.evictPod(&ctx, &drainState{}, d1, time.Now().Add(time.Minute), 20, 0, false)
+	assert.True(t, dsResult.WasEvictionSuccessful())
+	assert.True(t, dsResult.BestEffort)
+	appResult := evictor.evictPod(&ctx, &drainState{}, p1, time.Now().Add(time.Minute), 20, 0, true)
+	assert.True(t, appResult.WasEvictionSuccessful())
+	assert.False(t, appResult.BestEffort)
+}
+
+func TestDrainNodeOrphanedPodsBestEffort(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		orphanedPodsBestEffort bool
+		wantErr                bool
+	}{
+		"orphaned pods are evicted on a best effort basis under the option": {
+			orphanedPodsBestEffort: true,
+		},
+		"orphaned pods fail the drain without the option": {
+			wantErr: true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}}
+			owned := BuildTestPod("owned", 100, 0, WithNodeName(n1.Name))
+			owned.Namespace = "default"
+			owned.OwnerReferences = GenerateOwnerReferences(rs.Name, "ReplicaSet", "apps/v1", rs.UID)
+			// The ReplicaSet of the orphaned pod was deleted, so the pod won't be recreated.
+			orphaned := BuildTestPod("orphaned", 100, 0, WithNodeName(n1.Name))
+			orphaned.Namespace = "default"
+			orphaned.OwnerReferences = GenerateOwnerReferences("gone", "ReplicaSet", "apps/v1", "gone-uid")
+
+			orphanedEvicted := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			// Evicting the orphaned pod keeps failing, e.g. because its PDB doesn't allow it.
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name == orphaned.Name {
+					orphanedEvicted = true
+					return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+				}
+				return true, nil, nil
+			})
+
+			rsLister, err := kube_util.NewTestReplicaSetLister([]*appsv1.ReplicaSet{rs})
+			assert.NoError(t, err)
+			registry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil, rsLister, nil)
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, registry, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				EvictionRetryTime:                time.Second,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				OrphanedPodsBestEffort:           tc.orphanedPodsBestEffort,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clocktesting.NewFakeClock(time.Now()),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{owned, orphaned})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.True(t, orphanedEvicted)
+			assert.True(t, evictionResults[owned.Name].WasEvictionSuccessful())
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.False(t, evictionResults[orphaned.Name].WasEvictionSuccessful())
+			} else {
+				// Best effort evictions aren't reported in the results.
+				assert.NoError(t, err)
+				assert.NotContains(t, evictionResults, orphaned.Name)
+			}
+		})
+	}
+}
+
+func TestDrainNodeDrainID(t *testing.T) {
+	var logs bytes.Buffer
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	assert.NoError(t, flags.Set("v", "1"))
+	klog.LogToStderr(false)
+	klog.SetOutput(&logs)
+	defer func() {
+		klog.LogToStderr(true)
+		assert.NoError(t, flags.Set("v", "0"))
+	}()
+
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	klog.Flush()
+	drainID := evictionResults[p1.Name].DrainID
+	assert.NotEmpty(t, drainID)
+	assert.Equal(t, drainID, evictionResults[p2.Name].DrainID)
+
+	// Each log line and event of the drain carries its ID.
+	prefix := "[drain " + drainID + "] "
+	assert.Contains(t, logs.String(), prefix+"Draining node n1")
+	assert.Contains(t, logs.String(), prefix+"All pods removed from n1")
+	for _, match := range regexp.MustCompile(`\[drain ([^\]]*)\]`).FindAllStringSubmatch(logs.String(), -1) {
+		assert.Equal(t, drainID, match[1])
+	}
+	recorder := ctx.Recorder.(*kube_record.FakeRecorder)
+	assert.Len(t, recorder.Events, 2)
+	for len(recorder.Events) > 0 {
+		assert.Contains(t, <-recorder.Events, prefix+"deleting pod for node scale down")
+	}
+
+	// Another drain gets another ID.
+	evictionResults, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+	assert.NotEqual(t, drainID, evictionResults[p1.Name].DrainID)
+}
+
+func TestRecommendedDeletionWait(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	mirror := BuildTestPod("mirror", 100, 0, WithNodeName(n1.Name))
+	mirror.Annotations = map[string]string{types.ConfigMirrorAnnotationKey: "some-key"}
+
+	for tn, tc := range map[string]struct {
+		pods       []*apiv1.Pod
+		window     time.Duration
+		wantWait   time.Duration
+		wantEvents int
+	}{
+		"static pods get the drain window": {
+			pods:       []*apiv1.Pod{p1, mirror},
+			window:     30 * time.Second,
+			wantWait:   30 * time.Second,
+			wantEvents: 1,
+		},
+		"no wait without static pods": {
+			pods:   []*apiv1.Pod{p1},
+			window: 30 * time.Second,
+		},
+		"no wait without the drain window": {
+			pods: []*apiv1.Pod{p1, mirror},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, &fake.Clientset{}, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{StaticPodDrainWindow: tc.window, clock: clk}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, tc.pods)
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.wantWait, evictor.RecommendedDeletionWait(nodeInfo))
+
+			evictor.waitBeforeDeletion(&ctx, nodeInfo)
+			var wantSleeps []time.Duration
+			if tc.wantWait > 0 {
+				wantSleeps = []time.Duration{tc.wantWait}
+			}
+			assert.Equal(t, wantSleeps, clk.sleeps)
+			recorder := ctx.Recorder.(*kube_record.FakeRecorder)
+			assert.Len(t, recorder.Events, tc.wantEvents)
+			if tc.wantEvents > 0 {
+				assert.Contains(t, <-recorder.Events, "ScaleDownStaticPodsWait")
+			}
+		})
+	}
+}
+
+func TestDrainNodeRequeueFailedEvictions(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		requeue    bool
+		wantSleeps []time.Duration
+	}{
+		"PDB blocked pod is requeued on its first failure": {
+			requeue: true,
+		},
+		"PDB blocked pod is retried in place without requeueing": {
+			wantSleeps: []time.Duration{10 * time.Millisecond},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			peer := BuildTestPod("peer", 100, 0, WithNodeName(n1.Name))
+			blocked := BuildTestPod("blocked", 100, 0, WithNodeName(n1.Name))
+
+			var mu sync.Mutex
+			peerEvicted := false
+			blockedAttempts := 0
+			peerEvictedBeforeRetry := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name == peer.Name {
+					peerEvicted = true
+					return true, nil, nil
+				}
+				blockedAttempts++
+				// The first attempt is blocked by the PDB, which evicting the peer frees up.
+				if blockedAttempts == 1 {
+					return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+				}
+				peerEvictedBeforeRetry = peerEvicted
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{
+				EvictionRetryTime:                10 * time.Millisecond,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				RequeueFailedEvictions:           tc.requeue,
+				clock:                            clk,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{peer, blocked})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			results, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.True(t, results[blocked.Name].WasEvictionSuccessful())
+			assert.True(t, results[peer.Name].WasEvictionSuccessful())
+			assert.Equal(t, 2, blockedAttempts)
+			// A requeued pod is retried once the rest of its group was evicted, without waiting in between.
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+			if tc.requeue {
+				assert.True(t, peerEvictedBeforeRetry)
+			}
+		})
+	}
+}
+
+func TestInitiateEvictionRequeuedEvictionsAreRateLimited(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	var pods []*apiv1.Pod
+	for i := 0; i < 3; i++ {
+		pods = append(pods, BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name)))
+	}
+
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	fakeClient := &fake.Clientset{}
+	// The first eviction attempt of each pod fails, so each of them is requeued.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		name := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name
+		attempts[name]++
+		if attempts[name] == 1 {
+			return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+		}
+		return true, nil, nil
+	})
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	evictor := Evictor{
+		EvictionRetryTime:      10 * time.Millisecond,
+		RequeueFailedEvictions: true,
+		NamespaceEvictionQPS:   10,
+	}
+	start := time.Now()
+	results, err := evictor.initiateEviction(&ctx, &drainState{}, n1, pods, nil, map[string]status.PodEvictionResult{}, 20, 0, time.Time{})
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	for _, pod := range pods {
+		assert.True(t, results[pod.Name].WasEvictionSuccessful(), "pod %s should be evicted", pod.Name)
+		assert.Equal(t, 2, attempts[pod.Name])
+	}
+	// Six evictions in the namespace, 100ms apart, including the requeued ones.
+	assert.GreaterOrEqual(t, elapsed, 450*time.Millisecond)
+}
+
+func TestWaitForPodGone(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+	for tn, tc := range map[string]struct {
+		goneAfterGets int
+		wantTimedOut  bool
+		wantSleeps    []time.Duration
+	}{
+		"returns once the pod is gone": {
+			goneAfterGets: 2,
+			wantSleeps:    []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+		"times out if the pod stays": {
+			goneAfterGets: math.MaxInt,
+			wantTimedOut:  true,
+			wantSleeps:    []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second, 5 * time.Second, 2 * time.Second},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			gets := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				gets++
+				if gets > tc.goneAfterGets {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), p1.Name)
+				}
+				return true, p1, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := &sleepRecordingClock{FakeClock: clocktesting.NewFakeClock(time.Now())}
+			evictor := Evictor{clock: clk}
+			result := evictor.WaitForPodGone(&ctx, p1, 22*time.Second)
+			assert.Equal(t, p1, result.Pod)
+			assert.Equal(t, tc.wantTimedOut, result.TimedOut)
+			assert.Equal(t, !tc.wantTimedOut, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.wantSleeps, clk.sleeps)
+		})
+	}
+}
+
+func TestEvictPodGraceClampPolicy(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		policy   GraceClampPolicy
+		podGrace int64
+		want     int64
+	}{
+		"ClampDown clamps a longer pod grace period to the group's": {
+			policy:   ClampDown,
+			podGrace: 120,
+			want:     60,
+		},
+		"NeverShorten keeps a longer pod grace period": {
+			policy:   NeverShorten,
+			podGrace: 120,
+			want:     120,
+		},
+		"UseGroup uses the group's grace period over a longer pod one": {
+			policy:   UseGroup,
+			podGrace: 120,
+			want:     60,
+		},
+		"ClampDown keeps a shorter pod grace period": {
+			policy:   ClampDown,
+			podGrace: 10,
+			want:     10,
+		},
+		"NeverShorten uses the group's grace period over a shorter pod one": {
+			policy:   NeverShorten,
+			podGrace: 10,
+			want:     60,
+		},
+		"UseGroup uses the group's grace period over a shorter pod one": {
+			policy:   UseGroup,
+			podGrace: 10,
+			want:     60,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.TerminationGracePeriodSeconds = ptr.To(tc.podGrace)
+			var gracePeriod int64
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				eviction := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction)
+				gracePeriod = *eviction.DeleteOptions.GracePeriodSeconds
+				return true, nil, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			clk := clocktesting.NewFakeClock(time.Now())
+			evictor := Evictor{
+				EvictionRetryTime: 10 * time.Second,
+				GraceClampPolicy:  tc.policy,
+				clock:             clk,
+			}
+			result := evictor.evictPod(&ctx, &drainState{}, p1, clk.Now().Add(time.Minute), 60, 0, true)
+			assert.True(t, result.WasEvictionSuccessful())
+			assert.Equal(t, tc.want, gracePeriod)
+		})
+	}
+}
+
+func TestWaitPodsToDisappearFinalizers(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	stuck := BuildTestPod("stuck", 100, 0, WithNodeName(n1.Name))
+	stuck.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	stuck.Finalizers = []string{"example.com/cleanup", "example.com/other"}
+	stuck.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  "c1",
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}},
+	}}
+
+	for tn, tc := range map[string]struct {
+		finalizerPodsDrained bool
+		removeFinalizer      string
+		noContainerStatuses  bool
+		wantErr              bool
+		wantFinalizers       []string
+	}{
+		"pod held by finalizers times out by default": {
+			wantErr: true,
+		},
+		"pod held by finalizers counts as drained": {
+			finalizerPodsDrained: true,
+		},
+		"pod without container statuses isn't known to be stopped": {
+			finalizerPodsDrained: true,
+			noContainerStatuses:  true,
+			wantErr:              true,
+		},
+		"configured finalizer is removed": {
+			removeFinalizer: "example.com/cleanup",
+			wantFinalizers:  []string{"example.com/other"},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			pod := stuck.DeepCopy()
+			if tc.noContainerStatuses {
+				pod.Status.ContainerStatuses = nil
+			}
+			var updatedFinalizers []string
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if updatedFinalizers != nil {
+					return true, nil, errors.NewNotFound(apiv1.Resource("pod"), stuck.Name)
+				}
+				return true, pod, nil
+			})
+			fakeClient.Fake.AddReactor("update", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				pod := action.(core.UpdateAction).GetObject().(*apiv1.Pod)
+				updatedFinalizers = pod.Finalizers
+				return true, pod, nil
+			})
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+
+			evictor := Evictor{
+				FinalizerPodsDrained: tc.finalizerPodsDrained,
+				RemoveFinalizer:      tc.removeFinalizer,
+				clock:                clocktesting.NewFakeClock(time.Now()),
+			}
+			results, err := evictor.waitPodsToDisappear(&ctx, &drainState{}, n1, []*apiv1.Pod{pod}, map[string]status.PodEvictionResult{}, 22*time.Second)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.True(t, results[pod.Name].TimedOut)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantFinalizers, updatedFinalizers)
+		})
+	}
+}
+
+func TestCheckDrainFeasibility(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	podWithLabels := func(name string, labels map[string]string) *apiv1.Pod {
+		p := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		p.Labels = labels
+		return p
+	}
+	blocked := podWithLabels("blocked", map[string]string{"app": "db"})
+	web1 := podWithLabels("web1", map[string]string{"app": "web"})
+	web2 := podWithLabels("web2", map[string]string{"app": "web"})
+	free := podWithLabels("free", map[string]string{"app": "batch"})
+	pdbAllowing := func(app string, disruptionsAllowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: app, Namespace: "default"},
+			Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}},
+			Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: disruptionsAllowed},
+		}
+	}
+
+	fakeClient := &fake.Clientset{}
+	pdbLister := kube_util.NewTestPodDisruptionBudgetLister([]*policyv1.PodDisruptionBudget{pdbAllowing("db", 0), pdbAllowing("web", 1)})
+	registry := kube_util.NewListerRegistry(nil, nil, nil, pdbLister, nil, nil, nil, nil, nil)
+	ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, fakeClient, registry, nil, nil, nil)
+	assert.NoError(t, err)
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{blocked, web1, web2, free})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	evictor := Evictor{shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20)}
+	blockedPods, autoscalerErr := evictor.CheckDrainFeasibility(&ctx, nodeInfo)
+	assert.NoError(t, autoscalerErr)
+	blockedApps := make([]string, 0, len(blockedPods))
+	for _, pod := range blockedPods {
+		blockedApps = append(blockedApps, pod.Labels["app"])
+	}
+	// The db PDB allows no disruptions, and the web PDB allows only one of the two web pods to be evicted.
+	assert.ElementsMatch(t, []string{"db", "web"}, blockedApps)
+	// Nothing is evicted.
+	assert.Empty(t, fakeClient.Actions())
+}
+
+func TestDrainNodeEvictionEvents(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		maxRetries       int
+		requeue          bool
+		failures         int
+		wantStartEvents  int
+		wantFailedEvents int
+	}{
+		"pod retried three times gets one start event": {
+			failures:        3,
+			wantStartEvents: 1,
+		},
+		"requeued pod gets one start event": {
+			maxRetries:      2,
+			requeue:         true,
+			failures:        3,
+			wantStartEvents: 1,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			attempts := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), "whatever")
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				attempts++
+				if attempts <= tc.failures {
+					return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+				}
+				return true, nil, nil
+			})
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				EvictionRetryTime:                10 * time.Millisecond,
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				MaxEvictionRetries:               tc.maxRetries,
+				RequeueFailedEvictions:           tc.requeue,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.failures+1, attempts)
+
+			startEvents, failedEvents := 0, 0
+			recorder := ctx.Recorder.(*kube_record.FakeRecorder)
+			for len(recorder.Events) > 0 {
+				event := <-recorder.Events
+				if strings.Contains(event, "deleting pod for node scale down") {
+					startEvents++
+				}
+				if strings.Contains(event, "ScaleDownFailed") {
+					failedEvents++
+				}
+			}
+			assert.Equal(t, tc.wantStartEvents, startEvents)
+			assert.Equal(t, tc.wantFailedEvents, failedEvents)
+		})
+	}
+}
+
+type auditRecorder struct {
+	sync.Mutex
+	records []EvictionRecord
+}
+
+func (a *auditRecorder) RecordEviction(record EvictionRecord) {
+	a.Lock()
+	defer a.Unlock()
+	a.records = append(a.records, record)
+}
+
+func TestDrainNodeAuditSink(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+	p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+	clk := clocktesting.NewFakeClock(time.Now())
+
+	var mu sync.Mutex
+	evicted, deleted := map[string]bool{}, map[string]bool{}
+	fakeClient := &fake.Clientset{}
+	// p1 goes away once evicted, while p2 ignores the eviction and goes away only once it's force deleted.
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := action.(core.GetAction).GetName()
+		if (name == p1.Name && evicted[name]) || deleted[name] {
+			return true, nil, errors.NewNotFound(apiv1.Resource("pod"), name)
+		}
+		if name == p1.Name {
+			return true, p1, nil
+		}
+		return true, p2, nil
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		evicted[action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name] = true
+		return true, nil, nil
+	})
+	fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted[action.(core.DeleteAction).GetName()] = true
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	sink := &auditRecorder{}
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		ForceDrainAfter:                  10 * time.Second,
+		AuditSink:                        sink,
+		clock:                            clk,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	// Both pods are evicted, and p2 is force deleted afterwards.
+	assert.Len(t, sink.records, 3)
+	var forced []EvictionRecord
+	for _, record := range sink.records {
+		assert.Equal(t, n1.Name, record.NodeName)
+		assert.Equal(t, AuditInitiator, record.Initiator)
+		assert.False(t, record.Time.IsZero())
+		if record.Forced {
+			forced = append(forced, record)
+			continue
+		}
+		assert.Equal(t, "ScaleDown", record.Reason)
+		assert.Equal(t, int64(20), record.GracePeriodSeconds)
+	}
+	if assert.Len(t, forced, 1) {
+		assert.Equal(t, p2.Name, forced[0].Name)
+		assert.Equal(t, p2.Namespace, forced[0].Namespace)
+		assert.Equal(t, p2.UID, forced[0].UID)
+		assert.Equal(t, "ScaleDownForceDelete", forced[0].Reason)
+		assert.Equal(t, int64(0), forced[0].GracePeriodSeconds)
+	}
+}
+
+func TestDrainNodeNamespacePriority(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		namespacePriority []string
+		wantOrder         [][]string
+	}{
+		"pods are evicted in namespace order within the group": {
+			namespacePriority: []string{"app", "injector"},
+			wantOrder:         [][]string{{"app-pod"}, {"injector-pod"}, {"other-pod"}},
+		},
+		"pods are evicted together without a namespace order": {
+			wantOrder: [][]string{{"app-pod", "injector-pod", "other-pod"}},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			var pods []*apiv1.Pod
+			// Pods are in the same priority group, listed in the reverse of the namespace order.
+			for _, namespace := range []string{"other", "injector", "app"} {
+				pod := BuildTestPod(namespace+"-pod", 100, 0, WithNodeName(n1.Name))
+				pod.Namespace = namespace
+				pods = append(pods, pod)
+			}
+
+			var mutex sync.Mutex
+			var waves [][]string
+			waiting := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				// Evictions after pods were waited for start a new wave.
+				waiting = true
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if waiting || len(waves) == 0 {
+					waves = append(waves, nil)
+					waiting = false
+				}
+				waves[len(waves)-1] = append(waves[len(waves)-1], action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				NamespacePriority:                tc.namespacePriority,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			for _, wave := range waves {
+				sort.Strings(wave)
+			}
+			assert.Equal(t, tc.wantOrder, waves)
+		})
+	}
+}
+
+func TestDrainNodeDeletedMidWait(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		checkNodeDeletion bool
+		podNodeName       string
+		wantNodeChecks    int
+		wantErr           bool
+	}{
+		"node is checked on every poll": {
+			checkNodeDeletion: true,
+			podNodeName:       "n1",
+			wantNodeChecks:    2,
+		},
+		"node isn't checked while the pod stays on it": {
+			podNodeName:    "n1",
+			wantNodeChecks: 0,
+			wantErr:        true,
+		},
+		"node is checked once the pod left it": {
+			podNodeName:    "",
+			wantNodeChecks: 2,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.Priority = ptr.To(int32(0))
+			p2 := BuildTestPod("p2", 100, 0, WithNodeName(n1.Name))
+			p2.Spec.Priority = ptr.To(int32(1000))
+			p1Returned := p1.DeepCopy()
+			p1Returned.Spec.NodeName = tc.podNodeName
+
+			var mutex sync.Mutex
+			var evicted []string
+			nodeChecks := 0
+			fakeClient := &fake.Clientset{}
+			// p1 is still reported, but the node is deleted out-of-band after the first check.
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, p1Returned, nil
+			})
+			fakeClient.Fake.AddReactor("get", "nodes", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				nodeChecks++
+				if nodeChecks > 1 {
+					return true, nil, errors.NewNotFound(apiv1.Resource("node"), n1.Name)
+				}
+				return true, n1, nil
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				evicted = append(evicted, action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+				CheckNodeDeletion:   tc.checkNodeDeletion,
+				clock:               clocktesting.NewFakeClock(time.Now()),
+				shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+					{Priority: 0, ShutdownGracePeriodSeconds: 60},
+					{Priority: 1000, ShutdownGracePeriodSeconds: 60},
+				},
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, p2})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			results, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.Equal(t, tc.wantNodeChecks, nodeChecks)
+			// The remaining group isn't evicted, since either its pods are gone with the node or p1 timed out.
+			assert.Equal(t, []string{p1.Name}, evicted)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.True(t, results[p1.Name].TimedOut)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, results[p1.Name].WasEvictionSuccessful())
+			assert.True(t, results[p2.Name].WasEvictionSuccessful())
+		})
+	}
+}
+
+func TestDrainNodeSystemCriticalPolicy(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		policy      SystemCriticalPolicy
+		wantErr     bool
+		wantWaves   [][]string
+		wantResults []string
+	}{
+		"system-critical pods are evicted by priority by default": {
+			policy:      SystemCriticalByPriority,
+			wantErr:     true,
+			wantWaves:   [][]string{{"critical", "p1"}},
+			wantResults: []string{"critical", "p1"},
+		},
+		"system-critical pods block the drain": {
+			policy:  SystemCriticalBlock,
+			wantErr: true,
+		},
+		"system-critical pods are evicted last": {
+			policy:      SystemCriticalEvictLast,
+			wantErr:     true,
+			wantWaves:   [][]string{{"p1"}, {"critical"}},
+			wantResults: []string{"critical", "p1"},
+		},
+		"system-critical pods are evicted best effort": {
+			policy:      SystemCriticalBestEffort,
+			wantWaves:   [][]string{{"critical", "p1"}},
+			wantResults: []string{"p1"},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.Priority = ptr.To(int32(0))
+			critical := BuildTestPod("critical", 100, 0, WithNodeName(n1.Name))
+			critical.Spec.PriorityClassName = "system-node-critical"
+			critical.Spec.Priority = ptr.To(int32(2000001000))
+
+			var mutex sync.Mutex
+			var waves [][]string
+			waiting := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				waiting = true
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			// Evicting the system-critical pod is refused, e.g. by a PDB nobody expected to cover it.
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if waiting || len(waves) == 0 {
+					waves = append(waves, nil)
+					waiting = false
+				}
+				name := action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name
+				waves[len(waves)-1] = append(waves[len(waves)-1], name)
+				if name == critical.Name {
+					return true, nil, errors.NewForbidden(apiv1.Resource("pod"), name, fmt.Errorf("not allowed"))
+				}
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				SystemCriticalPolicy:             tc.policy,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1, critical})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			results, err := evictor.DrainNode(&ctx, nodeInfo)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, wave := range waves {
+				sort.Strings(wave)
+			}
+			assert.Equal(t, tc.wantWaves, waves)
+			var resultNames []string
+			for name := range results {
+				resultNames = append(resultNames, name)
+			}
+			sort.Strings(resultNames)
+			assert.Equal(t, tc.wantResults, resultNames)
+		})
+	}
+}
+
+func TestClassifyAPIError(t *testing.T) {
+	podResource := apiv1.Resource("pods")
+	for tn, tc := range map[string]struct {
+		err  error
+		want APIErrorClass
+	}{
+		"no error":            {err: nil, want: APIErrorSuccess},
+		"not found":           {err: errors.NewNotFound(podResource, "p1"), want: APIErrorSuccess},
+		"forbidden":           {err: errors.NewForbidden(podResource, "p1", fmt.Errorf("denied")), want: APIErrorPermanent},
+		"invalid":             {err: errors.NewInvalid(apiv1.SchemeGroupVersion.WithKind("Pod").GroupKind(), "p1", nil), want: APIErrorPermanent},
+		"bad request":         {err: errors.NewBadRequest("bad"), want: APIErrorPermanent},
+		"method not allowed":  {err: errors.NewMethodNotSupported(podResource, "create"), want: APIErrorPermanent},
+		"too many requests":   {err: errors.NewTooManyRequests("pdb", 10), want: APIErrorRetryable},
+		"server timeout":      {err: errors.NewServerTimeout(podResource, "create", 1), want: APIErrorRetryable},
+		"service unavailable": {err: errors.NewServiceUnavailable("unavailable"), want: APIErrorRetryable},
+		"internal error":      {err: errors.NewInternalError(fmt.Errorf("boom")), want: APIErrorRetryable},
+		"conflict":            {err: errors.NewConflict(podResource, "p1", fmt.Errorf("conflict")), want: APIErrorRetryable},
+		"unknown error":       {err: fmt.Errorf("connection reset"), want: APIErrorRetryable},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.want, ClassifyAPIError(tc.err))
+		})
+	}
+}
+
+func TestEvictPodAPIErrorClassifier(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		classifier   func(error) APIErrorClass
+		wantAttempts int
+		wantSuccess  bool
+	}{
+		"internal errors are retried by default": {
+			wantAttempts: 3,
+		},
+		"internal errors classified as permanent aren't retried": {
+			classifier:   func(error) APIErrorClass { return APIErrorPermanent },
+			wantAttempts: 1,
+		},
+		"internal errors classified as success complete the eviction": {
+			classifier:   func(error) APIErrorClass { return APIErrorSuccess },
+			wantAttempts: 1,
+			wantSuccess:  true,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			p1 := BuildTestPod("p1", 100, 0)
+			attempts := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				attempts++
+				return true, nil, errors.NewInternalError(fmt.Errorf("etcd unavailable"))
+			})
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: time.Hour,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				EvictionRetryTime:  time.Millisecond,
+				MaxEvictionRetries: 2,
+				APIErrorClassifier: tc.classifier,
+			}
+
+			result := evictor.evictPod(&ctx, &drainState{}, p1, time.Now().Add(time.Hour), 10, 0, true)
+			assert.Equal(t, tc.wantAttempts, attempts)
+			assert.Equal(t, tc.wantSuccess, result.WasEvictionSuccessful())
+		})
+	}
+}
+
+func TestDrainNodeTopologySpreadWaves(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		mode          WithinGroupMode
+		maxSkew       int32
+		wantWaveSizes []int
+	}{
+		"pods of a spread workload are evicted max skew at a time": {
+			mode:          WithinGroupTopologySpreadWaves,
+			maxSkew:       1,
+			wantWaveSizes: []int{2, 1, 1},
+		},
+		"a larger max skew allows larger waves": {
+			mode:          WithinGroupTopologySpreadWaves,
+			maxSkew:       2,
+			wantWaveSizes: []int{3, 1},
+		},
+		"pods are evicted at once without pacing": {
+			mode:          WithinGroupParallel,
+			maxSkew:       1,
+			wantWaveSizes: []int{4},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			pods := []*apiv1.Pod{BuildTestPod("other", 100, 0, WithNodeName(n1.Name))}
+			for i := 0; i < 3; i++ {
+				pod := BuildTestPod(fmt.Sprintf("web-%d", i), 100, 0, WithNodeName(n1.Name))
+				pod.Labels = map[string]string{"app": "web"}
+				pod.Spec.TopologySpreadConstraints = []apiv1.TopologySpreadConstraint{{
+					MaxSkew:           tc.maxSkew,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: apiv1.DoNotSchedule,
+					LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}}
+				pods = append(pods, pod)
+			}
+
+			var mutex sync.Mutex
+			var waves [][]string
+			waiting := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				waiting = true
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if waiting || len(waves) == 0 {
+					waves = append(waves, nil)
+					waiting = false
+				}
+				waves[len(waves)-1] = append(waves[len(waves)-1], action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				WithinGroupMode:                  tc.mode,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			var waveSizes []int
+			for _, wave := range waves {
+				waveSizes = append(waveSizes, len(wave))
+			}
+			assert.Equal(t, tc.wantWaveSizes, waveSizes)
+			// The pod without topology spread constraints isn't held back.
+			if assert.NotEmpty(t, waves) {
+				assert.Contains(t, waves[0], "other")
+			}
+		})
+	}
+}
+
+func TestDrainNodeMaxPodsEvictedPerWave(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		mode          WithinGroupMode
+		maxPerWave    int
+		wantWaveSizes []int
+	}{
+		"pods are evicted in capped waves": {
+			mode:          WithinGroupParallel,
+			maxPerWave:    10,
+			wantWaveSizes: []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10},
+		},
+		"the last wave holds the remaining pods": {
+			mode:          WithinGroupParallel,
+			maxPerWave:    30,
+			wantWaveSizes: []int{30, 30, 30, 10},
+		},
+		"waves of other modes are capped too": {
+			mode:          WithinGroupTopologySpreadWaves,
+			maxPerWave:    40,
+			wantWaveSizes: []int{40, 40, 20},
+		},
+		"pods are evicted at once without a cap": {
+			mode:          WithinGroupParallel,
+			wantWaveSizes: []int{100},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 100000, 1000)
+			SetNodeReadyState(n1, true, time.Time{})
+			var pods []*apiv1.Pod
+			for i := 0; i < 100; i++ {
+				pods = append(pods, BuildTestPod(fmt.Sprintf("p-%d", i), 100, 0, WithNodeName(n1.Name)))
+			}
+
+			var mutex sync.Mutex
+			var waves [][]string
+			waiting := false
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				waiting = true
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if waiting || len(waves) == 0 {
+					waves = append(waves, nil)
+					waiting = false
+				}
+				waves[len(waves)-1] = append(waves[len(waves)-1], action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				WithinGroupMode:                  tc.mode,
+				MaxPodsEvictedPerWave:            tc.maxPerWave,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			evictionResults, err := evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.Len(t, evictionResults, 100)
+			var waveSizes []int
+			for _, wave := range waves {
+				waveSizes = append(waveSizes, len(wave))
+			}
+			assert.Equal(t, tc.wantWaveSizes, waveSizes)
+		})
+	}
+}
+
+func TestDrainNodeNodeGroupPolicies(t *testing.T) {
+	gracefulDelete := GracefulDelete
+	evictionAPI := EvictionAPI
+	for tn, tc := range map[string]struct {
+		nodeGroup       string
+		wantMethod      string
+		wantGracePeriod int64
+	}{
+		"spot nodes are drained by deleting pods": {
+			nodeGroup:       "spot",
+			wantMethod:      "delete",
+			wantGracePeriod: 5,
+		},
+		"stable nodes are drained through the eviction API": {
+			nodeGroup:       "stable",
+			wantMethod:      "evict",
+			wantGracePeriod: 20,
+		},
+		"nodes of other node groups use the evictor configuration": {
+			nodeGroup:       "other",
+			wantMethod:      "evict",
+			wantGracePeriod: 20,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			n1.Labels["pool"] = tc.nodeGroup
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+			p1.Spec.TerminationGracePeriodSeconds = ptr.To(int64(30))
+
+			var methods []string
+			var gracePeriod int64
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				methods = append(methods, "evict")
+				gracePeriod = *action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).DeleteOptions.GracePeriodSeconds
+				return true, nil, nil
+			})
+			fakeClient.Fake.AddReactor("delete", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				methods = append(methods, "delete")
+				gracePeriod = *action.(core.DeleteAction).GetDeleteOptions().GracePeriodSeconds
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+				NodeGroupLabel:      "pool",
+				NodeGroupPolicies: map[string]NodeGroupEvictionPolicy{
+					"spot": {
+						EvictionMethod:                   &gracefulDelete,
+						ShutdownGracePeriodByPodPriority: SingleRuleDrainConfig(5),
+					},
+					"stable": {EvictionMethod: &evictionAPI},
+				},
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tc.wantMethod}, methods)
+			assert.Equal(t, tc.wantGracePeriod, gracePeriod)
+		})
+	}
+}
+
+func TestDrainNodeNodeGroupMaxPodEvictionTime(t *testing.T) {
+	spotEvictionTime := 2 * time.Second
+	for tn, tc := range map[string]struct {
+		nodeGroup    string
+		wantAttempts int
+	}{
+		"spot nodes give up on evictions after the shorter time": {
+			nodeGroup:    "spot",
+			wantAttempts: 2,
+		},
+		"other nodes retry evictions for the context's time": {
+			nodeGroup:    "stable",
+			wantAttempts: 10,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 1000, 1000)
+			n1.Labels["pool"] = tc.nodeGroup
+			SetNodeReadyState(n1, true, time.Time{})
+			p1 := BuildTestPod("p1", 100, 0, WithNodeName(n1.Name))
+
+			attempts := 0
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				attempts++
+				return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 10 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				EvictionRetryTime:   time.Second,
+				PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+				NodeGroupLabel:      "pool",
+				NodeGroupPolicies: map[string]NodeGroupEvictionPolicy{
+					"spot": {MaxPodEvictionTime: &spotEvictionTime},
+				},
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+				clock:                            clocktesting.NewFakeClock(time.Now()),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{p1})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.Error(t, err)
+			assert.Equal(t, tc.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestDrainNodeEvictLargestFirst(t *testing.T) {
+	for tn, tc := range map[string]struct {
+		evictLargestFirst bool
+		wantOrder         []string
+	}{
+		"pods are evicted by descending requests": {
+			evictLargestFirst: true,
+			wantOrder:         []string{"mid", "big", "small"},
+		},
+		// Without the option, pods are evicted in the order they're listed on the node.
+		"pods are evicted in their original order without the option": {},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			n1 := BuildTestNode("n1", 4000, 8<<30)
+			SetNodeReadyState(n1, true, time.Time{})
+			small := BuildTestPod("small", 100, 0, WithNodeName(n1.Name))
+			big := BuildTestPod("big", 2000, 0, WithNodeName(n1.Name))
+			// Half a core and 2GiB of memory outweigh two cores.
+			mid := BuildTestPod("mid", 500, 2<<30, WithNodeName(n1.Name))
+
+			var evicted []string
+			fakeClient := &fake.Clientset{}
+			fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+			})
+			fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				evicted = append(evicted, action.(core.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+				return true, nil, nil
+			})
+
+			options := config.AutoscalingOptions{
+				MaxPodEvictionTime: 5 * time.Second,
+			}
+			ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+			assert.NoError(t, err)
+			evictor := Evictor{
+				PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+				WithinGroupMode:                  WithinGroupSequential,
+				EvictLargestFirst:                tc.evictLargestFirst,
+				shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+			}
+			clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, []*apiv1.Pod{small, big, mid})
+			nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+			assert.NoError(t, err)
+
+			wantOrder := tc.wantOrder
+			if wantOrder == nil {
+				for _, podInfo := range nodeInfo.Pods {
+					wantOrder = append(wantOrder, podInfo.Pod.Name)
+				}
+			}
+
+			_, err = evictor.DrainNode(&ctx, nodeInfo)
+			assert.NoError(t, err)
+			assert.Equal(t, wantOrder, evicted)
+		})
+	}
+}
+
+func TestDrainNodeGroupRetryBudget(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for i := 0; i < 5; i++ {
+		pods = append(pods, BuildTestPod(fmt.Sprintf("p%d", i), 100, 0, WithNodeName(n1.Name)))
+	}
+
+	var mutex sync.Mutex
+	attempts := 0
+	fakeClient := &fake.Clientset{}
+	// A PDB blocks the evictions of all pods of the group.
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts++
+		return true, nil, errors.NewTooManyRequests("PDB violated", 0)
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 200 * time.Millisecond,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		EvictionRetryTime:                time.Millisecond,
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		GroupRetryBudget:                 3,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	results, err := evictor.DrainNode(&ctx, nodeInfo)
+	assert.Error(t, err)
+	// Each pod gets its first attempt, only the retries are shared.
+	assert.Equal(t, 5+3, attempts)
+	for _, pod := range pods {
+		assert.True(t, results[pod.Name].TimedOut)
+	}
+}
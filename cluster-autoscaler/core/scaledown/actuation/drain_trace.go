@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuation
+
+import (
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/status"
+)
+
+// DrainTraceEntry is the plan and outcome of draining a single pod.
+type DrainTraceEntry struct {
+	Namespace string
+	Name      string
+	// Group is the index of the pod's eviction group, in the order the groups are drained.
+	Group int
+	// Priority and GracePeriodSeconds are the priority threshold and shutdown grace period of the group.
+	Priority           int32
+	GracePeriodSeconds int64
+	BestEffort         bool
+	// GroupStarted and GroupFinished are when draining the group started and finished. They're zero if the group
+	// wasn't drained, e.g. after an earlier group failed.
+	GroupStarted  time.Time
+	GroupFinished time.Time
+	Evicted       bool
+	TimedOut      bool
+	Skipped       bool
+	Err           string
+}
+
+// DrainTrace is the plan and outcome of draining a node, for debugging. Entries hold one entry per pod to drain, in
+// eviction order.
+type DrainTrace struct {
+	NodeName string
+	Started  time.Time
+	Finished time.Time
+	Err      string
+	Entries  []DrainTraceEntry
+}
+
+// DrainTracer keeps the trace of the last drain of each node done by the Evictors it's set on. It's safe for
+// concurrent use.
+type DrainTracer struct {
+	mutex  sync.Mutex
+	traces map[string]DrainTrace
+}
+
+// NewDrainTracer returns an empty DrainTracer.
+func NewDrainTracer() *DrainTracer {
+	return &DrainTracer{traces: make(map[string]DrainTrace)}
+}
+
+// Trace returns the trace of the last drain of the node, and false if the node wasn't drained.
+func (t *DrainTracer) Trace(nodeName string) (DrainTrace, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	trace, found := t.traces[nodeName]
+	return trace, found
+}
+
+func (t *DrainTracer) record(trace DrainTrace) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.traces[trace.NodeName] = trace
+}
+
+// drainTrace builds the trace of a single drain. All its methods are no-ops on a nil drainTrace, so that they can be
+// called unconditionally. It's not safe for concurrent use.
+type drainTrace struct {
+	tracer *DrainTracer
+	clock  clock.Clock
+	trace  DrainTrace
+	groups []podEvictionGroup
+	spans  [][2]time.Time
+}
+
+func newDrainTrace(tracer *DrainTracer, clk clock.Clock, node *apiv1.Node) *drainTrace {
+	if tracer == nil {
+		return nil
+	}
+	return &drainTrace{tracer: tracer, clock: clk, trace: DrainTrace{NodeName: node.Name, Started: clk.Now()}}
+}
+
+// plan records the groups the pods are drained in.
+func (d *drainTrace) plan(groups []podEvictionGroup) {
+	if d == nil {
+		return
+	}
+	d.groups = groups
+	d.spans = make([][2]time.Time, len(groups))
+}
+
+func (d *drainTrace) groupStarted(i int) {
+	if d == nil {
+		return
+	}
+	d.spans[i][0] = d.clock.Now()
+}
+
+func (d *drainTrace) groupFinished(i int) {
+	if d == nil {
+		return
+	}
+	d.spans[i][1] = d.clock.Now()
+}
+
+// finish records the trace with the outcome of the drain in the tracer.
+func (d *drainTrace) finish(evictionResults map[string]status.PodEvictionResult, err error) {
+	if d == nil {
+		return
+	}
+	d.trace.Finished = d.clock.Now()
+	if err != nil {
+		d.trace.Err = err.Error()
+	}
+	for i, group := range d.groups {
+		addEntry := func(pod *apiv1.Pod, bestEffort bool) {
+			entry := DrainTraceEntry{
+				Namespace:          pod.Namespace,
+				Name:               pod.Name,
+				Group:              i,
+				Priority:           group.Priority,
+				GracePeriodSeconds: group.ShutdownGracePeriodSeconds,
+				BestEffort:         bestEffort,
+				GroupStarted:       d.spans[i][0],
+				GroupFinished:      d.spans[i][1],
+			}
+			if result, found := evictionResults[pod.Name]; found {
+				entry.Evicted = result.WasEvictionSuccessful()
+				entry.TimedOut = result.TimedOut
+				entry.Skipped = result.Skipped
+				if result.Err != nil {
+					entry.Err = result.Err.Error()
+				}
+			}
+			d.trace.Entries = append(d.trace.Entries, entry)
+		}
+		for _, pod := range group.FullEvictionPods {
+			addEntry(pod, false)
+		}
+		for _, pod := range group.BestEffortEvictionPods {
+			addEntry(pod, true)
+		}
+	}
+	d.tracer.record(d.trace)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/autoscaler/cluster-autoscaler/config"
+	. "k8s.io/autoscaler/cluster-autoscaler/core/test"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator/clustersnapshot"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	kubelet_config "k8s.io/kubernetes/pkg/kubelet/apis/config"
+	"k8s.io/utils/ptr"
+)
+
+func TestDrainTracer(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	var pods []*apiv1.Pod
+	for _, p := range []struct {
+		name     string
+		priority int32
+	}{
+		{name: "high", priority: 1000},
+		{name: "low-1", priority: 0},
+		{name: "low-2", priority: 0},
+	} {
+		pod := BuildTestPod(p.name, 100, 0, WithNodeName(n1.Name))
+		pod.Spec.Priority = ptr.To(p.priority)
+		pods = append(pods, pod)
+	}
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, nil, nil, nil, nil)
+	assert.NoError(t, err)
+	tracer := NewDrainTracer()
+	evictor := Evictor{
+		PodEvictionHeadroom: DefaultPodEvictionHeadroom,
+		Tracer:              tracer,
+		shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+			{Priority: 0, ShutdownGracePeriodSeconds: 10},
+			{Priority: 1000, ShutdownGracePeriodSeconds: 20},
+		},
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	_, found := tracer.Trace(n1.Name)
+	assert.False(t, found)
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	trace, found := tracer.Trace(n1.Name)
+	assert.True(t, found)
+	assert.Equal(t, n1.Name, trace.NodeName)
+	assert.Empty(t, trace.Err)
+	assert.False(t, trace.Finished.Before(trace.Started))
+	// There's one entry per pod, with the low priority pods first.
+	var names []string
+	for _, entry := range trace.Entries {
+		names = append(names, entry.Name)
+		assert.True(t, entry.Evicted)
+		assert.False(t, entry.GroupStarted.IsZero())
+		assert.False(t, entry.GroupFinished.Before(entry.GroupStarted))
+	}
+	if assert.Len(t, trace.Entries, 3) {
+		assert.ElementsMatch(t, []string{"low-1", "low-2"}, names[:2])
+		assert.Equal(t, "high", names[2])
+		assert.Equal(t, int64(10), trace.Entries[0].GracePeriodSeconds)
+		assert.Equal(t, int64(20), trace.Entries[2].GracePeriodSeconds)
+		assert.Less(t, trace.Entries[0].Group, trace.Entries[2].Group)
+		assert.False(t, trace.Entries[2].GroupStarted.Before(trace.Entries[0].GroupFinished))
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuation
+
+import (
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	acontext "k8s.io/autoscaler/cluster-autoscaler/context"
+	"k8s.io/autoscaler/cluster-autoscaler/core/scaledown/status"
+)
+
+// ownerEvictions counts the pods of a single owner evicted by a drain.
+type ownerEvictions struct {
+	owner   *apiv1.ObjectReference
+	evicted int
+	total   int
+}
+
+// recordOwnerEvents emits an event summarizing the evictions of a drain on the top-level owner of each evicted pod,
+// e.g. the Deployment of a ReplicaSet's pods, as the pods themselves are gone. Pods without an owner are skipped.
+func (e Evictor) recordOwnerEvents(ctx *acontext.AutoscalingContext, ds *drainState, node *apiv1.Node, evictionResults map[string]status.PodEvictionResult) {
+	byOwner := make(map[string]*ownerEvictions)
+	for _, result := range evictionResults {
+		if result.Pod == nil || result.Skipped {
+			continue
+		}
+		owner := topLevelOwner(ctx, result.Pod)
+		if owner == nil {
+			continue
+		}
+		key := owner.Kind + "/" + owner.Namespace + "/" + owner.Name
+		if _, found := byOwner[key]; !found {
+			byOwner[key] = &ownerEvictions{owner: owner}
+		}
+		byOwner[key].total++
+		if result.WasEvictionSuccessful() {
+			byOwner[key].evicted++
+		}
+	}
+
+	keys := make([]string, 0, len(byOwner))
+	for key := range byOwner {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		evictions := byOwner[key]
+		if evictions.evicted == evictions.total {
+			ctx.Recorder.Eventf(evictions.owner, apiv1.EventTypeNormal, "ScaleDownEvicted", "%sevicted %d pods from node %s for scale down", ds.logPrefix(), evictions.total, node.Name)
+		} else {
+			ctx.Recorder.Eventf(evictions.owner, apiv1.EventTypeWarning, "ScaleDownEvictionFailed", "%sevicted %d of %d pods from node %s for scale down", ds.logPrefix(), evictions.evicted, evictions.total, node.Name)
+		}
+	}
+}
+
+// topLevelOwner returns a reference to the controller at the top of the pod's owner chain, following ReplicaSets to
+// their Deployment and Jobs to their CronJob. It returns nil if the pod has no controller. If an intermediate owner
+// can't be listed, the chain stops at it.
+func topLevelOwner(ctx *acontext.AutoscalingContext, pod *apiv1.Pod) *apiv1.ObjectReference {
+	ownerRef := metav1.GetControllerOf(pod)
+	if ownerRef == nil {
+		return nil
+	}
+	var parent metav1.Object
+	if ctx.ListerRegistry != nil {
+		var err error
+		switch ownerRef.Kind {
+		case "ReplicaSet":
+			if lister := ctx.ListerRegistry.ReplicaSetLister(); lister != nil {
+				parent, err = lister.ReplicaSets(pod.Namespace).Get(ownerRef.Name)
+			}
+		case "Job":
+			if lister := ctx.ListerRegistry.JobLister(); lister != nil {
+				parent, err = lister.Jobs(pod.Namespace).Get(ownerRef.Name)
+			}
+		}
+		if err != nil {
+			klog.V(4).Infof("Failed to get %s %s/%s owning pod %s: %v", ownerRef.Kind, pod.Namespace, ownerRef.Name, pod.Name, err)
+			parent = nil
+		}
+	}
+	if parent != nil {
+		if parentRef := metav1.GetControllerOf(parent); parentRef != nil {
+			ownerRef = parentRef
+		}
+	}
+	return &apiv1.ObjectReference{
+		APIVersion: ownerRef.APIVersion,
+		Kind:       ownerRef.Kind,
+		Namespace:  pod.Namespace,
+		Name:       ownerRef.Name,
+		UID:        ownerRef.UID,
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/autoscaler/cluster-autoscaler/config"
+	. "k8s.io/autoscaler/cluster-autoscaler/core/test"
+	"k8s.io/autoscaler/cluster-autoscaler/simulator/clustersnapshot"
+	kube_util "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	kube_record "k8s.io/client-go/tools/record"
+)
+
+func TestDrainNodeOwnerEvents(t *testing.T) {
+	n1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(n1, true, time.Time{})
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "web-7d4b9",
+		Namespace:       "default",
+		OwnerReferences: GenerateOwnerReferences("web", "Deployment", "apps/v1", "web-uid"),
+	}}
+	var pods []*apiv1.Pod
+	for _, name := range []string{"web-1", "web-2"} {
+		pod := BuildTestPod(name, 100, 0, WithNodeName(n1.Name))
+		pod.Namespace = "default"
+		pod.OwnerReferences = GenerateOwnerReferences(rs.Name, "ReplicaSet", "apps/v1", "rs-uid")
+		pods = append(pods, pod)
+	}
+	// The pod without an owner doesn't get an owner event.
+	pods = append(pods, BuildTestPod("naked", 100, 0, WithNodeName(n1.Name)))
+
+	fakeClient := &fake.Clientset{}
+	fakeClient.Fake.AddReactor("get", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(apiv1.Resource("pod"), action.(core.GetAction).GetName())
+	})
+	fakeClient.Fake.AddReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	rsLister, err := kube_util.NewTestReplicaSetLister([]*appsv1.ReplicaSet{rs})
+	assert.NoError(t, err)
+	registry := kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil, rsLister, nil)
+	options := config.AutoscalingOptions{
+		MaxPodEvictionTime: 5 * time.Second,
+	}
+	ctx, err := NewScaleTestAutoscalingContext(options, fakeClient, registry, nil, nil, nil)
+	assert.NoError(t, err)
+	evictor := Evictor{
+		PodEvictionHeadroom:              DefaultPodEvictionHeadroom,
+		OwnerEvents:                      true,
+		shutdownGracePeriodByPodPriority: SingleRuleDrainConfig(20),
+	}
+	clustersnapshot.InitializeClusterSnapshotOrDie(t, ctx.ClusterSnapshot, []*apiv1.Node{n1}, pods)
+	nodeInfo, err := ctx.ClusterSnapshot.NodeInfos().Get(n1.Name)
+	assert.NoError(t, err)
+
+	recorder := ctx.Recorder.(*kube_record.FakeRecorder)
+	recorder.IncludeObject = true
+	_, err = evictor.DrainNode(&ctx, nodeInfo)
+	assert.NoError(t, err)
+
+	var ownerEvents []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "ScaleDownEvict") {
+			ownerEvents = append(ownerEvents, event)
+		}
+	}
+	if assert.Len(t, ownerEvents, 1) {
+		assert.Regexp(t, `^Normal ScaleDownEvicted \[drain [0-9a-f-]+\] evicted 2 pods from node n1 for scale down involvedObject\{kind=Deployment,apiVersion=apps/v1\}$`, ownerEvents[0])
+	}
+}
+
+func TestTopLevelOwner(t *testing.T) {
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "web-7d4b9",
+		Namespace:       "default",
+		OwnerReferences: GenerateOwnerReferences("web", "Deployment", "apps/v1", "web-uid"),
+	}}
+	rsLister, err := kube_util.NewTestReplicaSetLister([]*appsv1.ReplicaSet{rs})
+	assert.NoError(t, err)
+
+	for tn, tc := range map[string]struct {
+		owners    []metav1.OwnerReference
+		listers   kube_util.ListerRegistry
+		wantOwner *apiv1.ObjectReference
+	}{
+		"pod without an owner": {},
+		"ReplicaSet is followed to its Deployment": {
+			owners:    GenerateOwnerReferences(rs.Name, "ReplicaSet", "apps/v1", "rs-uid"),
+			listers:   kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil, rsLister, nil),
+			wantOwner: &apiv1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web", UID: "web-uid"},
+		},
+		"unknown ReplicaSet is the top-level owner": {
+			owners:    GenerateOwnerReferences("other", "ReplicaSet", "apps/v1", "other-uid"),
+			listers:   kube_util.NewListerRegistry(nil, nil, nil, nil, nil, nil, nil, rsLister, nil),
+			wantOwner: &apiv1.ObjectReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: "other", UID: "other-uid"},
+		},
+		"ReplicaSet without listers is the top-level owner": {
+			owners:    GenerateOwnerReferences(rs.Name, "ReplicaSet", "apps/v1", "rs-uid"),
+			wantOwner: &apiv1.ObjectReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "default", Name: rs.Name, UID: "rs-uid"},
+		},
+		"StatefulSet is the top-level owner": {
+			owners:    GenerateOwnerReferences("db", "StatefulSet", "apps/v1", "db-uid"),
+			wantOwner: &apiv1.ObjectReference{APIVersion: "apps/v1", Kind: "StatefulSet", Namespace: "default", Name: "db", UID: "db-uid"},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			pod := BuildTestPod("p1", 100, 0)
+			pod.Namespace = "default"
+			pod.OwnerReferences = tc.owners
+			ctx, err := NewScaleTestAutoscalingContext(config.AutoscalingOptions{}, &fake.Clientset{}, tc.listers, nil, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantOwner, topLevelOwner(&ctx, pod))
+		})
+	}
+}
@@ -24,9 +24,16 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
+	resourcehelper "k8s.io/kubernetes/pkg/api/v1/resource"
 	kubelet_config "k8s.io/kubernetes/pkg/kubelet/apis/config"
 )
 
+// defaultPodPriority is the priority of pods with no Spec.Priority set. It matches the priority the priority
+// admission plugin assigns to pods when there is no global default PriorityClass.
+const defaultPodPriority int32 = 0
+
+// groupByPriority splits the pods into one group per shutdownGracePeriodByPodPriority entry, based on their
+// priority. Pods with no priority set are grouped as if they had defaultPodPriority.
 func groupByPriority(shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority, fullEvictionPods, bestEffortEvictionPods []*apiv1.Pod) []podEvictionGroup {
 	groups := make([]podEvictionGroup, 0, len(shutdownGracePeriodByPodPriority))
 	for _, period := range shutdownGracePeriodByPodPriority {
@@ -47,7 +54,7 @@ func groupByPriority(shutdownGracePeriodByPodPriority []kubelet_config.ShutdownG
 }
 
 func groupIndex(pod *apiv1.Pod, groups []podEvictionGroup) int {
-	var priority int32
+	priority := defaultPodPriority
 	if pod.Spec.Priority != nil {
 		priority = *pod.Spec.Priority
 	}
@@ -73,6 +80,70 @@ func groupIndex(pod *apiv1.Pod, groups []podEvictionGroup) int {
 	return index
 }
 
+// splitByNamespace splits each group into consecutive groups with the same priority and grace period, one for each
+// namespace in namespaceOrder with pods in the group, followed by one for the pods in the other namespaces. Groups
+// without pods are dropped. Groups are left as they are if namespaceOrder is empty.
+func splitByNamespace(groups []podEvictionGroup, namespaceOrder []string) []podEvictionGroup {
+	if len(namespaceOrder) == 0 {
+		return groups
+	}
+	rank := make(map[string]int, len(namespaceOrder))
+	for i, namespace := range namespaceOrder {
+		if _, found := rank[namespace]; !found {
+			rank[namespace] = i
+		}
+	}
+	namespaceRank := func(pod *apiv1.Pod) int {
+		if r, found := rank[pod.Namespace]; found {
+			return r
+		}
+		return len(namespaceOrder)
+	}
+
+	result := make([]podEvictionGroup, 0, len(groups))
+	for _, group := range groups {
+		split := make([]podEvictionGroup, len(namespaceOrder)+1)
+		for i := range split {
+			split[i].ShutdownGracePeriodByPodPriority = group.ShutdownGracePeriodByPodPriority
+		}
+		for _, pod := range group.FullEvictionPods {
+			r := namespaceRank(pod)
+			split[r].FullEvictionPods = append(split[r].FullEvictionPods, pod)
+		}
+		for _, pod := range group.BestEffortEvictionPods {
+			r := namespaceRank(pod)
+			split[r].BestEffortEvictionPods = append(split[r].BestEffortEvictionPods, pod)
+		}
+		for _, g := range split {
+			if len(g.FullEvictionPods) > 0 || len(g.BestEffortEvictionPods) > 0 {
+				result = append(result, g)
+			}
+		}
+	}
+	return result
+}
+
+// sortLargestFirst orders the pods of each group by descending podRequestWeight, keeping the order of pods with the
+// same weight.
+func sortLargestFirst(groups []podEvictionGroup) {
+	byWeight := func(pods []*apiv1.Pod) {
+		sort.SliceStable(pods, func(i, j int) bool {
+			return podRequestWeight(pods[i]) > podRequestWeight(pods[j])
+		})
+	}
+	for _, group := range groups {
+		byWeight(group.FullEvictionPods)
+		byWeight(group.BestEffortEvictionPods)
+	}
+}
+
+// podRequestWeight returns the size of the pod's CPU and memory requests, weighing a CPU core as much as a GiB of
+// memory.
+func podRequestWeight(pod *apiv1.Pod) float64 {
+	requests := resourcehelper.PodRequests(pod, resourcehelper.PodResourcesOptions{})
+	return float64(requests.Cpu().MilliValue())/1000 + float64(requests.Memory().Value())/(1<<30)
+}
+
 // ParseShutdownGracePeriodsAndPriorities parse priorityGracePeriodStr and returns an array of ShutdownGracePeriodByPodPriority if succeeded.
 // Otherwise, returns an empty list
 func ParseShutdownGracePeriodsAndPriorities(priorityGracePeriodStr string) []kubelet_config.ShutdownGracePeriodByPodPriority {
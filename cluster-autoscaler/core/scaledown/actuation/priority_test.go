@@ -184,6 +184,52 @@ func TestGroupByPriority(t *testing.T) {
 	assert.Equal(t, wantGroups, groups)
 }
 
+func TestGroupByPriorityNilPriority(t *testing.T) {
+	nilPriority := BuildTestPod("nil-priority", 100, 0)
+	zeroPriority := BuildTestPod("zero-priority", 100, 0)
+	priority0 := int32(0)
+	zeroPriority.Spec.Priority = &priority0
+
+	for tn, tc := range map[string]struct {
+		shutdownGracePeriodByPodPriority []kubelet_config.ShutdownGracePeriodByPodPriority
+		wantGroup                        int
+	}{
+		"zero is a group boundary": {
+			shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: -10, ShutdownGracePeriodSeconds: 3},
+				{Priority: 0, ShutdownGracePeriodSeconds: 2},
+				{Priority: 100, ShutdownGracePeriodSeconds: 1},
+			},
+			wantGroup: 1,
+		},
+		"zero is within a group": {
+			shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: -10, ShutdownGracePeriodSeconds: 2},
+				{Priority: 100, ShutdownGracePeriodSeconds: 1},
+			},
+			wantGroup: 0,
+		},
+		"zero is below all groups": {
+			shutdownGracePeriodByPodPriority: []kubelet_config.ShutdownGracePeriodByPodPriority{
+				{Priority: 10, ShutdownGracePeriodSeconds: 2},
+				{Priority: 100, ShutdownGracePeriodSeconds: 1},
+			},
+			wantGroup: 0,
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			groups := groupByPriority(tc.shutdownGracePeriodByPodPriority, []*apiv1.Pod{nilPriority, zeroPriority}, nil)
+			for i, group := range groups {
+				if i == tc.wantGroup {
+					assert.Equal(t, []*apiv1.Pod{nilPriority, zeroPriority}, group.FullEvictionPods)
+				} else {
+					assert.Empty(t, group.FullEvictionPods)
+				}
+			}
+		})
+	}
+}
+
 func TestParseShutdownGracePeriodsAndPriorities(t *testing.T) {
 	testCases := []struct {
 		name  string
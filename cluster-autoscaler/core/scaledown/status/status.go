@@ -17,6 +17,7 @@ limitations under the License.
 package status
 
 import (
+	"sort"
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -135,9 +136,55 @@ type PodEvictionResult struct {
 	Pod      *apiv1.Pod
 	TimedOut bool
 	Err      error
+	// Skipped is set if the eviction wasn't attempted because of an earlier failure.
+	Skipped bool
+	// BestEffort is set if the pod was evicted on a best effort basis, like DaemonSet pods by default, so that
+	// failing to evict it didn't fail the drain.
+	BestEffort bool
+	// DrainID identifies the drain that evicted the pod. It's also included in the logs and events of the drain,
+	// so that they can be correlated.
+	DrainID string
 }
 
 // WasEvictionSuccessful tells if the pod was successfully evicted.
 func (per PodEvictionResult) WasEvictionSuccessful() bool {
 	return per.Err == nil && !per.TimedOut
 }
+
+// PodEvictionSummary counts the pod eviction results by outcome.
+type PodEvictionSummary struct {
+	Succeeded int
+	// TimedOut counts the pods that were still present when the eviction timed out.
+	TimedOut int
+	// Failed counts the pods whose eviction failed with an error before timing out.
+	Failed int
+	// Skipped counts the pods whose eviction wasn't attempted because of an earlier failure.
+	Skipped int
+	// FailedPods holds the pods that weren't evicted successfully, sorted by name.
+	FailedPods []*apiv1.Pod
+}
+
+// SummarizeResults counts the pod eviction results by outcome and lists the pods that weren't evicted successfully.
+func SummarizeResults(results map[string]PodEvictionResult) PodEvictionSummary {
+	var summary PodEvictionSummary
+	var failedNames []string
+	for name, result := range results {
+		switch {
+		case result.Skipped:
+			summary.Skipped++
+		case result.WasEvictionSuccessful():
+			summary.Succeeded++
+			continue
+		case result.TimedOut:
+			summary.TimedOut++
+		default:
+			summary.Failed++
+		}
+		failedNames = append(failedNames, name)
+	}
+	sort.Strings(failedNames)
+	for _, name := range failedNames {
+		summary.FailedPods = append(summary.FailedPods, results[name].Pod)
+	}
+	return summary
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	. "k8s.io/autoscaler/cluster-autoscaler/utils/test"
+)
+
+func TestSummarizeResults(t *testing.T) {
+	pods := map[string]*apiv1.Pod{}
+	for _, name := range []string{"ok-1", "ok-2", "timeout", "timeout-with-error", "error", "skipped"} {
+		pods[name] = BuildTestPod(name, 100, 0)
+	}
+
+	for tn, tc := range map[string]struct {
+		results map[string]PodEvictionResult
+		want    PodEvictionSummary
+	}{
+		"no results": {
+			results: nil,
+			want:    PodEvictionSummary{},
+		},
+		"mixed results": {
+			results: map[string]PodEvictionResult{
+				"ok-1":               {Pod: pods["ok-1"]},
+				"ok-2":               {Pod: pods["ok-2"]},
+				"timeout":            {Pod: pods["timeout"], TimedOut: true},
+				"timeout-with-error": {Pod: pods["timeout-with-error"], TimedOut: true, Err: fmt.Errorf("too many requests")},
+				"error":              {Pod: pods["error"], Err: fmt.Errorf("forbidden")},
+				"skipped":            {Pod: pods["skipped"], Skipped: true},
+			},
+			want: PodEvictionSummary{
+				Succeeded:  2,
+				TimedOut:   2,
+				Failed:     1,
+				Skipped:    1,
+				FailedPods: []*apiv1.Pod{pods["error"], pods["skipped"], pods["timeout"], pods["timeout-with-error"]},
+			},
+		},
+	} {
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, tc.want, SummarizeResults(tc.results))
+		})
+	}
+}
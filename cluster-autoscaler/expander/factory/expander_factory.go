@@ -34,6 +34,11 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// locationSpreadProvider is implemented by cloud providers supporting the location-spread expander.
+type locationSpreadProvider interface {
+	LocationSpreadFilter() expander.Filter
+}
+
 // Factory can create expander.Strategy based on provided expander names.
 type Factory struct {
 	createFunc map[string]func() expander.Filter
@@ -98,4 +103,9 @@ func (f *Factory) RegisterDefaultExpanders(cloudProvider cloudprovider.CloudProv
 		return priority.NewFilter(lister.ConfigMaps(configNamespace), autoscalingKubeClients.Recorder)
 	})
 	f.RegisterFilter(expander.GRPCExpanderName, func() expander.Filter { return grpcplugin.NewFilter(GRPCExpanderCert, GRPCExpanderURL) })
+	// The location-spread expander is only registered for cloud providers supporting it, so that Build
+	// returns an error when it's requested for any other.
+	if provider, ok := cloudProvider.(locationSpreadProvider); ok {
+		f.RegisterFilter(expander.LocationSpreadExpanderName, provider.LocationSpreadFilter)
+	}
 }
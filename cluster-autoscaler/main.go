@@ -113,6 +113,8 @@ var (
 	kubeAPIContentType      = flag.String("kube-api-content-type", "application/vnd.kubernetes.protobuf", "Content type of requests sent to apiserver.")
 	kubeClientBurst         = flag.Int("kube-client-burst", rest.DefaultBurst, "Burst value for kubernetes client.")
 	kubeClientQPS           = flag.Float64("kube-client-qps", float64(rest.DefaultQPS), "QPS value for kubernetes client.")
+	evictionKubeClientBurst = flag.Int("eviction-kube-client-burst", rest.DefaultBurst, "Burst value for the kubernetes client used for pod evictions.")
+	evictionKubeClientQPS   = flag.Float64("eviction-kube-client-qps", 0, "QPS value for the kubernetes client used for pod evictions. If 0, evictions share the main kubernetes client.")
 	cloudConfig             = flag.String("cloud-config", "", "The path to the cloud provider configuration file.  Empty string for no configuration file.")
 	namespace               = flag.String("namespace", "kube-system", "Namespace in which cluster-autoscaler run.")
 	enforceNodeGroupMinSize = flag.Bool("enforce-node-group-min-size", false, "Should CA scale up the node group to the configured min size if needed.")
@@ -461,6 +463,8 @@ func buildAutoscaler(debuggingSnapshotter debuggingsnapshot.DebuggingSnapshotter
 
 	autoscalingOptions.KubeClientOpts.KubeClientBurst = int(*kubeClientBurst)
 	autoscalingOptions.KubeClientOpts.KubeClientQPS = float32(*kubeClientQPS)
+	autoscalingOptions.KubeClientOpts.EvictionKubeClientBurst = int(*evictionKubeClientBurst)
+	autoscalingOptions.KubeClientOpts.EvictionKubeClientQPS = float32(*evictionKubeClientQPS)
 	kubeClient := kube_util.CreateKubeClient(autoscalingOptions.KubeClientOpts)
 
 	// Informer transform to trim ManagedFields for memory efficiency.
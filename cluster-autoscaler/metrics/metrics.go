@@ -47,6 +47,9 @@ type NodeGroupType string
 // PodEvictionResult describes result of the pod eviction attempt
 type PodEvictionResult string
 
+// NodeDrainResult describes result of the node drain attempt
+type NodeDrainResult string
+
 const (
 	caNamespace           = "cluster_autoscaler"
 	readyLabel            = "ready"
@@ -94,6 +97,14 @@ const (
 	PodEvictionSucceed PodEvictionResult = "succeeded"
 	// PodEvictionFailed means creation of the pod eviction object failed
 	PodEvictionFailed PodEvictionResult = "failed"
+	// OtherNamespace is the namespace label of evictions of pods in namespaces that aren't tracked separately
+	OtherNamespace = "other"
+	// NodeDrainSucceed means all pods were evicted from the node
+	NodeDrainSucceed NodeDrainResult = "success"
+	// NodeDrainTimedOut means some pods were still on the node when the drain timed out
+	NodeDrainTimedOut NodeDrainResult = "timeout"
+	// NodeDrainFailed means the drain failed for any other reason
+	NodeDrainFailed NodeDrainResult = "error"
 )
 
 // Names of Cluster Autoscaler operations
@@ -332,6 +343,40 @@ var (
 		}, []string{"eviction_result"},
 	)
 
+	evictionsByNamespaceCount = k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Namespace: caNamespace,
+			Name:      "evicted_pods_by_namespace_total",
+			Help:      "Number of pods evicted by CA, by namespace. Only configured namespaces are tracked separately.",
+		}, []string{"eviction_result", "namespace"},
+	)
+
+	nodeDrainsCount = k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Namespace: caNamespace,
+			Name:      "node_drains_total",
+			Help:      "Number of node drains attempted by CA, by result.",
+		}, []string{"result"},
+	)
+
+	nodeDrainEvictionDuration = k8smetrics.NewHistogram(
+		&k8smetrics.HistogramOpts{
+			Namespace: caNamespace,
+			Name:      "node_drain_eviction_duration_seconds",
+			Help:      "Time spent by each node drain creating the evictions of its pods.",
+			Buckets:   k8smetrics.ExponentialBuckets(0.1, 2, 16), // 0.1, 0.2, 0.4, ..., 1638.4, 3276.8
+		},
+	)
+
+	nodeDrainWaitDuration = k8smetrics.NewHistogram(
+		&k8smetrics.HistogramOpts{
+			Namespace: caNamespace,
+			Name:      "node_drain_wait_duration_seconds",
+			Help:      "Time spent by each node drain waiting for its evicted pods to disappear.",
+			Buckets:   k8smetrics.ExponentialBuckets(0.1, 2, 16), // 0.1, 0.2, 0.4, ..., 1638.4, 3276.8
+		},
+	)
+
 	unneededNodesCount = k8smetrics.NewGauge(
 		&k8smetrics.GaugeOpts{
 			Namespace: caNamespace,
@@ -441,6 +486,10 @@ func RegisterAll(emitPerNodeGroupMetrics bool) {
 	legacyregistry.MustRegister(scaleDownCount)
 	legacyregistry.MustRegister(gpuScaleDownCount)
 	legacyregistry.MustRegister(evictionsCount)
+	legacyregistry.MustRegister(evictionsByNamespaceCount)
+	legacyregistry.MustRegister(nodeDrainsCount)
+	legacyregistry.MustRegister(nodeDrainEvictionDuration)
+	legacyregistry.MustRegister(nodeDrainWaitDuration)
 	legacyregistry.MustRegister(unneededNodesCount)
 	legacyregistry.MustRegister(unremovableNodesCount)
 	legacyregistry.MustRegister(scaleDownInCooldown)
@@ -623,6 +672,27 @@ func RegisterEvictions(podsCount int, result PodEvictionResult) {
 	evictionsCount.WithLabelValues(string(result)).Add(float64(podsCount))
 }
 
+// RegisterEvictionsByNamespace records number of evicted pods succeed or failed in the namespace. Namespaces
+// not in trackedNamespaces are recorded as OtherNamespace, to keep the number of series bounded.
+func RegisterEvictionsByNamespace(podsCount int, result PodEvictionResult, namespace string, trackedNamespaces map[string]bool) {
+	if !trackedNamespaces[namespace] {
+		namespace = OtherNamespace
+	}
+	evictionsByNamespaceCount.WithLabelValues(string(result), namespace).Add(float64(podsCount))
+}
+
+// RegisterNodeDrain records the result of a single node drain
+func RegisterNodeDrain(result NodeDrainResult) {
+	nodeDrainsCount.WithLabelValues(string(result)).Inc()
+}
+
+// RegisterNodeDrainDurations records the time a single node drain spent creating evictions and waiting for the
+// evicted pods to disappear
+func RegisterNodeDrainDurations(eviction, wait time.Duration) {
+	nodeDrainEvictionDuration.Observe(eviction.Seconds())
+	nodeDrainWaitDuration.Observe(wait.Seconds())
+}
+
 // UpdateUnneededNodesCount records number of currently unneeded nodes
 func UpdateUnneededNodesCount(nodesCount int) {
 	unneededNodesCount.Set(float64(nodesCount))
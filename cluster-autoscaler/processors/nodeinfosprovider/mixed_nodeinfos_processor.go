@@ -42,6 +42,13 @@ type cacheItem struct {
 	added time.Time
 }
 
+// templateCandidateNodeGroup is implemented by node groups whose nodes can differ
+// from new nodes of the group, e.g. after the machine type of the group was
+// changed. Nodes that aren't template candidates aren't used as the group's template.
+type templateCandidateNodeGroup interface {
+	IsTemplateCandidate(node *apiv1.Node) bool
+}
+
 // MixedTemplateNodeInfoProvider build nodeInfos from the cluster's nodes and node groups.
 type MixedTemplateNodeInfoProvider struct {
 	nodeInfoCache   map[string]cacheItem
@@ -92,6 +99,9 @@ func (p *MixedTemplateNodeInfoProvider) Process(ctx *context.AutoscalingContext,
 		if nodeGroup == nil || reflect.ValueOf(nodeGroup).IsNil() {
 			return false, "", nil
 		}
+		if candidateGroup, ok := nodeGroup.(templateCandidateNodeGroup); ok && !candidateGroup.IsTemplateCandidate(node) {
+			return false, "", nil
+		}
 		id := nodeGroup.Id()
 		if _, found := result[id]; !found {
 			// Build nodeInfo.
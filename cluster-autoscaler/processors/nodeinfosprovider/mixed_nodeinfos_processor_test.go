@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
 	testprovider "k8s.io/autoscaler/cluster-autoscaler/cloudprovider/test"
 	"k8s.io/autoscaler/cluster-autoscaler/context"
 	"k8s.io/autoscaler/cluster-autoscaler/simulator/predicatechecker"
@@ -290,6 +291,74 @@ func TestGetNodeInfosCacheExpired(t *testing.T) {
 
 }
 
+type templateCandidateProvider struct {
+	*testprovider.TestCloudProvider
+	candidates map[string]bool
+}
+
+func (p *templateCandidateProvider) NodeGroupForNode(node *apiv1.Node) (cloudprovider.NodeGroup, error) {
+	nodeGroup, err := p.TestCloudProvider.NodeGroupForNode(node)
+	if nodeGroup == nil || err != nil {
+		return nodeGroup, err
+	}
+	return &templateCandidateGroup{NodeGroup: nodeGroup, candidates: p.candidates}, nil
+}
+
+type templateCandidateGroup struct {
+	cloudprovider.NodeGroup
+	candidates map[string]bool
+}
+
+func (g *templateCandidateGroup) IsTemplateCandidate(node *apiv1.Node) bool {
+	return g.candidates[node.Name]
+}
+
+func TestGetNodeInfosSkipsNonTemplateCandidates(t *testing.T) {
+	now := time.Now()
+	oldType1 := BuildTestNode("n1", 1000, 1000)
+	SetNodeReadyState(oldType1, true, now.Add(-2*time.Minute))
+	newType2 := BuildTestNode("n2", 2000, 2000)
+	SetNodeReadyState(newType2, true, now.Add(-2*time.Minute))
+	oldType3 := BuildTestNode("n3", 1000, 1000)
+	SetNodeReadyState(oldType3, true, now.Add(-2*time.Minute))
+
+	tn := BuildTestNode("tn", 5000, 5000)
+	tni := schedulerframework.NewNodeInfo()
+	tni.SetNode(tn)
+
+	testProvider := testprovider.NewTestAutoprovisioningCloudProvider(
+		nil, nil, nil, nil, nil,
+		map[string]*schedulerframework.NodeInfo{"ng2": tni})
+	testProvider.AddNodeGroup("ng1", 1, 10, 2) // Nodegroup with nodes of the old and the new machine type.
+	testProvider.AddNode("ng1", oldType1)
+	testProvider.AddNode("ng1", newType2)
+	testProvider.AddNodeGroup("ng2", 1, 10, 1) // Nodegroup with only a node of the old machine type.
+	testProvider.AddNode("ng2", oldType3)
+	provider := &templateCandidateProvider{TestCloudProvider: testProvider, candidates: map[string]bool{"n2": true}}
+
+	podLister := kube_util.NewTestPodLister([]*apiv1.Pod{})
+	registry := kube_util.NewListerRegistry(nil, nil, podLister, nil, nil, nil, nil, nil, nil)
+	predicateChecker, err := predicatechecker.NewTestPredicateChecker()
+	assert.NoError(t, err)
+
+	ctx := context.AutoscalingContext{
+		CloudProvider:    provider,
+		PredicateChecker: predicateChecker,
+		AutoscalingKubeClients: context.AutoscalingKubeClients{
+			ListerRegistry: registry,
+		},
+	}
+	res, err := NewMixedTemplateNodeInfoProvider(&cacheTtl, false).Process(&ctx, []*apiv1.Node{oldType1, newType2, oldType3}, []*appsv1.DaemonSet{}, taints.TaintConfig{}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(res))
+	info, found := res["ng1"]
+	assert.True(t, found)
+	assertEqualNodeCapacities(t, newType2, info.Node())
+	info, found = res["ng2"]
+	assert.True(t, found)
+	assertEqualNodeCapacities(t, tn, info.Node())
+}
+
 func assertEqualNodeCapacities(t *testing.T, expected, actual *apiv1.Node) {
 	t.Helper()
 	assert.NotEqual(t, actual.Status, nil, "")
@@ -45,6 +45,14 @@ type autoscalerErrorImpl struct {
 	msg       string
 }
 
+// wrappingAutoscalerError is an AutoscalerError wrapping another error. It's
+// only used through a pointer, so that it stays comparable whatever the
+// wrapped error is.
+type wrappingAutoscalerError struct {
+	autoscalerErrorImpl
+	wrapped error
+}
+
 const (
 	// CloudProviderError is an error related to underlying infrastructure
 	CloudProviderError AutoscalerErrorType = "cloudProviderError"
@@ -75,6 +83,19 @@ func NewAutoscalerError(errorType AutoscalerErrorType, msg string, args ...inter
 	}
 }
 
+// NewAutoscalerErrorWrapping returns new autoscaler error with a message constructed from format string,
+// wrapping the given error so that it can be matched with errors.Is and errors.As. The wrapped error
+// isn't included in the message.
+func NewAutoscalerErrorWrapping(errorType AutoscalerErrorType, wrapped error, msg string, args ...interface{}) AutoscalerError {
+	return &wrappingAutoscalerError{
+		autoscalerErrorImpl: autoscalerErrorImpl{
+			errorType: errorType,
+			msg:       fmt.Sprintf(msg, args...),
+		},
+		wrapped: wrapped,
+	}
+}
+
 // ToAutoscalerError converts an error to AutoscalerError with given type,
 // unless it already is an AutoscalerError (in which case it's not modified).
 func ToAutoscalerError(defaultType AutoscalerErrorType, err error) AutoscalerError {
@@ -109,3 +130,19 @@ func (e autoscalerErrorImpl) AddPrefix(msg string, args ...interface{}) Autoscal
 	e.msg = fmt.Sprintf(msg, args...) + e.msg
 	return e
 }
+
+// Unwrap returns the error wrapped by this error.
+func (e *wrappingAutoscalerError) Unwrap() error {
+	return e.wrapped
+}
+
+// AddPrefix adds a prefix to error message, keeping the wrapped error.
+func (e *wrappingAutoscalerError) AddPrefix(msg string, args ...interface{}) AutoscalerError {
+	return &wrappingAutoscalerError{
+		autoscalerErrorImpl: autoscalerErrorImpl{
+			errorType: e.errorType,
+			msg:       fmt.Sprintf(msg, args...) + e.msg,
+		},
+		wrapped: e.wrapped,
+	}
+}
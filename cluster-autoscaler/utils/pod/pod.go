@@ -26,6 +26,8 @@ import (
 const (
 	// DaemonSetPodAnnotationKey - annotation use to informs the cluster-autoscaler controller when a pod needs to be considered as a Daemonset's Pod.
 	DaemonSetPodAnnotationKey = "cluster-autoscaler.kubernetes.io/daemonset-pod"
+	// FakePodAnnotationKey - annotation marking pods cluster-autoscaler adds to its cluster snapshot for simulations, which don't exist in the API server.
+	FakePodAnnotationKey = "cluster-autoscaler.kubernetes.io/fake-pod"
 )
 
 // IsDaemonSetPod returns true if the Pod should be considered as Pod managed by a DaemonSet
@@ -47,6 +49,11 @@ func IsMirrorPod(pod *apiv1.Pod) bool {
 	return found
 }
 
+// IsFakePod returns true if the pod was added by cluster-autoscaler for a simulation and doesn't exist in the API server.
+func IsFakePod(pod *apiv1.Pod) bool {
+	return pod.Annotations[FakePodAnnotationKey] == "true"
+}
+
 // IsStaticPod returns true if the pod is a static pod.
 func IsStaticPod(pod *apiv1.Pod) bool {
 	if pod.Annotations != nil {
@@ -161,6 +161,45 @@ func TestIsMirrorPod(t *testing.T) {
 	}
 }
 
+func TestIsFakePod(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *apiv1.Pod
+		want bool
+	}{
+		{
+			name: "not a fake pod",
+			pod: &apiv1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+				},
+			},
+			want: false,
+		},
+		{
+			name: "is a fake pod",
+			pod: &apiv1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+					Annotations: map[string]string{
+						FakePodAnnotationKey: "true",
+					},
+				},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFakePod(tt.pod); got != tt.want {
+				t.Errorf("IsFakePod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIsStaticPod(t *testing.T) {
 	tests := []struct {
 		name string